@@ -0,0 +1,148 @@
+package config
+
+import (
+	"strings"
+	"time"
+)
+
+// AccessTokenTTL is how long a signed JWT access token stays valid.
+// Keep it short: clients renew it with their refresh token.
+func AccessTokenTTL() time.Duration {
+	return GetDuration("ACCESS_TOKEN_TTL", 15*time.Minute)
+}
+
+// RefreshTokenTTL is how long an opaque refresh token can be exchanged.
+func RefreshTokenTTL() time.Duration {
+	return GetDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour)
+}
+
+// PasswordResetTTL is how long a password reset link stays usable.
+func PasswordResetTTL() time.Duration {
+	return GetDuration("PASSWORD_RESET_TTL", time.Hour)
+}
+
+// AppBaseURL is the public URL used to build links in outgoing email.
+func AppBaseURL() string {
+	return GetEnv("APP_BASE_URL", "http://localhost:8080")
+}
+
+// EmailVerificationTTL is how long an email verification link stays usable.
+func EmailVerificationTTL() time.Duration {
+	return GetDuration("EMAIL_VERIFICATION_TTL", 24*time.Hour)
+}
+
+// EmailVerificationResendInterval is the minimum gap between two
+// verification emails for the same user.
+func EmailVerificationResendInterval() time.Duration {
+	return GetDuration("EMAIL_VERIFICATION_RESEND_INTERVAL", time.Minute)
+}
+
+// EmailVerificationMaxPerHour caps how many verification emails one user
+// can trigger per hour.
+func EmailVerificationMaxPerHour() int {
+	return GetInt("EMAIL_VERIFICATION_MAX_PER_HOUR", 5)
+}
+
+// RequireVerifiedEmailForCheckout blocks Checkout for users who haven't
+// verified their email yet. Browsing and carting stay open.
+func RequireVerifiedEmailForCheckout() bool {
+	return GetBool("REQUIRE_VERIFIED_EMAIL_FOR_CHECKOUT", true)
+}
+
+// TrustProxyHeaders makes utils.ClientIP read X-Forwarded-For. Enable it
+// only when the app runs behind a load balancer that sets the header.
+func TrustProxyHeaders() bool {
+	return GetBool("TRUST_PROXY_HEADERS", false)
+}
+
+// LoginThrottle holds the brute-force protection settings for Login.
+type LoginThrottle struct {
+	MaxAccountFailures int           // failures before an account is locked
+	MaxIPFailures      int           // failures before an IP is locked
+	LockoutDuration    time.Duration // how long a lock lasts
+	BackoffBase        time.Duration // delay after the first failure, doubled after each one
+	BackoffMax         time.Duration // cap for the exponential delay
+	Window             time.Duration // failures older than this are forgotten
+}
+
+// LoginThrottleSettings reads LoginThrottle from the environment.
+func LoginThrottleSettings() LoginThrottle {
+	return LoginThrottle{
+		MaxAccountFailures: GetInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		MaxIPFailures:      GetInt("LOGIN_MAX_IP_FAILURES", 20),
+		LockoutDuration:    GetDuration("LOGIN_LOCKOUT_DURATION", 15*time.Minute),
+		BackoffBase:        GetDuration("LOGIN_BACKOFF_BASE", time.Second),
+		BackoffMax:         GetDuration("LOGIN_BACKOFF_MAX", time.Minute),
+		Window:             GetDuration("LOGIN_FAILURE_WINDOW", 15*time.Minute),
+	}
+}
+
+// PasswordResetThrottleSettings limits forgot-password emails per address
+// and per IP, so the endpoint can't be used to flood someone's inbox. Every
+// request counts, whether or not the address has an account.
+func PasswordResetThrottleSettings() LoginThrottle {
+	return LoginThrottle{
+		MaxAccountFailures: GetInt("PASSWORD_RESET_MAX_PER_EMAIL", 3),
+		MaxIPFailures:      GetInt("PASSWORD_RESET_MAX_PER_IP", 10),
+		LockoutDuration:    GetDuration("PASSWORD_RESET_LOCKOUT_DURATION", time.Hour),
+		BackoffBase:        GetDuration("PASSWORD_RESET_BACKOFF_BASE", time.Minute),
+		BackoffMax:         GetDuration("PASSWORD_RESET_BACKOFF_MAX", 15*time.Minute),
+		Window:             GetDuration("PASSWORD_RESET_WINDOW", time.Hour),
+	}
+}
+
+// TOTPIssuer is the name authenticator apps show next to the account.
+func TOTPIssuer() string {
+	return GetEnv("TOTP_ISSUER", "FutureMarket")
+}
+
+// RequireAdmin2FA makes AdminMiddleware reject admin tokens that were not
+// issued through a two-factor login.
+func RequireAdmin2FA() bool {
+	return GetBool("REQUIRE_ADMIN_2FA", true)
+}
+
+// MFAChallengeTTL is how long the challenge token from the first login step
+// can be exchanged for real tokens.
+func MFAChallengeTTL() time.Duration {
+	return GetDuration("MFA_CHALLENGE_TTL", 5*time.Minute)
+}
+
+// OIDC holds the settings for "sign in with" an external OpenID Connect
+// provider. The flow is off while Issuer is empty.
+type OIDC struct {
+	Issuer       string        // e.g. https://accounts.example.com
+	ClientID     string        // client registered at the provider
+	ClientSecret string        // empty for public clients (PKCE only)
+	RedirectURL  string        // must point at /api/v1/oidc/callback
+	Scopes       []string      // always includes "openid"
+	StateTTL     time.Duration // how long a started login can be completed
+}
+
+// Enabled reports whether an OIDC provider is configured.
+func (o OIDC) Enabled() bool {
+	return o.Issuer != "" && o.ClientID != ""
+}
+
+// OIDCSettings reads OIDC from the environment.
+func OIDCSettings() OIDC {
+	scopes := strings.Fields(GetEnv("OIDC_SCOPES", "openid email profile"))
+	hasOpenID := false
+	for _, s := range scopes {
+		if s == "openid" {
+			hasOpenID = true
+		}
+	}
+	if !hasOpenID {
+		scopes = append([]string{"openid"}, scopes...)
+	}
+
+	return OIDC{
+		Issuer:       strings.TrimSuffix(GetEnv("OIDC_ISSUER", ""), "/"),
+		ClientID:     GetEnv("OIDC_CLIENT_ID", ""),
+		ClientSecret: GetEnv("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  GetEnv("OIDC_REDIRECT_URL", AppBaseURL()+"/api/v1/oidc/callback"),
+		Scopes:       scopes,
+		StateTTL:     GetDuration("OIDC_STATE_TTL", 10*time.Minute),
+	}
+}
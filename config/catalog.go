@@ -0,0 +1,48 @@
+package config
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// defaultPriceBuckets are the upper bounds (in cents) of the price facet
+// buckets: under $25, $25–50, $50–100, $100–250, $250–500 and $500+.
+var defaultPriceBuckets = []int64{2500, 5000, 10000, 25000, 50000}
+
+// maxPriceBuckets caps the boundaries a request may ask for.
+const maxPriceBuckets = 20
+
+// ParsePriceBuckets parses comma-separated bucket boundaries in cents, e.g.
+// "1000,5000,10000". Boundaries must be positive and strictly ascending.
+func ParsePriceBuckets(s string) ([]int64, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) > maxPriceBuckets {
+		return nil, errors.New("too many price buckets")
+	}
+	bounds := make([]int64, 0, len(parts))
+
+	for _, p := range parts {
+		n, err := strconv.ParseInt(strings.TrimSpace(p), 10, 64)
+		if err != nil || n <= 0 {
+			return nil, errors.New("price buckets must be positive whole cents")
+		}
+		if len(bounds) > 0 && n <= bounds[len(bounds)-1] {
+			return nil, errors.New("price buckets must be in ascending order")
+		}
+		bounds = append(bounds, n)
+	}
+
+	return bounds, nil
+}
+
+// ProductPriceBuckets returns the default price facet boundaries, from
+// PRODUCT_PRICE_BUCKETS when it is set and valid.
+func ProductPriceBuckets() []int64 {
+	if v := GetEnv("PRODUCT_PRICE_BUCKETS", ""); v != "" {
+		if bounds, err := ParsePriceBuckets(v); err == nil {
+			return bounds
+		}
+	}
+	return append([]int64(nil), defaultPriceBuckets...)
+}
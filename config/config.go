@@ -1,25 +1,114 @@
 package config
 
+import (
+	"sort"
+	"sync"
+)
+
+// WildcardAction grants a role every action.
+const WildcardAction = "*"
+
+// Policy maps a role to the actions it is allowed to perform.
+type Policy map[string]map[string]bool
+
+var (
+	policyMu sync.RWMutex
+	policy   = DefaultPolicy()
+)
+
+// DefaultPolicy is the built-in role table. It seeds the role_permissions
+// table on first start and is what RolePermission uses until that table has
+// been loaded.
+func DefaultPolicy() Policy {
+	return Policy{
+		// Admins can do everything.
+		"admin": {WildcardAction: true},
+
+		// Customers can do a limited set of things.
+		"customer": {
+			"manage:cart":  true, // add/update/remove items, view own cart
+			"checkout":     true, // place an order
+			"write:review": true, // create or update review
+			"read:orders":  true, // view own order history
+		},
+	}
+}
+
+// SetPolicy replaces the active role table, e.g. after reloading it from the
+// database.
+func SetPolicy(p Policy) {
+	policyMu.Lock()
+	defer policyMu.Unlock()
+	policy = p
+}
+
+// HasRole reports whether the active policy knows about a role at all.
+func HasRole(role string) bool {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+	_, ok := policy[role]
+	return ok
+}
+
 // RolePermission defines what each role is allowed to do.
 // Public things like browsing products don't need to come through here.
 func RolePermission(userRole, action string) bool {
-	// Admins can do everything.
-	if userRole == "admin" {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+
+	actions, ok := policy[userRole]
+	if !ok {
+		// Default: not allowed.
+		return false
+	}
+
+	return actions[WildcardAction] || actions[action]
+}
+
+// RoleCovers reports whether role holds every permission of other, which
+// it needs to grant other or to act on a user who has it. Only a wildcard
+// role covers another wildcard role.
+func RoleCovers(role, other string) bool {
+	policyMu.RLock()
+	defer policyMu.RUnlock()
+
+	actions, ok := policy[role]
+	if !ok {
+		return false
+	}
+	if actions[WildcardAction] {
 		return true
 	}
 
-	// Customers can do a limited set of things.
-	if userRole == "customer" {
-		switch action {
-		case
-			"manage:cart",  // add/update/remove items, view own cart
-			"checkout",     // place an order
-			"write:review", // create or update review
-			"read:orders":  // view own order history
-			return true
+	for action, allowed := range policy[other] {
+		if allowed && !actions[action] {
+			return false
 		}
 	}
+	return true
+}
+
+// apiKeyScopes are the actions an API key can be granted. Customer actions
+// (cart, checkout, reviews, orders) work on the caller's own account and
+// need a real user. API keys can never manage other API keys, nor users:
+// a key that can promote an account to admin is an admin login without
+// password or 2FA.
+var apiKeyScopes = map[string]bool{
+	"manage:products": true,
+	"read:audit":      true,
+}
 
-	// Default: not allowed.
-	return false
+// IsAPIKeyScope reports whether action may be granted to an API key.
+func IsAPIKeyScope(action string) bool {
+	return apiKeyScopes[action]
+}
+
+// APIKeyScopes lists every action an API key can be granted.
+func APIKeyScopes() []string {
+	scopes := make([]string, 0, len(apiKeyScopes))
+	for s := range apiKeyScopes {
+		scopes = append(scopes, s)
+	}
+	sort.Strings(scopes)
+	return scopes
 }
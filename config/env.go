@@ -0,0 +1,47 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"time"
+)
+
+// GetEnv returns the value of an environment variable, or fallback when it
+// is unset or empty.
+func GetEnv(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+// GetDuration parses an environment variable such as "15m" or "720h".
+// Invalid values fall back to the default instead of failing startup.
+func GetDuration(key string, fallback time.Duration) time.Duration {
+	if v := os.Getenv(key); v != "" {
+		if d, err := time.ParseDuration(v); err == nil && d > 0 {
+			return d
+		}
+	}
+	return fallback
+}
+
+// GetInt parses an integer environment variable.
+func GetInt(key string, fallback int) int {
+	if v := os.Getenv(key); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			return n
+		}
+	}
+	return fallback
+}
+
+// GetBool parses a boolean environment variable ("true", "1", "false", ...).
+func GetBool(key string, fallback bool) bool {
+	if v := os.Getenv(key); v != "" {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,142 @@
+package db
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// schemaMigrations are idempotent SQL statements that must run before
+// AutoMigrate, e.g. to drop columns AutoMigrate would otherwise keep.
+var schemaMigrations = []struct {
+	name string
+	sql  string
+}{
+	{
+		// Blacklist entries used to store the raw JWT; they are now keyed by
+		// jti or token hash and expire with the token.
+		name: "drop raw token column from token_blacklists",
+		sql:  "ALTER TABLE IF EXISTS token_blacklists DROP COLUMN IF EXISTS token",
+	},
+	{
+		// Stock used to be unique per product; variants add one row each.
+		// AutoMigrate then creates the partial unique index instead.
+		name: "drop unique stock per product index",
+		sql:  "DROP INDEX IF EXISTS idx_stocks_product_id",
+	},
+	{
+		// Emails used to be unique across deleted accounts too, so a
+		// deleted user could never sign up again. AutoMigrate then creates
+		// idx_users_email_active, which only covers live accounts.
+		name: "drop unique email index covering deleted users",
+		sql:  "DROP INDEX IF EXISTS idx_users_email",
+	},
+	{
+		// Checkout needs a verified email. Accounts from before email
+		// verification existed never got a link, so they count as verified
+		// since signing up. Adding the column here, before AutoMigrate
+		// does, makes sure this only ever touches those accounts.
+		name: "add users.email_verified_at and verify existing users",
+		sql: `DO $$
+			BEGIN
+				IF to_regclass('users') IS NOT NULL AND NOT EXISTS (
+					SELECT 1 FROM information_schema.columns
+					WHERE table_schema = current_schema() AND table_name = 'users' AND column_name = 'email_verified_at'
+				) THEN
+					ALTER TABLE users ADD COLUMN email_verified_at timestamptz;
+					UPDATE users SET email_verified_at = created_at;
+				END IF;
+			END $$`,
+	},
+}
+
+// lateSchemaMigrations are idempotent SQL statements for schema that
+// AutoMigrate can't express (generated columns, GIN indexes). They run
+// right after AutoMigrate, so the tables exist.
+var lateSchemaMigrations = []struct {
+	name string
+	sql  string
+}{
+	{
+		// Full-text search over products: name weighs most, then category,
+		// then description. The column is maintained by Postgres itself.
+		name: "add products.search_vector",
+		sql: `ALTER TABLE products ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('english'::regconfig, coalesce(name, '')), 'A') ||
+				setweight(to_tsvector('english'::regconfig, coalesce(category, '')), 'B') ||
+				setweight(to_tsvector('english'::regconfig, coalesce(description, '')), 'C')
+			) STORED`,
+	},
+	{
+		name: "index products.search_vector",
+		sql:  "CREATE INDEX IF NOT EXISTS idx_products_search_vector ON products USING GIN (search_vector)",
+	},
+	{
+		// Serves attr.<key>=<value> filters, which use @> containment
+		name: "index products.attributes",
+		sql:  "CREATE INDEX IF NOT EXISTS idx_products_attributes ON products USING GIN (attributes jsonb_path_ops)",
+	},
+}
+
+// dataMigrations are idempotent SQL statements that fix up existing rows
+// after AutoMigrate has brought the schema up to date.
+var dataMigrations = []struct {
+	name string
+	sql  string
+}{
+	{
+		// Public registration used to store "user", which no policy knows.
+		name: "normalise legacy user role",
+		sql:  "UPDATE users SET role = 'customer' WHERE role = 'user'",
+	},
+	{
+		// Products used to carry a free-text category. Every distinct
+		// spelling becomes a top-level category keyed by its slug, so
+		// "Electronics" and "electronics " end up in the same one.
+		name: "create categories from product category strings",
+		sql: `INSERT INTO categories (name, slug, position, created_at, updated_at)
+			SELECT DISTINCT ON (slug) name, slug, 0, now(), now()
+			FROM (
+				SELECT initcap(trim(category)) AS name,
+					trim(both '-' from regexp_replace(lower(trim(category)), '[^a-z0-9]+', '-', 'g')) AS slug
+				FROM products
+				WHERE category_id IS NULL AND deleted_at IS NULL
+			) legacy
+			WHERE slug <> '' AND NOT EXISTS (
+				SELECT 1 FROM categories c WHERE c.slug = legacy.slug AND c.deleted_at IS NULL
+			)
+			ORDER BY slug, name`,
+	},
+	{
+		name: "link products to their category",
+		sql: `UPDATE products p SET category_id = c.id, category = c.name
+			FROM categories c
+			WHERE p.category_id IS NULL AND c.deleted_at IS NULL
+				AND c.slug = trim(both '-' from regexp_replace(lower(trim(p.category)), '[^a-z0-9]+', '-', 'g'))`,
+	},
+}
+
+func runSchemaMigrations(db *gorm.DB) {
+	for _, m := range schemaMigrations {
+		if err := db.Exec(m.sql).Error; err != nil {
+			log.Fatalf("schema migration %q failed: %v", m.name, err)
+		}
+	}
+}
+
+func runLateSchemaMigrations(db *gorm.DB) {
+	for _, m := range lateSchemaMigrations {
+		if err := db.Exec(m.sql).Error; err != nil {
+			log.Fatalf("schema migration %q failed: %v", m.name, err)
+		}
+	}
+}
+
+func runDataMigrations(db *gorm.DB) {
+	for _, m := range dataMigrations {
+		if err := db.Exec(m.sql).Error; err != nil {
+			log.Fatalf("data migration %q failed: %v", m.name, err)
+		}
+	}
+}
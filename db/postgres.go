@@ -31,9 +31,15 @@ func InitDB() *gorm.DB {
 
 	log.Println("Connected to database successfully!")
 
+	runSchemaMigrations(DB)
+
 	err = DB.AutoMigrate(
 		&models.User{},
+		&models.Category{},
+		&models.AttributeDefinition{},
 		&models.Product{},
+		&models.ProductVariant{},
+		&models.ProductImage{},
 		&models.Stock{},
 		&models.Cart{},
 		&models.CartItem{},
@@ -41,10 +47,25 @@ func InitDB() *gorm.DB {
 		&models.OrderItem{},
 		&models.Review{},
 		&models.TokenBlacklist{},
+		&models.RefreshToken{},
+		&models.RolePermission{},
+		&models.PasswordResetToken{},
+		&models.EmailVerificationToken{},
+		&models.LoginAttempt{},
+		&models.AuditEvent{},
+		&models.RecoveryCode{},
+		&models.MFAChallenge{},
+		&models.Session{},
+		&models.OIDCState{},
+		&models.UserIdentity{},
+		&models.APIKey{},
 	)
 	if err != nil {
 		log.Fatalf("unable to migrate schema: %v", err)
 	}
 
+	runLateSchemaMigrations(DB)
+	runDataMigrations(DB)
+
 	return DB
 }
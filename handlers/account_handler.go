@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"futuremarket/middleware"
+	"futuremarket/service"
+
+	"gorm.io/gorm"
+)
+
+// AccountHandler lets a logged-in user view and manage their own account
+type AccountHandler struct {
+	Service service.UserService
+	Data    service.AccountDataService
+	Audit   service.AuditService
+}
+
+// writeAccountError maps self-service errors onto HTTP status codes.
+func writeAccountError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrWrongPassword):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrEmailTaken), errors.Is(err, service.ErrAdminAccountDeletion):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		// Validation errors from ValidateName/ValidateEmail/ValidatePassword
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	}
+}
+
+// -----------------------------------------------
+// GET /api/v1/me
+// -----------------------------------------------
+func (h *AccountHandler) GetMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := h.Service.GetUserByID(userID)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	writeUser(w, service.NewUserView(user))
+}
+
+// -----------------------------------------------
+// PATCH /api/v1/me
+// -----------------------------------------------
+// Name changes apply immediately. Email changes need current_password and
+// only take effect once the new address has been verified.
+func (h *AccountHandler) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Name            *string `json:"name"`
+		Email           *string `json:"email"`
+		CurrentPassword string  `json:"current_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Service.GetUserByID(userID)
+	if err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	if req.Name != nil && *req.Name != user.Name {
+		if user, err = h.Service.UpdateName(userID, *req.Name); err != nil {
+			writeAccountError(w, err)
+			return
+		}
+	}
+
+	if req.Email != nil && *req.Email != user.Email {
+		if req.CurrentPassword == "" {
+			http.Error(w, "current_password required to change email", http.StatusBadRequest)
+			return
+		}
+		if user, err = h.Service.RequestEmailChange(userID, req.CurrentPassword, *req.Email); err != nil {
+			writeAccountError(w, err)
+			return
+		}
+	}
+
+	writeUser(w, service.NewUserView(user))
+}
+
+// -----------------------------------------------
+// POST /api/v1/me/password
+// -----------------------------------------------
+func (h *AccountHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.CurrentPassword == "" || req.NewPassword == "" {
+		http.Error(w, "current_password and new_password required", http.StatusBadRequest)
+		return
+	}
+
+	// Keep the caller's own session; sign out everything else
+	sessionID, _ := middleware.GetSessionIDFromContext(r)
+
+	if err := h.Service.ChangePassword(userID, req.CurrentPassword, req.NewPassword, sessionID); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "password changed",
+	})
+}
+
+// -----------------------------------------------
+// GET /api/v1/me/export
+// -----------------------------------------------
+// Returns everything we store about the caller as a downloadable JSON file.
+func (h *AccountHandler) ExportMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	export, err := h.Data.Export(userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeAccountError(w, err)
+			return
+		}
+		http.Error(w, "failed to export account data", http.StatusInternalServerError)
+		return
+	}
+
+	h.Audit.Log(auditEntry(r, "account.export", "user", strconv.Itoa(int(userID))))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="futuremarket-export-%d.json"`, userID))
+	w.Header().Set("Cache-Control", "no-store")
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(export)
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/me
+// -----------------------------------------------
+// Anonymises the caller's account. Orders stay for accounting and reviews
+// stay for ratings, both without personal data. Needs current_password
+// when the account has one.
+func (h *AccountHandler) DeleteMe(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+	}
+
+	// An empty body is fine for accounts without a password
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Data.DeleteAccount(userID, req.CurrentPassword); err != nil {
+		writeAccountError(w, err)
+		return
+	}
+
+	h.Audit.Log(auditEntry(r, "account.delete", "user", strconv.Itoa(int(userID))))
+
+	w.WriteHeader(http.StatusNoContent)
+}
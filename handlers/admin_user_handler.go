@@ -0,0 +1,230 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"futuremarket/middleware"
+	"futuremarket/service"
+
+	"gorm.io/gorm"
+)
+
+// AdminUserHandler lets admins manage user accounts
+type AdminUserHandler struct {
+	Service    service.UserService
+	LoginGuard service.LoginGuard
+	Audit      service.AuditService
+}
+
+// parseIDVar reads the numeric {id} path variable of any route (users,
+// products, images, API keys, ...).
+func parseIDVar(r *http.Request) (uint, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || id < 1 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/users/{id}/unlock
+// -----------------------------------------------
+func (h *AdminUserHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Service.GetUserByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	if err := h.LoginGuard.Unlock(user.Email); err != nil {
+		http.Error(w, "failed to unlock user", http.StatusInternalServerError)
+		return
+	}
+
+	h.audit(r, "login.unlock", user.ID, nil)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "user unlocked",
+	})
+}
+
+// writeUserError maps UserService errors onto HTTP status codes.
+func writeUserError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "user not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrUnknownRole):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrCannotModifySelf):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrRoleNotCovered):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		http.Error(w, "failed to update user", http.StatusInternalServerError)
+	}
+}
+
+func (h *AdminUserHandler) audit(r *http.Request, action string, userID uint, details any) {
+	entry := auditEntry(r, action, "user", strconv.Itoa(int(userID)))
+	entry.Details = details
+	h.Audit.Log(entry)
+}
+
+func writeUser(w http.ResponseWriter, view service.UserView) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(view)
+}
+
+// -----------------------------------------------
+// GET /api/v1/admin/users?page=&limit=&q=
+// -----------------------------------------------
+func (h *AdminUserHandler) ListUsers(w http.ResponseWriter, r *http.Request) {
+	page := parseQueryInt(r, "page", 1)
+	limit := parseQueryInt(r, "limit", 20)
+	if limit > 100 {
+		limit = 100
+	}
+
+	result, err := h.Service.ListUsers(page, limit, r.URL.Query().Get("q"))
+	if err != nil {
+		http.Error(w, "failed to load users", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// -----------------------------------------------
+// GET /api/v1/admin/users/{id}
+// -----------------------------------------------
+func (h *AdminUserHandler) GetUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Service.GetUserByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	writeUser(w, service.NewUserView(user))
+}
+
+// -----------------------------------------------
+// PATCH /api/v1/admin/users/{id}/role
+// -----------------------------------------------
+func (h *AdminUserHandler) ChangeRole(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		Role string `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Role == "" {
+		http.Error(w, "role required", http.StatusBadRequest)
+		return
+	}
+
+	actorID, _ := middleware.GetUserIDFromContext(r)
+
+	before, err := h.Service.GetUserByID(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	user, err := h.Service.ChangeRole(actorID, id, req.Role)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "user.role_change", "user", strconv.Itoa(int(id)))
+	entry.Before = map[string]string{"role": before.Role}
+	entry.After = map[string]string{"role": user.Role}
+	h.Audit.Log(entry)
+
+	writeUser(w, service.NewUserView(user))
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/users/{id}/disable
+// -----------------------------------------------
+func (h *AdminUserHandler) DisableUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	actorID, _ := middleware.GetUserIDFromContext(r)
+
+	user, err := h.Service.DisableUser(actorID, id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	h.audit(r, "user.disable", id, nil)
+	writeUser(w, service.NewUserView(user))
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/users/{id}/enable
+// -----------------------------------------------
+func (h *AdminUserHandler) EnableUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	user, err := h.Service.EnableUser(id)
+	if err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	h.audit(r, "user.enable", id, nil)
+	writeUser(w, service.NewUserView(user))
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/admin/users/{id}
+// -----------------------------------------------
+func (h *AdminUserHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid user id", http.StatusBadRequest)
+		return
+	}
+
+	actorID, _ := middleware.GetUserIDFromContext(r)
+
+	if err := h.Service.DeleteUser(actorID, id); err != nil {
+		writeUserError(w, err)
+		return
+	}
+
+	h.audit(r, "user.delete", id, nil)
+	w.WriteHeader(http.StatusNoContent)
+}
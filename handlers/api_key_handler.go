@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"futuremarket/middleware"
+	"futuremarket/service"
+)
+
+// APIKeyHandler lets admins issue and revoke keys for server-to-server
+// integrations
+type APIKeyHandler struct {
+	Service service.APIKeyService
+	Audit   service.AuditService
+}
+
+// writeAPIKeyError maps APIKeyService errors onto HTTP status codes.
+func writeAPIKeyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrAPIKeyNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidScope),
+		errors.Is(err, service.ErrAPIKeyName),
+		errors.Is(err, service.ErrAPIKeyExpiry):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "api key request failed", http.StatusInternalServerError)
+	}
+}
+
+func (h *APIKeyHandler) audit(r *http.Request, action string, keyID uint, details any) {
+	entry := auditEntry(r, action, "api_key", strconv.Itoa(int(keyID)))
+	entry.Details = details
+	h.Audit.Log(entry)
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/api-keys
+// -----------------------------------------------
+func (h *APIKeyHandler) CreateKey(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"` // optional, RFC 3339
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	// Keys are always created by a person, never by another key
+	actorID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	role, _ := r.Context().Value(middleware.ContextRole).(string)
+
+	created, err := h.Service.CreateKey(actorID, role, req.Name, req.Scopes, req.ExpiresAt)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	h.audit(r, "api_key.create", created.ID, map[string]any{
+		"name":   created.Name,
+		"scopes": created.Scopes,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// -----------------------------------------------
+// GET /api/v1/admin/api-keys
+// -----------------------------------------------
+func (h *APIKeyHandler) ListKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.Service.ListKeys()
+	if err != nil {
+		http.Error(w, "failed to load api keys", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"api_keys": keys,
+	})
+}
+
+// -----------------------------------------------
+// GET /api/v1/admin/api-keys/{id}
+// -----------------------------------------------
+func (h *APIKeyHandler) GetKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid api key id", http.StatusBadRequest)
+		return
+	}
+
+	key, err := h.Service.GetKey(id)
+	if err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(key)
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/admin/api-keys/{id}
+// -----------------------------------------------
+func (h *APIKeyHandler) RevokeKey(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid api key id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.RevokeKey(id); err != nil {
+		writeAPIKeyError(w, err)
+		return
+	}
+
+	h.audit(r, "api_key.revoke", id, nil)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"futuremarket/service"
+)
+
+// AttributeHandler serves the custom attribute definitions of categories
+// and their admin management.
+type AttributeHandler struct {
+	Service service.AttributeService
+	Audit   service.AuditService
+}
+
+// writeAttributeError maps attribute definition errors onto HTTP status codes.
+func writeAttributeError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrAttributeNotFound), errors.Is(err, service.ErrCategoryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrDuplicateAttribute):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidAttribute):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to save attribute", http.StatusInternalServerError)
+	}
+}
+
+// -----------------------------------------------
+// GET /api/v1/categories/{id}/attributes
+// -----------------------------------------------
+// Lists the attributes products in the category can have, including those
+// inherited from parent categories.
+func (h *AttributeHandler) ListAttributes(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	attributes, err := h.Service.ListForCategory(id)
+	if err != nil {
+		if errors.Is(err, service.ErrCategoryNotFound) {
+			writeAttributeError(w, err)
+			return
+		}
+		http.Error(w, "failed to load attributes", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"attributes": attributes})
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/categories/{id}/attributes
+// -----------------------------------------------
+// Body: {"key", "label", "type": string|number|boolean|enum, "options"
+// (enum only), "unit", "required", "position"}
+func (h *AttributeHandler) CreateAttribute(w http.ResponseWriter, r *http.Request) {
+	categoryID, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	var req service.AttributeInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	attribute, err := h.Service.CreateAttribute(categoryID, req)
+	if err != nil {
+		writeAttributeError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "category.attribute_create", "attribute", strconv.Itoa(int(attribute.ID)))
+	entry.After = attribute
+	h.Audit.Log(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attribute)
+}
+
+// -----------------------------------------------
+// PATCH /api/v1/admin/attributes/{id}
+// -----------------------------------------------
+// Key and type can't change; everything else can.
+func (h *AttributeHandler) UpdateAttribute(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid attribute id", http.StatusBadRequest)
+		return
+	}
+
+	var req service.AttributeInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	before, updated, err := h.Service.UpdateAttribute(id, req)
+	if err != nil {
+		writeAttributeError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "category.attribute_update", "attribute", strconv.Itoa(int(id)))
+	entry.Before = before
+	entry.After = updated
+	h.Audit.Log(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/admin/attributes/{id}
+// -----------------------------------------------
+// Also removes the attribute's values from the products of the category
+// and its subcategories.
+func (h *AttributeHandler) DeleteAttribute(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid attribute id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.Service.DeleteAttribute(id)
+	if err != nil {
+		writeAttributeError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "category.attribute_delete", "attribute", strconv.Itoa(int(id)))
+	entry.Before = deleted
+	h.Audit.Log(entry)
+
+	w.WriteHeader(http.StatusNoContent)
+}
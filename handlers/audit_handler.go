@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/middleware"
+	"futuremarket/repository"
+	"futuremarket/service"
+	"futuremarket/utils"
+)
+
+// AuditHandler lets admins search the security audit log
+type AuditHandler struct {
+	Service service.AuditService
+}
+
+// auditEntry starts an audit event for the current request, filling in who
+// made it (user or API key), from where, and the request ID.
+func auditEntry(r *http.Request, action, targetType, targetID string) service.AuditEntry {
+	entry := service.AuditEntry{
+		IP:         utils.ClientIP(r, config.TrustProxyHeaders()),
+		RequestID:  middleware.GetRequestIDFromContext(r),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+	}
+	if userID, ok := middleware.GetUserIDFromContext(r); ok {
+		entry.ActorID = &userID
+	}
+	if keyID, ok := middleware.GetAPIKeyIDFromContext(r); ok {
+		entry.APIKeyID = &keyID
+	}
+	return entry
+}
+
+// -----------------------------------------------
+// GET /api/v1/admin/audit
+// -----------------------------------------------
+// Filters by actor_id, api_key_id, action, target_type, target_id,
+// request_id, ip, since and until; pages with page and limit.
+func (h *AuditHandler) ListEvents(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	filter := repository.AuditFilter{
+		Action:     q.Get("action"),
+		TargetType: q.Get("target_type"),
+		TargetID:   q.Get("target_id"),
+		RequestID:  q.Get("request_id"),
+		IP:         q.Get("ip"),
+	}
+
+	for key, dst := range map[string]**uint{"actor_id": &filter.ActorID, "api_key_id": &filter.APIKeyID} {
+		if v := q.Get(key); v != "" {
+			id, err := strconv.ParseUint(v, 10, 64)
+			if err != nil || id == 0 {
+				http.Error(w, "invalid "+key, http.StatusBadRequest)
+				return
+			}
+			uid := uint(id)
+			*dst = &uid
+		}
+	}
+
+	for key, dst := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		if v := q.Get(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				http.Error(w, key+" must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			*dst = &t
+		}
+	}
+
+	page := parseQueryInt(r, "page", 1)
+	limit := parseQueryInt(r, "limit", 50)
+	if limit > 200 {
+		limit = 200
+	}
+
+	result, err := h.Service.ListEvents(filter, page, limit)
+	if err != nil {
+		http.Error(w, "failed to load audit events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -2,21 +2,47 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"log"
+	"math"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
-	"time"
 
+	"futuremarket/config"
+	"futuremarket/middleware"
+	"futuremarket/models"
 	"futuremarket/service"
+	"futuremarket/utils"
 
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
-// AuthHandler handles registration, login, and logout
+// AuthHandler handles registration, login, token refresh and logout
 type AuthHandler struct {
 	Service          service.UserService
 	BlacklistService service.BlacklistService // REQUIRED FOR LOGOUT
+	TokenService     service.TokenService
+	Sessions         service.SessionService
+	Verification     service.EmailVerificationService
+	LoginGuard       service.LoginGuard
+	TwoFactor        service.TwoFactorService
+	Audit            service.AuditService
+}
+
+// tokenResponse keeps the legacy "token" field next to the new pair so
+// existing clients keep working.
+type tokenResponse struct {
+	Token string `json:"token"`
+	service.TokenPair
+}
+
+func writeTokenPair(w http.ResponseWriter, pair service.TokenPair) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokenResponse{
+		Token:     pair.AccessToken,
+		TokenPair: pair,
+	})
 }
 
 // -----------------------------------------------
@@ -27,7 +53,6 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		Name     string `json:"name"`
 		Email    string `json:"email"`
 		Password string `json:"password"`
-		Role     string `json:"role"`
 	}
 
 	// Parse JSON
@@ -52,26 +77,33 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	if req.Role != "user" && req.Role != "admin" {
-		http.Error(w, "Role can either be user or admin", http.StatusBadRequest)
-		return
-	}
+
 	// -----------------------------
-	// CREATE USER (service handles hashing + checking duplicates)
+	// CREATE USER (always a customer; service handles hashing + checking duplicates)
 	// -----------------------------
-	_, err := h.Service.RegisterUser(req.Name, req.Email, req.Password, req.Role)
+	user, err := h.Service.RegisterUser(req.Name, req.Email, req.Password)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
+	entry := auditEntry(r, "auth.register", "user", strconv.Itoa(int(user.ID)))
+	entry.ActorID = &user.ID
+	h.Audit.Log(entry)
+
+	// Verification email failures shouldn't undo the registration;
+	// the user can ask for a new link via /email/verify/resend.
+	if err := h.Verification.SendVerification(user); err != nil {
+		log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+	}
+
 	// -----------------------------
 	// SUCCESS RESPONSE
 	// -----------------------------
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
-		"message": "registration successful",
+		"message": "registration successful, please check your email to verify your address",
 	})
 }
 
@@ -80,8 +112,9 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 // -----------------------------------------------
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var req struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
@@ -94,40 +127,231 @@ func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ip := utils.ClientIP(r, config.TrustProxyHeaders())
+
+	// Brute-force protection (per account + per IP)
+	if !h.loginAllowed(w, req.Email, ip) {
+		return
+	}
+
 	// Lookup user
 	user, err := h.Service.GetUserByEmail(req.Email)
 	if err != nil {
+		h.recordLoginFailure(r, req.Email, ip, "unknown_email")
 		http.Error(w, "invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
 	// Verify password
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		h.recordLoginFailure(r, req.Email, ip, "wrong_password")
 		http.Error(w, "invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
-	// JWT claims
-	claims := jwt.MapClaims{
-		"user_id": user.ID,
-		"role":    user.Role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(),
+	h.continueLogin(w, r, user, req.DeviceName)
+}
+
+// continueLogin runs after the first factor (password or an external
+// identity provider) succeeded: it asks for the TOTP code when 2FA is on
+// and otherwise completes the login.
+func (h *AuthHandler) continueLogin(w http.ResponseWriter, r *http.Request, user models.User, deviceName string) {
+	if user.DisabledAt != nil {
+		h.auditLoginFailure(r, user.Email, "account_disabled")
+		http.Error(w, service.ErrAccountDisabled.Error(), http.StatusForbidden)
+		return
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	// Two-step login: first factor OK, now ask for the TOTP code. Failed
+	// attempts aren't reset yet so the code step stays throttled too.
+	if user.TOTPEnabledAt != nil {
+		challenge, err := h.TokenService.IssueChallenge(user)
+		if err != nil {
+			http.Error(w, "failed to generate token", http.StatusInternalServerError)
+			return
+		}
 
-	// Sign token
-	signedToken, err := token.SignedString([]byte(os.Getenv("JWT_SECRET")))
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"mfa_required":    true,
+			"challenge_token": challenge,
+		})
+		return
+	}
+
+	h.completeLogin(w, r, user, false, deviceName)
+}
+
+// -----------------------------------------------
+// POST /api/v1/login/2fa
+// -----------------------------------------------
+func (h *AuthHandler) LoginTwoFactor(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ChallengeToken string `json:"challenge_token"`
+		Code           string `json:"code"` // TOTP code or recovery code
+		DeviceName     string `json:"device_name"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.ChallengeToken == "" || req.Code == "" {
+		http.Error(w, "challenge_token and code required", http.StatusBadRequest)
+		return
+	}
+
+	// Used up here, so a leaked challenge can't be replayed to guess codes
+	userID, err := h.TokenService.ConsumeChallenge(req.ChallengeToken)
 	if err != nil {
-		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		if errors.Is(err, service.ErrInvalidChallenge) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "server error", http.StatusInternalServerError)
 		return
 	}
 
-	// Successful login → return JWT
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{
-		"token": signedToken,
+	user, err := h.Service.GetUserByID(userID)
+	if err != nil || user.DisabledAt != nil {
+		http.Error(w, service.ErrInvalidChallenge.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	ip := utils.ClientIP(r, config.TrustProxyHeaders())
+	if !h.loginAllowed(w, user.Email, ip) {
+		return
+	}
+
+	if err := h.TwoFactor.VerifyCode(user, req.Code); err != nil {
+		if writeBlocked(w, err) {
+			return
+		}
+		if errors.Is(err, service.ErrInvalidTwoFactorCode) || errors.Is(err, service.ErrTwoFactorNotEnabled) {
+			h.recordLoginFailure(r, user.Email, ip, "invalid_2fa_code")
+			http.Error(w, service.ErrInvalidTwoFactorCode.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+
+	h.completeLogin(w, r, user, true, req.DeviceName)
+}
+
+// completeLogin clears failed attempts, opens a session for the device and
+// returns a fresh token pair.
+func (h *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, user models.User, mfa bool, deviceName string) {
+	if err := h.LoginGuard.RecordSuccess(user.Email); err != nil {
+		log.Printf("failed to reset login attempts for %s: %v", user.Email, err)
+	}
+
+	if len(deviceName) > 100 {
+		deviceName = deviceName[:100]
+	}
+	userAgent := r.UserAgent()
+	if len(userAgent) > 500 {
+		userAgent = userAgent[:500]
+	}
+
+	// Access token + refresh token (new session per login)
+	pair, err := h.TokenService.IssueTokens(user, mfa, service.SessionMeta{
+		DeviceName: deviceName,
+		IP:         utils.ClientIP(r, config.TrustProxyHeaders()),
+		UserAgent:  userAgent,
 	})
+	if err != nil {
+		http.Error(w, "failed to generate token", http.StatusInternalServerError)
+		return
+	}
+
+	entry := auditEntry(r, "auth.login", "user", strconv.Itoa(int(user.ID)))
+	entry.ActorID = &user.ID
+	entry.Details = map[string]any{"mfa": mfa, "device_name": deviceName}
+	h.Audit.Log(entry)
+
+	// Successful login → return tokens
+	writeTokenPair(w, pair)
+}
+
+// loginAllowed writes a 429 and returns false while the account or IP is
+// throttled.
+func (h *AuthHandler) loginAllowed(w http.ResponseWriter, email, ip string) bool {
+	return guardAllows(w, h.LoginGuard, email, ip)
+}
+
+// guardAllows writes a 429 with Retry-After and returns false while guard
+// throttles the account or IP.
+func guardAllows(w http.ResponseWriter, guard service.LoginGuard, account, ip string) bool {
+	err := guard.Check(account, ip)
+	if err == nil {
+		return true
+	}
+
+	if !writeBlocked(w, err) {
+		http.Error(w, "server error", http.StatusInternalServerError)
+	}
+	return false
+}
+
+// writeBlocked answers 429 with Retry-After and returns true when err is a
+// service.LoginBlockedError.
+func writeBlocked(w http.ResponseWriter, err error) bool {
+	var blocked service.LoginBlockedError
+	if !errors.As(err, &blocked) {
+		return false
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(blocked.RetryAfter.Seconds()))))
+	http.Error(w, err.Error(), http.StatusTooManyRequests)
+	return true
+}
+
+// recordLoginFailure counts a failed attempt towards the lockout and writes
+// it to the audit log.
+func (h *AuthHandler) recordLoginFailure(r *http.Request, email, ip, reason string) {
+	if err := h.LoginGuard.RecordFailure(email, ip); err != nil {
+		log.Printf("failed to record login failure for %s: %v", email, err)
+	}
+	h.auditLoginFailure(r, email, reason)
+}
+
+func (h *AuthHandler) auditLoginFailure(r *http.Request, email, reason string) {
+	entry := auditEntry(r, "auth.login_failed", "email", email)
+	entry.Details = map[string]string{"reason": reason}
+	h.Audit.Log(entry)
+}
+
+// -----------------------------------------------
+// POST /api/v1/token/refresh
+// -----------------------------------------------
+func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.RefreshToken == "" {
+		http.Error(w, "refresh_token required", http.StatusBadRequest)
+		return
+	}
+
+	pair, err := h.TokenService.Refresh(req.RefreshToken, utils.ClientIP(r, config.TrustProxyHeaders()))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		http.Error(w, "failed to refresh token", http.StatusInternalServerError)
+		return
+	}
+
+	writeTokenPair(w, pair)
 }
 
 // -----------------------------------------------
@@ -142,13 +366,35 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 
 	token := strings.TrimPrefix(authHeader, "Bearer ")
 
-	// Blacklist the token
-	err := h.BlacklistService.BlacklistToken(token)
+	// Blacklist the token by its verified jti and exp
+	claims, err := h.TokenService.Keys.Parse(token)
 	if err != nil {
+		http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+		return
+	}
+	if err := h.BlacklistService.BlacklistToken(token, claims); err != nil {
 		http.Error(w, "failed to blacklist token", http.StatusInternalServerError)
 		return
 	}
 
+	// End the session (and its refresh tokens) this access token belongs to.
+	// Going through SessionService also marks it revoked in the session
+	// cache, so the other access tokens of the session stop working now.
+	userID, _ := middleware.GetUserIDFromContext(r)
+	sessionID, hasSession := middleware.GetSessionIDFromContext(r)
+	if hasSession {
+		if err := h.Sessions.RevokeSession(userID, sessionID); err != nil && !errors.Is(err, service.ErrSessionNotFound) {
+			http.Error(w, "failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	entry := auditEntry(r, "auth.logout", "user", strconv.Itoa(int(userID)))
+	if hasSession {
+		entry.Details = map[string]uint{"session_id": sessionID}
+	}
+	h.Audit.Log(entry)
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	json.NewEncoder(w).Encode(map[string]string{
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"futuremarket/jwtkeys"
+	"futuremarket/middleware"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/service"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func hmacKeyset(secret string) *jwtkeys.Keyset {
+	return &jwtkeys.Keyset{
+		Active: &jwtkeys.Key{
+			ID:        "test",
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   []byte(secret),
+			VerifyKey: []byte(secret),
+		},
+		Issuer:   "futuremarket",
+		Audience: "futuremarket-api",
+	}
+}
+
+func TestLogout(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.RefreshToken{},
+		&models.TokenBlacklist{}, &models.AuditEvent{})
+	tokens := service.TokenService{
+		Keys:     hmacKeyset("test-secret-test-secret-test-secret"),
+		Repo:     repository.RefreshTokenRepo{DB: db},
+		Sessions: repository.SessionRepo{DB: db},
+	}
+	sessions := service.SessionService{
+		Repo:      tokens.Sessions,
+		Tokens:    tokens,
+		Cache:     service.NewTTLCache(100),
+		ActiveTTL: time.Hour,
+	}
+	blacklist := service.BlacklistService{
+		Repo:        repository.BlacklistRepository{DB: db},
+		NegativeTTL: time.Minute,
+	}
+	h := &AuthHandler{
+		BlacklistService: blacklist,
+		TokenService:     tokens,
+		Sessions:         sessions,
+		Audit:            service.AuditService{Repo: repository.AuditRepo{DB: db}},
+	}
+
+	user := models.User{Name: "Owner", Email: "owner@example.com", Role: "customer"}
+	db.Create(&user)
+	pair, err := tokens.IssueTokens(user, false, service.SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, _ := tokens.Keys.Parse(pair.AccessToken)
+	sessionID := uint(claims["sid"].(float64))
+
+	logout := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/logout", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		ctx := context.WithValue(r.Context(), middleware.ContextUserID, int(user.ID))
+		ctx = context.WithValue(ctx, middleware.ContextSessionID, sessionID)
+		w := httptest.NewRecorder()
+		h.Logout(w, r.WithContext(ctx))
+		return w
+	}
+
+	// A token the server didn't sign can't pick what gets blacklisted
+	forged, _ := hmacKeyset("someone-elses-secret-someone-elses").Sign(jwt.MapClaims{
+		"jti": claims["jti"],
+		"exp": time.Now().Add(100 * 365 * 24 * time.Hour).Unix(),
+	})
+	if w := logout(forged); w.Code != http.StatusUnauthorized {
+		t.Fatalf("forged token: status %d, want 401", w.Code)
+	}
+	var count int64
+	db.Model(&models.TokenBlacklist{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("forged token added %d blacklist entries", count)
+	}
+
+	if w := logout(pair.AccessToken); w.Code != http.StatusOK {
+		t.Fatalf("logout: status %d: %s", w.Code, w.Body)
+	}
+
+	if blacklisted, err := blacklist.IsTokenBlacklisted(pair.AccessToken, claims); err != nil || !blacklisted {
+		t.Errorf("access token blacklisted = %v, %v", blacklisted, err)
+	}
+	if active, err := sessions.IsSessionActive(sessionID, user.ID); err != nil || active {
+		t.Errorf("session active = %v, %v", active, err)
+	}
+	if _, err := tokens.Refresh(pair.RefreshToken, ""); err == nil {
+		t.Error("refresh token still works")
+	}
+}
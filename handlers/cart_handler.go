@@ -19,6 +19,24 @@ func getUserIDFromContext(r *http.Request) uint {
 	return uint(val)
 }
 
+// variantIDFromQuery reads the optional ?variant_id= that picks a variant's
+// cart line. It writes a 400 and returns false when the value is invalid.
+func variantIDFromQuery(w http.ResponseWriter, r *http.Request) (*uint, bool) {
+	v := r.URL.Query().Get("variant_id")
+	if v == "" {
+		return nil, true
+	}
+
+	id, err := strconv.ParseUint(v, 10, 64)
+	if err != nil || id == 0 {
+		http.Error(w, "invalid variant_id", 400)
+		return nil, false
+	}
+
+	variantID := uint(id)
+	return &variantID, true
+}
+
 // ------------------------------------------------------------
 // GET CART
 // ------------------------------------------------------------
@@ -41,7 +59,8 @@ func (h *CartHandler) AddToCart(w http.ResponseWriter, r *http.Request) {
 	userID := getUserIDFromContext(r)
 
 	var body struct {
-		ProductID uint `json:"product_id"`
+		ProductID uint  `json:"product_id"`
+		VariantID *uint `json:"variant_id"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
@@ -49,7 +68,7 @@ func (h *CartHandler) AddToCart(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err := h.Service.AddToCart(userID, body.ProductID)
+	err := h.Service.AddToCart(userID, body.ProductID, body.VariantID)
 	if err != nil {
 		http.Error(w, err.Error(), 400)
 		return
@@ -67,13 +86,18 @@ func (h *CartHandler) UpdateCartItem(w http.ResponseWriter, r *http.Request) {
 
 	pid, _ := strconv.Atoi(mux.Vars(r)["product_id"])
 
+	variantID, ok := variantIDFromQuery(w, r)
+	if !ok {
+		return
+	}
+
 	var body struct {
 		Quantity int `json:"quantity"`
 	}
 
 	json.NewDecoder(r.Body).Decode(&body)
 
-	err := h.Service.UpdateQuantity(userID, uint(pid), body.Quantity)
+	err := h.Service.UpdateQuantity(userID, uint(pid), variantID, body.Quantity)
 	if err != nil {
 		http.Error(w, err.Error(), 400)
 		return
@@ -89,7 +113,12 @@ func (h *CartHandler) RemoveCartItem(w http.ResponseWriter, r *http.Request) {
 	userID := getUserIDFromContext(r)
 	pid, _ := strconv.Atoi(mux.Vars(r)["product_id"])
 
-	err := h.Service.RemoveItem(userID, uint(pid))
+	variantID, ok := variantIDFromQuery(w, r)
+	if !ok {
+		return
+	}
+
+	err := h.Service.RemoveItem(userID, uint(pid), variantID)
 	if err != nil {
 		http.Error(w, err.Error(), 400)
 		return
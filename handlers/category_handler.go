@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"futuremarket/service"
+)
+
+// CategoryHandler serves the category tree and its admin management.
+type CategoryHandler struct {
+	Service service.CategoryService
+	Audit   service.AuditService
+}
+
+// writeCategoryError maps category errors onto HTTP status codes.
+func writeCategoryError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrCategoryNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrCategorySlugTaken), errors.Is(err, service.ErrCategoryInUse),
+		errors.Is(err, service.ErrCategoryMove):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidCategory), errors.Is(err, service.ErrCategoryCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to save category", http.StatusInternalServerError)
+	}
+}
+
+// -----------------------------------------------
+// GET /api/v1/categories
+// -----------------------------------------------
+// Returns the whole tree; each node lists its children in display order.
+func (h *CategoryHandler) GetTree(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.Service.Tree()
+	if err != nil {
+		http.Error(w, "failed to load categories", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"categories": tree})
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/categories
+// -----------------------------------------------
+// Body: {"name", "slug" (optional), "parent_id" (optional), "position"}
+func (h *CategoryHandler) CreateCategory(w http.ResponseWriter, r *http.Request) {
+	var req service.CategoryInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	category, err := h.Service.CreateCategory(req)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "category.create", "category", strconv.Itoa(int(category.ID)))
+	entry.After = category
+	h.Audit.Log(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(category)
+}
+
+// -----------------------------------------------
+// PATCH /api/v1/admin/categories/{id}
+// -----------------------------------------------
+// "parent_id": 0 moves the category to the top level.
+func (h *CategoryHandler) UpdateCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	var req service.CategoryInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	before, updated, err := h.Service.UpdateCategory(id, req)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "category.update", "category", strconv.Itoa(int(id)))
+	entry.Before = before
+	entry.After = updated
+	h.Audit.Log(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/admin/categories/{id}
+// -----------------------------------------------
+// Only empty categories (no subcategories, no products) can be deleted.
+func (h *CategoryHandler) DeleteCategory(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid category id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.Service.DeleteCategory(id)
+	if err != nil {
+		writeCategoryError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "category.delete", "category", strconv.Itoa(int(id)))
+	entry.Before = deleted
+	h.Audit.Log(entry)
+
+	w.WriteHeader(http.StatusNoContent)
+}
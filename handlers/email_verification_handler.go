@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"futuremarket/middleware"
+	"futuremarket/service"
+)
+
+// EmailVerificationHandler handles verifying and re-sending email links
+type EmailVerificationHandler struct {
+	Service service.EmailVerificationService
+}
+
+// -----------------------------------------------
+// POST /api/v1/email/verify
+// -----------------------------------------------
+func (h *EmailVerificationHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" {
+		http.Error(w, "token required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Verify(req.Token); err != nil {
+		if errors.Is(err, service.ErrInvalidVerificationToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if errors.Is(err, service.ErrEmailTaken) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		http.Error(w, "failed to verify email", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "email verified",
+	})
+}
+
+// -----------------------------------------------
+// POST /api/v1/email/verify/resend   (AUTH REQUIRED)
+// -----------------------------------------------
+func (h *EmailVerificationHandler) Resend(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	err := h.Service.Resend(userID)
+	if err != nil {
+		var throttled service.ThrottledError
+		switch {
+		case errors.As(err, &throttled):
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		case errors.Is(err, service.ErrEmailAlreadyVerified):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, "failed to send verification email", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "verification email sent",
+	})
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"futuremarket/jwtkeys"
+)
+
+// JWKSHandler publishes the public signing keys so other services can
+// verify access tokens without sharing a secret.
+type JWKSHandler struct {
+	Keys *jwtkeys.Keyset
+}
+
+// -----------------------------------------------
+// GET /.well-known/jwks.json
+// -----------------------------------------------
+func (h *JWKSHandler) JWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(h.Keys.JWKS())
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"futuremarket/config"
+	"futuremarket/oidc"
+	"futuremarket/service"
+	"futuremarket/utils"
+)
+
+// OIDCHandler signs users in through an external OpenID Connect provider.
+// After the provider comes back the login continues exactly like a
+// password login (2FA challenge or token pair).
+type OIDCHandler struct {
+	Service service.OIDCService
+	Auth    *AuthHandler
+}
+
+// oidcStateCookie ties a started login to the browser that started it, so
+// an attacker can't have a victim complete the attacker's login (login
+// CSRF) by sending them a callback URL.
+const oidcStateCookie = "oidc_state"
+
+func (h *OIDCHandler) stateCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcStateCookie,
+		Value:    value,
+		Path:     "/api/v1/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.Service.Client.Config.RedirectURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// -----------------------------------------------
+// GET /api/v1/oidc/login?device_name=&redirect=false
+// -----------------------------------------------
+func (h *OIDCHandler) Login(w http.ResponseWriter, r *http.Request) {
+	authURL, state, err := h.Service.Begin(r.Context(), r.URL.Query().Get("device_name"))
+	if err != nil {
+		log.Printf("failed to start oidc login: %v", err)
+		http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		return
+	}
+
+	http.SetCookie(w, h.stateCookie(state, int(h.Service.Client.Config.StateTTL.Seconds())))
+
+	// SPAs can ask for the URL instead of following a redirect.
+	if r.URL.Query().Get("redirect") == "false" {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"authorization_url": authURL,
+		})
+		return
+	}
+
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// -----------------------------------------------
+// GET /api/v1/oidc/callback?state=&code=
+// -----------------------------------------------
+func (h *OIDCHandler) Callback(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	// Only the browser that started the login may finish it
+	cookie, err := r.Cookie(oidcStateCookie)
+	http.SetCookie(w, h.stateCookie("", -1))
+	if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(q.Get("state"))) != 1 {
+		http.Error(w, "sign-in failed, please start again", http.StatusUnauthorized)
+		return
+	}
+
+	// The provider reports refusals (e.g. access_denied) as query params.
+	if providerErr := q.Get("error"); providerErr != "" {
+		msg := "sign-in failed: " + providerErr
+		if desc := q.Get("error_description"); desc != "" {
+			msg += " (" + desc + ")"
+		}
+		http.Error(w, msg, http.StatusUnauthorized)
+		return
+	}
+
+	ip := utils.ClientIP(r, config.TrustProxyHeaders())
+
+	login, err := h.Service.Complete(r.Context(), q.Get("state"), q.Get("code"), ip)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrInvalidOIDCState),
+			errors.Is(err, oidc.ErrInvalidToken),
+			errors.Is(err, oidc.ErrTokenExchange):
+			http.Error(w, "sign-in failed, please start again", http.StatusUnauthorized)
+		case errors.Is(err, service.ErrAccountDisabled):
+			http.Error(w, err.Error(), http.StatusForbidden)
+		case errors.Is(err, service.ErrOIDCEmailRequired),
+			errors.Is(err, service.ErrOIDCEmailUnverified),
+			errors.Is(err, service.ErrOIDCLinkPrivileged):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, oidc.ErrDiscovery):
+			http.Error(w, "identity provider unavailable", http.StatusBadGateway)
+		default:
+			http.Error(w, "server error", http.StatusInternalServerError)
+		}
+		if !errors.Is(err, service.ErrInvalidOIDCState) {
+			log.Printf("oidc callback failed: %v", err)
+		}
+		return
+	}
+
+	h.Auth.continueLogin(w, r, login.User, login.DeviceName)
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/oidc"
+	"futuremarket/repository"
+	"futuremarket/service"
+)
+
+func TestOIDCStateCookie(t *testing.T) {
+	// Discovery is all the login step needs from the provider
+	var provider *httptest.Server
+	provider = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 provider.URL,
+			"authorization_endpoint": provider.URL + "/authorize",
+			"token_endpoint":         provider.URL + "/token",
+			"jwks_uri":               provider.URL + "/jwks",
+		})
+	}))
+	defer provider.Close()
+
+	db := newTestDB(t, &models.OIDCState{})
+	h := &OIDCHandler{Service: service.OIDCService{
+		Client: oidc.NewClient(config.OIDC{
+			Issuer:      provider.URL,
+			ClientID:    "client",
+			RedirectURL: "https://shop.example.com/api/v1/oidc/callback",
+			StateTTL:    time.Minute,
+		}, provider.Client()),
+		States: repository.OIDCStateRepo{DB: db},
+	}}
+
+	w := httptest.NewRecorder()
+	h.Login(w, httptest.NewRequest(http.MethodGet, "/api/v1/oidc/login", nil))
+	if w.Code != http.StatusFound {
+		t.Fatalf("login: status %d: %s", w.Code, w.Body)
+	}
+	authURL, _ := url.Parse(w.Header().Get("Location"))
+	state := authURL.Query().Get("state")
+
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == oidcStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || cookie.Value != state || !cookie.HttpOnly || !cookie.Secure || cookie.MaxAge != 60 {
+		t.Fatalf("state cookie = %+v, want an HttpOnly, Secure cookie holding %q", cookie, state)
+	}
+
+	// The callback URL alone, in a browser that didn't start the login
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{"no cookie", ""},
+		{"another login's cookie", "someone-elses-state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/oidc/callback?code=code&state="+url.QueryEscape(state), nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: oidcStateCookie, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			h.Callback(w, r)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("status %d, want 401", w.Code)
+			}
+			var count int64
+			db.Model(&models.OIDCState{}).Count(&count)
+			if count != 1 {
+				t.Error("the started login was used up")
+			}
+		})
+	}
+}
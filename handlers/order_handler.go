@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"futuremarket/middleware"
+	"futuremarket/pagination"
 	"futuremarket/service"
 	"net/http"
-	"strconv"
 )
 
 type OrderHandler struct {
@@ -20,6 +21,10 @@ func (h *OrderHandler) Checkout(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 
 	if err := h.Service.Checkout(userID); err != nil {
+		if errors.Is(err, service.ErrEmailNotVerified) {
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
 		http.Error(w, err.Error(), 400)
 		return
 	}
@@ -41,41 +46,45 @@ func (h *OrderHandler) ListOrders(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(orders)
 }
 
-// GET /api/v1/orders/paginated?page=1&limit=10
+// GET /api/v1/orders/paginated?page=1&limit=10 (or ?cursor=...&count=false)
 func (h *OrderHandler) ListOrdersPaginated(w http.ResponseWriter, r *http.Request) {
 	userID := getUserID(r)
 
 	// Read query params
-	page := 1
-	limit := 20
-
-	if p := r.URL.Query().Get("page"); p != "" {
-		if val, err := strconv.Atoi(p); err == nil && val > 0 {
-			page = val
-		}
-	}
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if val, err := strconv.Atoi(l); err == nil && val > 0 {
-			limit = val
-		}
-	}
+	params := parsePageParams(r, 20)
 
 	// Call service
-	orders, total, err := h.Service.ListOrdersPaginated(userID, page, limit)
+	orders, meta, err := h.Service.ListOrdersPaginated(userID, params)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "failed to load orders", http.StatusInternalServerError)
 		return
 	}
 
-	// Build response
+	// Build response (keeps the original "page" key)
+	metaOut := map[string]any{
+		"limit": meta.Limit,
+	}
+	if meta.TotalItems != nil {
+		metaOut["total_items"] = *meta.TotalItems
+		metaOut["total_pages"] = *meta.TotalPages
+	}
+	if meta.CurrentPage > 0 {
+		metaOut["page"] = meta.CurrentPage
+	}
+	if meta.NextCursor != "" {
+		metaOut["next_cursor"] = meta.NextCursor
+	}
+	if meta.PrevCursor != "" {
+		metaOut["prev_cursor"] = meta.PrevCursor
+	}
+
 	response := map[string]any{
 		"orders": orders,
-		"meta": map[string]any{
-			"total_items": total,
-			"total_pages": (total + int64(limit) - 1) / int64(limit),
-			"page":        page,
-			"limit":       limit,
-		},
+		"meta":   metaOut,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"futuremarket/service"
+)
+
+// parsePageParams reads ?page=&limit=&cursor=&count= from a list request.
+// count=false skips the total; the limit is capped by the service.
+func parsePageParams(r *http.Request, defaultLimit int) service.PageParams {
+	params := service.PageParams{
+		Page:   parseQueryInt(r, "page", 1),
+		Limit:  parseQueryInt(r, "limit", defaultLimit),
+		Cursor: r.URL.Query().Get("cursor"),
+	}
+
+	if v := r.URL.Query().Get("count"); v != "" {
+		if count, err := strconv.ParseBool(v); err == nil {
+			params.SkipCount = !count
+		}
+	}
+
+	return params
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"futuremarket/config"
+	"futuremarket/service"
+	"futuremarket/utils"
+)
+
+// PasswordHandler handles the forgot/reset password flow
+type PasswordHandler struct {
+	Service service.PasswordResetService
+
+	// Guard limits reset emails per address and per IP
+	Guard service.LoginGuard
+}
+
+// -----------------------------------------------
+// POST /api/v1/password/forgot
+// -----------------------------------------------
+func (h *PasswordHandler) Forgot(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if err := service.ValidateEmail(req.Email); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Every request counts, known address or not, so the answer gives
+	// nothing away and nobody can flood an inbox with reset links
+	ip := utils.ClientIP(r, config.TrustProxyHeaders())
+	if !guardAllows(w, h.Guard, req.Email, ip) {
+		return
+	}
+	if err := h.Guard.RecordFailure(req.Email, ip); err != nil {
+		log.Printf("failed to record password reset request for %s: %v", req.Email, err)
+	}
+
+	if err := h.Service.RequestReset(req.Email); err != nil {
+		http.Error(w, "failed to send reset email", http.StatusInternalServerError)
+		return
+	}
+
+	// Same answer whether or not the account exists
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "if that email is registered, a reset link has been sent",
+	})
+}
+
+// -----------------------------------------------
+// POST /api/v1/password/reset
+// -----------------------------------------------
+func (h *PasswordHandler) Reset(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	if req.Token == "" {
+		http.Error(w, "token required", http.StatusBadRequest)
+		return
+	}
+	if err := service.ValidatePassword(req.Password); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	err := h.Service.ResetPassword(req.Token, req.Password)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidResetToken) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "failed to reset password", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "password has been reset",
+	})
+}
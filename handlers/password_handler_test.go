@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/mailer"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/service"
+)
+
+// recordingSender keeps every message instead of sending it.
+type recordingSender struct {
+	sent []mailer.Message
+}
+
+func (s *recordingSender) Send(msg mailer.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+func TestForgotIsThrottled(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.PasswordResetToken{}, &models.AuditEvent{})
+	db.Create(&models.User{Name: "Known", Email: "known@example.com", Role: "customer"})
+
+	mail := &recordingSender{}
+	h := &PasswordHandler{
+		Service: service.PasswordResetService{
+			Repo:     repository.PasswordResetRepo{DB: db},
+			UserRepo: repository.UserRepo{DB: db},
+			Mailer:   mail,
+		},
+		Guard: service.LoginGuard{
+			Store: service.NewMemoryAttemptStore(),
+			Audit: service.AuditService{Repo: repository.AuditRepo{DB: db}},
+			Settings: config.LoginThrottle{
+				MaxAccountFailures: 3,
+				MaxIPFailures:      10,
+				LockoutDuration:    time.Hour,
+				Window:             time.Hour,
+			},
+			Scope: "password_reset",
+		},
+	}
+
+	forgot := func(email, ip string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/password/forgot", strings.NewReader(`{"email":"`+email+`"}`))
+		r.RemoteAddr = ip + ":1234"
+		w := httptest.NewRecorder()
+		h.Forgot(w, r)
+		return w
+	}
+
+	for i := 0; i < 3; i++ {
+		if w := forgot("known@example.com", "10.0.0.1"); w.Code != http.StatusAccepted {
+			t.Fatalf("request %d: status = %d, want 202", i+1, w.Code)
+		}
+	}
+
+	// The address is now locked, whichever IP asks
+	w := forgot("known@example.com", "10.0.0.2")
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("4th request: status = %d, Retry-After = %q, want 429 with Retry-After", w.Code, w.Header().Get("Retry-After"))
+	}
+	if len(mail.sent) != 3 {
+		t.Errorf("sent %d emails, want 3", len(mail.sent))
+	}
+
+	// Unknown addresses are throttled the same way, so 429s leak nothing
+	for i := 0; i < 3; i++ {
+		forgot("nobody@example.com", "10.0.0.3")
+	}
+	if w := forgot("nobody@example.com", "10.0.0.4"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("unknown address: status = %d, want 429", w.Code)
+	}
+
+	// One IP can't spray many addresses either
+	for i := 0; i < 10; i++ {
+		forgot("spray"+string(rune('a'+i))+"@example.com", "10.0.0.5")
+	}
+	if w := forgot("other@example.com", "10.0.0.5"); w.Code != http.StatusTooManyRequests {
+		t.Errorf("IP over its limit: status = %d, want 429", w.Code)
+	}
+}
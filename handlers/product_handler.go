@@ -35,11 +35,19 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"futuremarket/config"
 	"futuremarket/service"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"github.com/gorilla/mux"
 	"futuremarket/models"
+	"futuremarket/pagination"
+	"futuremarket/repository"
+	"gorm.io/gorm"
 
 
 	
@@ -47,46 +55,75 @@ import (
 
 // ProductHandler manages product listing, search and admin product management.
 type ProductHandler struct {
-	Service service.ProductService
+	Service  service.ProductService
+	Variants service.VariantService
+	Images   service.ProductImageService
+	Audit    service.AuditService
 }
 
 // GET /api/v1/products
 func (h *ProductHandler) ListProducts(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
 
-	// Pagination
-	page := 1
-	limit := 20
-
-	if p, err := strconv.Atoi(q.Get("page")); err == nil && p > 0 {
-		page = p
-	}
-	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
-		limit = l
-	}
+	// Pagination: page/limit or a cursor from the previous response
+	params := parsePageParams(r, 20)
 
 	// Filters
-	var minPricePtr, maxPricePtr *int64
+	filter := repository.ProductFilter{
+		Query: q.Get("q"),
+		Sort:  q.Get("sort"),
+	}
 	if v := q.Get("min_price"); v != "" {
 		if val, err := strconv.ParseInt(v, 10, 64); err == nil {
-			minPricePtr = &val
+			filter.MinPrice = &val
 		}
 	}
 	if v := q.Get("max_price"); v != "" {
 		if val, err := strconv.ParseInt(v, 10, 64); err == nil {
-			maxPricePtr = &val
+			filter.MaxPrice = &val
+		}
+	}
+
+	if v := q.Get("min_rating"); v != "" {
+		if val, err := strconv.ParseFloat(v, 64); err == nil {
+			filter.MinRating = &val
 		}
 	}
 
 	category := q.Get("category")
-	var categoryPtr *string
 	if category != "" {
-		categoryPtr = &category
+		filter.Category = &category
+	}
+
+	// Custom attributes: ?attr.brand=Sony&attr.screen_in[gte]=50
+	filter.Attributes = parseAttributeFilters(q)
+
+	// Facets: ?facets=category,price,rating&price_buckets=2500,5000
+	var facets service.FacetRequest
+	if v := q.Get("facets"); v != "" {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				facets.Names = append(facets.Names, name)
+			}
+		}
+	}
+	if v := q.Get("price_buckets"); v != "" {
+		bounds, err := config.ParsePriceBuckets(v)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		facets.PriceBuckets = bounds
 	}
 
 	// Call service
-	result, err := h.Service.ListProductsWithFilters(page, limit, minPricePtr, maxPricePtr, categoryPtr)
+	result, err := h.Service.ListProductsWithFilters(params, filter, facets)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidSort) || errors.Is(err, service.ErrInvalidFacet) ||
+			errors.Is(err, service.ErrInvalidAttributeQuery) || errors.Is(err, pagination.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "failed to fetch products", http.StatusInternalServerError)
 		return
 	}
@@ -106,12 +143,18 @@ func (h *ProductHandler) GetProductByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	product, err := h.Service.GetProductByID(uint(id))
+	// The product's fields plus the variants it can be bought in
+	product, err := h.Variants.GetProductDetail(uint(id))
 	if err != nil {
 		http.Error(w, "product not found", http.StatusNotFound)
 		return
 	}
 
+	if product.Images, err = h.Images.ListImages(uint(id)); err != nil {
+		http.Error(w, "failed to load product images", http.StatusInternalServerError)
+		return
+	}
+
 	json.NewEncoder(w).Encode(product)
 }
 
@@ -119,12 +162,16 @@ func (h *ProductHandler) GetProductByID(w http.ResponseWriter, r *http.Request)
 // POST /api/v1/admin/products  (via admin routes)
 func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	var req struct {
+		SKU         string `json:"sku"` // optional external ID, unique
 		Name        string `json:"name"`
 		Description string `json:"description"`
-		Category    string `json:"category"`
+		Category    string `json:"category"`    // slug or name of an existing category
+		CategoryID  *uint  `json:"category_id"` // wins over category
 		PriceCents  int64  `json:"price_cents"`
 		Stock       int64  `json:"stock"` 
 		ImageURL    string `json:"image_url"`
+		Attributes  map[string]json.RawMessage `json:"attributes"` // keyed by attribute key
+		service.ProductStatusInput                             // status, publish_at, unpublish_at
 	}
 
 	// Parse request body
@@ -141,9 +188,11 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 
 	// Build product model
 	product := models.Product{
+		SKU:         req.SKU,
 		Name:        req.Name,
 		Description: req.Description,
 		Category:    req.Category,
+		CategoryID:  req.CategoryID,
 		PriceCents:  req.PriceCents,
 		Stock:		 req.Stock,
 		ImageURL:    req.ImageURL,
@@ -151,12 +200,25 @@ func (h *ProductHandler) CreateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Call service layer
-	err := h.Service.CreateProduct(&product)
+	err := h.Service.CreateProduct(&product, req.Attributes, req.ProductStatusInput)
 	if err != nil {
+		if errors.Is(err, service.ErrDuplicateSKU) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrUnknownCategory) || errors.Is(err, service.ErrInvalidAttributeValue) ||
+			errors.Is(err, service.ErrInvalidProductStatus) || errors.Is(err, service.ErrInvalidProduct) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "failed to create product", http.StatusInternalServerError)
 		return
 	}
 
+	entry := auditEntry(r, "product.create", "product", strconv.Itoa(int(product.ID)))
+	entry.After = product
+	h.Audit.Log(entry)
+
 	// Success response
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(product)
@@ -172,22 +234,107 @@ func (h *ProductHandler) UpdateProduct(w http.ResponseWriter, r *http.Request) {
 	}
 
 
-	var req models.Product
+	var req struct {
+		models.Product
+		CategoryID *uint                      `json:"category_id"`
+		Attributes map[string]json.RawMessage `json:"attributes"` // null removes a value
+		service.ProductStatusInput
+	}
 
 	// Parse request body
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "invalid JSON", http.StatusBadRequest)
 		return
 	}
+	if req.CategoryID != nil {
+		req.Product.CategoryID = req.CategoryID
+	}
+
+	before, err := h.Service.GetProductByID(uint(id))
+	if err != nil {
+		http.Error(w, "product not found", http.StatusNotFound)
+		return
+	}
 
 	// Call service to update only provided fields
-	updated, err := h.Service.UpdateProduct(uint(id), &req)
+	updated, err := h.Service.UpdateProduct(uint(id), &req.Product, req.Attributes, req.ProductStatusInput)
 	if err != nil {
+		if errors.Is(err, service.ErrDuplicateSKU) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		if errors.Is(err, service.ErrUnknownCategory) || errors.Is(err, service.ErrInvalidAttributeValue) ||
+			errors.Is(err, service.ErrInvalidProductStatus) || errors.Is(err, service.ErrInvalidProduct) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
+	entry := auditEntry(r, "product.update", "product", strconv.Itoa(id))
+	entry.Before = before
+	entry.After = updated
+	h.Audit.Log(entry)
+
+	// Stock changes get their own event so inventory history is easy to query
+	if before.Stock != updated.Stock {
+		entry := auditEntry(r, "product.stock_change", "product", strconv.Itoa(id))
+		entry.Before = map[string]int64{"stock": before.Stock}
+		entry.After = map[string]int64{"stock": updated.Stock}
+		h.Audit.Log(entry)
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(updated)
 }
 
+// DELETE /api/v1/admin/products/{id}
+// Archives the product rather than deleting it, so orders keep showing it.
+// PATCH "status": "active" brings it back.
+func (h *ProductHandler) ArchiveProduct(w http.ResponseWriter, r *http.Request) {
+	id, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid product id", http.StatusBadRequest)
+		return
+	}
+
+	before, archived, err := h.Service.ArchiveProduct(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "product not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to archive product", http.StatusInternalServerError)
+		return
+	}
+
+	entry := auditEntry(r, "product.archive", "product", strconv.Itoa(int(id)))
+	entry.Before = map[string]string{"status": before.Status}
+	entry.After = map[string]string{"status": archived.Status}
+	h.Audit.Log(entry)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// parseAttributeFilters reads attr.<key>=value and attr.<key>[op]=value
+// query parameters, sorted by name so the generated SQL is stable.
+func parseAttributeFilters(q url.Values) []repository.AttributeFilter {
+	var names []string
+	for name := range q {
+		if strings.HasPrefix(name, "attr.") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var filters []repository.AttributeFilter
+	for _, name := range names {
+		key, op := strings.TrimPrefix(name, "attr."), repository.AttributeEq
+		if i := strings.IndexByte(key, '['); i >= 0 && strings.HasSuffix(key, "]") {
+			key, op = key[:i], key[i+1:len(key)-1]
+		}
+		filters = append(filters, repository.AttributeFilter{Key: key, Op: op, Raw: q[name]})
+	}
+	return filters
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"futuremarket/repository"
+)
+
+func TestParseAttributeFilters(t *testing.T) {
+	q, err := url.ParseQuery("attr.screen_in[gte]=50&attr.brand=Sony&attr.brand=LG&attr.screen_in[lt]=70&attr.x[=1&page=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []repository.AttributeFilter{
+		{Key: "brand", Op: "eq", Raw: []string{"Sony", "LG"}},
+		{Key: "screen_in", Op: "gte", Raw: []string{"50"}},
+		{Key: "screen_in", Op: "lt", Raw: []string{"70"}},
+		// No closing bracket: the whole name is the key, which is unknown
+		{Key: "x[", Op: "eq", Raw: []string{"1"}},
+	}
+	if got := parseAttributeFilters(q); !reflect.DeepEqual(got, want) {
+		t.Errorf("filters = %+v\nwant      %+v", got, want)
+	}
+}
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"futuremarket/service"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// writeImageError maps product image errors onto HTTP status codes.
+func writeImageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "product not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrImageNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrImageTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.Is(err, service.ErrUnsupportedImage):
+		http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, service.ErrTooManyImages):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidImageOrder):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to save image", http.StatusInternalServerError)
+	}
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/products/{id}/images
+// -----------------------------------------------
+// multipart/form-data with one or more file parts (e.g. "images"). Files
+// are added after the product's existing images, in request order.
+func (h *ProductHandler) UploadImages(w http.ResponseWriter, r *http.Request) {
+	productID, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid product id", http.StatusBadRequest)
+		return
+	}
+
+	// Room for every allowed file plus the multipart framing
+	maxFile := h.Images.MaxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxFile*int64(h.Images.MaxImages)+1<<20)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "expected multipart/form-data", http.StatusBadRequest)
+		return
+	}
+
+	var files []service.ImageUpload
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			var tooBig *http.MaxBytesError
+			if errors.As(err, &tooBig) {
+				http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		if part.FileName() == "" {
+			continue // plain form fields carry nothing we need
+		}
+
+		// Read one byte past the limit so the service can reject the file
+		data, err := io.ReadAll(io.LimitReader(part, maxFile+1))
+		if err != nil {
+			http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if int64(len(data)) > maxFile {
+			writeImageError(w, fmt.Errorf("%w: %q is over %d bytes", service.ErrImageTooLarge, part.FileName(), maxFile))
+			return
+		}
+		files = append(files, service.ImageUpload{Filename: part.FileName(), Data: data})
+	}
+
+	if len(files) == 0 {
+		http.Error(w, "no image files in the request", http.StatusBadRequest)
+		return
+	}
+
+	images, err := h.Images.Upload(r.Context(), productID, files)
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+
+	for _, img := range images {
+		entry := auditEntry(r, "product.image_upload", "product_image", strconv.Itoa(int(img.ID)))
+		entry.After = img
+		entry.Details = map[string]uint{"product_id": productID}
+		h.Audit.Log(entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]any{"images": images})
+}
+
+// -----------------------------------------------
+// PUT /api/v1/admin/products/{id}/images/order
+// -----------------------------------------------
+// Body: {"image_ids": [3, 1, 2]} listing every image of the product. The
+// first one becomes the product's image_url.
+func (h *ProductHandler) ReorderImages(w http.ResponseWriter, r *http.Request) {
+	productID, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid product id", http.StatusBadRequest)
+		return
+	}
+
+	var req struct {
+		ImageIDs []uint `json:"image_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	before, err := h.Images.ListImages(productID)
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+
+	images, err := h.Images.Reorder(productID, req.ImageIDs)
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "product.image_reorder", "product", strconv.Itoa(int(productID)))
+	entry.Before = before
+	entry.After = images
+	h.Audit.Log(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"images": images})
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/admin/products/{id}/images/{image_id}
+// -----------------------------------------------
+func (h *ProductHandler) DeleteImage(w http.ResponseWriter, r *http.Request) {
+	productID, ok := parseIDVar(r)
+	imageID, err := strconv.Atoi(mux.Vars(r)["image_id"])
+	if !ok || err != nil || imageID < 1 {
+		http.Error(w, "invalid product or image id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.Images.DeleteImage(r.Context(), productID, uint(imageID))
+	if err != nil {
+		writeImageError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "product.image_delete", "product_image", strconv.Itoa(imageID))
+	entry.Before = deleted
+	entry.Details = map[string]uint{"product_id": productID}
+	h.Audit.Log(entry)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/service"
+)
+
+// importFormat picks the file format from ?format= or the Content-Type.
+func importFormat(r *http.Request) string {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "text/csv":
+		return service.FormatCSV
+	case "application/x-ndjson", "application/jsonl", "application/json-lines":
+		return service.FormatNDJSON
+	}
+	return ""
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/products/import?format=csv|ndjson&dry_run=&chunk_size=
+// -----------------------------------------------
+// The body is the file. Products are matched by sku. Without chunk_size
+// the import is all-or-nothing: one invalid row and nothing is saved
+// (422). With chunk_size=N every N rows commit on their own and invalid
+// rows are skipped. Either way the response lists each failed row.
+func (h *ProductHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	opts := service.ImportOptions{Format: importFormat(r)}
+	if v := q.Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+			return
+		}
+		opts.DryRun = dryRun
+	}
+	if v := q.Get("chunk_size"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			http.Error(w, "chunk_size must be a positive number", http.StatusBadRequest)
+			return
+		}
+		opts.ChunkSize = n
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, int64(config.GetInt("PRODUCT_IMPORT_MAX_BYTES", 32<<20)))
+
+	result, err := h.Service.ImportProducts(r.Body, opts)
+	if err != nil {
+		var tooBig *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooBig):
+			http.Error(w, "import file too large", http.StatusRequestEntityTooLarge)
+		case errors.Is(err, service.ErrInvalidImport):
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		default:
+			log.Printf("product import failed after %d created, %d updated: %v", result.Created, result.Updated, err)
+			http.Error(w, "import failed", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if !opts.DryRun {
+		entry := auditEntry(r, "product.import", "product", "")
+		entry.Details = map[string]any{
+			"format":     opts.Format,
+			"chunk_size": opts.ChunkSize,
+			"rows":       result.Rows,
+			"created":    result.Created,
+			"updated":    result.Updated,
+			"failed":     result.Failed,
+			"committed":  result.Committed,
+		}
+		h.Audit.Log(entry)
+	}
+
+	status := http.StatusOK
+	if !opts.DryRun && !result.Committed {
+		status = http.StatusUnprocessableEntity
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(result)
+}
+
+// -----------------------------------------------
+// GET /api/v1/admin/products/export?format=csv|ndjson
+// -----------------------------------------------
+// Streams every product, whatever its status, in the import format.
+func (h *ProductHandler) ExportProducts(w http.ResponseWriter, r *http.Request) {
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = service.FormatCSV
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	switch format {
+	case service.FormatCSV:
+	case service.FormatNDJSON:
+		contentType = "application/x-ndjson"
+	default:
+		http.Error(w, "format must be csv or ndjson", http.StatusBadRequest)
+		return
+	}
+
+	h.Audit.Log(auditEntry(r, "product.export", "product", ""))
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="futuremarket-products-%s.%s"`, time.Now().Format("20060102"), format))
+	w.Header().Set("Cache-Control", "no-store")
+
+	// Headers are gone once the first batch is out, so a failure can only
+	// cut the file short
+	if err := h.Service.ExportProducts(flushWriter{w}, format); err != nil {
+		log.Printf("product export failed: %v", err)
+	}
+}
+
+// flushWriter sends every write to the client straight away, so large
+// exports stream instead of piling up in the response buffer.
+type flushWriter struct {
+	w http.ResponseWriter
+}
+
+func (f flushWriter) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	if err == nil {
+		http.NewResponseController(f.w).Flush()
+	}
+	return n, err
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"futuremarket/service"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// writeVariantError maps variant errors onto HTTP status codes.
+func writeVariantError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		http.Error(w, "product not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrVariantNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrDuplicateVariant):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, service.ErrInvalidVariant):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "failed to save variant", http.StatusInternalServerError)
+	}
+}
+
+// parseVariantPath reads {id} and {variant_id} from the URL.
+func parseVariantPath(r *http.Request) (uint, uint, bool) {
+	productID, ok := parseIDVar(r)
+	if !ok {
+		return 0, 0, false
+	}
+	variantID, err := strconv.Atoi(mux.Vars(r)["variant_id"])
+	if err != nil || variantID < 1 {
+		return 0, 0, false
+	}
+	return productID, uint(variantID), true
+}
+
+// -----------------------------------------------
+// POST /api/v1/admin/products/{id}/variants
+// -----------------------------------------------
+// Body: {"sku", "options": {"size": "42", "colour": "black"},
+// "price_cents" (optional override), "image_url", "stock"}
+func (h *ProductHandler) CreateVariant(w http.ResponseWriter, r *http.Request) {
+	productID, ok := parseIDVar(r)
+	if !ok {
+		http.Error(w, "invalid product id", http.StatusBadRequest)
+		return
+	}
+
+	var req service.VariantInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	variant, err := h.Variants.CreateVariant(productID, req)
+	if err != nil {
+		writeVariantError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "product.variant_create", "product_variant", strconv.Itoa(int(variant.ID)))
+	entry.After = variant
+	entry.Details = map[string]uint{"product_id": productID}
+	h.Audit.Log(entry)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(variant)
+}
+
+// -----------------------------------------------
+// PATCH /api/v1/admin/products/{id}/variants/{variant_id}
+// -----------------------------------------------
+// Only the fields sent are changed; "price_cents": 0 drops the override.
+func (h *ProductHandler) UpdateVariant(w http.ResponseWriter, r *http.Request) {
+	productID, variantID, ok := parseVariantPath(r)
+	if !ok {
+		http.Error(w, "invalid product or variant id", http.StatusBadRequest)
+		return
+	}
+
+	var req service.VariantInput
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid JSON", http.StatusBadRequest)
+		return
+	}
+
+	before, updated, err := h.Variants.UpdateVariant(productID, variantID, req)
+	if err != nil {
+		writeVariantError(w, err)
+		return
+	}
+
+	target := strconv.Itoa(int(variantID))
+
+	entry := auditEntry(r, "product.variant_update", "product_variant", target)
+	entry.Before = before
+	entry.After = updated
+	entry.Details = map[string]uint{"product_id": productID}
+	h.Audit.Log(entry)
+
+	// Stock changes get their own event, as for products
+	if before.Stock != updated.Stock {
+		entry := auditEntry(r, "product.stock_change", "product_variant", target)
+		entry.Before = map[string]int{"stock": before.Stock}
+		entry.After = map[string]int{"stock": updated.Stock}
+		h.Audit.Log(entry)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/admin/products/{id}/variants/{variant_id}
+// -----------------------------------------------
+func (h *ProductHandler) DeleteVariant(w http.ResponseWriter, r *http.Request) {
+	productID, variantID, ok := parseVariantPath(r)
+	if !ok {
+		http.Error(w, "invalid product or variant id", http.StatusBadRequest)
+		return
+	}
+
+	deleted, err := h.Variants.DeleteVariant(productID, variantID)
+	if err != nil {
+		writeVariantError(w, err)
+		return
+	}
+
+	entry := auditEntry(r, "product.variant_delete", "product_variant", strconv.Itoa(int(variantID)))
+	entry.Before = deleted
+	entry.Details = map[string]uint{"product_id": productID}
+	h.Audit.Log(entry)
+
+	w.WriteHeader(http.StatusNoContent)
+}
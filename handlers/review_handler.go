@@ -2,12 +2,14 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gorilla/mux"
 
 	"futuremarket/middleware"
+	"futuremarket/pagination"
 	"futuremarket/service"
 )
 
@@ -38,8 +40,7 @@ func parseQueryInt(r *http.Request, key string, defaultVal int) int {
 // -----------------------------------------------------------
 func (h *ReviewHandler) ListReviews(w http.ResponseWriter, r *http.Request) {
 
-	page := parseQueryInt(r, "page", 1)
-	limit := parseQueryInt(r, "limit", 10)
+	params := parsePageParams(r, 10)
 
 	idStr := mux.Vars(r)["id"]
 	productID, err := strconv.Atoi(idStr)
@@ -48,8 +49,12 @@ func (h *ReviewHandler) ListReviews(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := h.Service.ListReviewsPaginated(uint(productID), page, limit)
+	result, err := h.Service.ListReviewsPaginated(uint(productID), params)
 	if err != nil {
+		if errors.Is(err, pagination.ErrInvalidCursor) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "unable to load reviews", http.StatusInternalServerError)
 		return
 	}
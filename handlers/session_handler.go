@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"futuremarket/middleware"
+	"futuremarket/service"
+)
+
+// SessionHandler lets a user see and sign out their signed-in devices
+type SessionHandler struct {
+	Service service.SessionService
+}
+
+// -----------------------------------------------
+// GET /api/v1/me/sessions
+// -----------------------------------------------
+func (h *SessionHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	currentID, _ := middleware.GetSessionIDFromContext(r)
+
+	sessions, err := h.Service.ListSessions(userID, currentID)
+	if err != nil {
+		http.Error(w, "failed to load sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"sessions": sessions,
+	})
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/me/sessions/{id}
+// -----------------------------------------------
+func (h *SessionHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	sessionID, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil || sessionID < 1 {
+		http.Error(w, "invalid session id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.RevokeSession(userID, uint(sessionID)); err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		http.Error(w, "failed to revoke session", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// -----------------------------------------------
+// DELETE /api/v1/me/sessions   (sign out everywhere)
+// -----------------------------------------------
+func (h *SessionHandler) RevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.Service.RevokeAll(userID); err != nil {
+		http.Error(w, "failed to revoke sessions", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
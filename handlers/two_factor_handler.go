@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"futuremarket/middleware"
+	"futuremarket/service"
+)
+
+// TwoFactorHandler manages TOTP enrollment for the logged-in user
+type TwoFactorHandler struct {
+	Service service.TwoFactorService
+	Audit   service.AuditService
+}
+
+// writeTwoFactorError maps TwoFactorService errors onto HTTP status codes.
+func writeTwoFactorError(w http.ResponseWriter, err error) {
+	if writeBlocked(w, err) {
+		return
+	}
+
+	switch {
+	case errors.Is(err, service.ErrInvalidTwoFactorCode), errors.Is(err, service.ErrWrongPassword):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, service.ErrTwoFactorAlreadyOn),
+		errors.Is(err, service.ErrTwoFactorNotEnabled),
+		errors.Is(err, service.ErrTwoFactorNotEnrolling):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "two-factor request failed", http.StatusInternalServerError)
+	}
+}
+
+func (h *TwoFactorHandler) audit(r *http.Request, action string, userID uint) {
+	h.Audit.Log(auditEntry(r, action, "user", strconv.Itoa(int(userID))))
+}
+
+// -----------------------------------------------
+// POST /api/v1/me/2fa/enroll
+// -----------------------------------------------
+func (h *TwoFactorHandler) Enroll(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	enrollment, err := h.Service.BeginEnrollment(userID)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(enrollment)
+}
+
+// -----------------------------------------------
+// POST /api/v1/me/2fa/confirm
+// -----------------------------------------------
+func (h *TwoFactorHandler) Confirm(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Code == "" {
+		http.Error(w, "code required", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.Service.ConfirmEnrollment(userID, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	h.audit(r, "2fa.enable", userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"message":        "two-factor authentication enabled, log in again to use it",
+		"recovery_codes": codes,
+	})
+}
+
+// -----------------------------------------------
+// POST /api/v1/me/2fa/recovery-codes
+// -----------------------------------------------
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" || req.Code == "" {
+		http.Error(w, "password and code required", http.StatusBadRequest)
+		return
+	}
+
+	codes, err := h.Service.RegenerateRecoveryCodes(userID, req.Password, req.Code)
+	if err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	h.audit(r, "2fa.recovery_codes_regenerated", userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"recovery_codes": codes,
+	})
+}
+
+// -----------------------------------------------
+// POST /api/v1/me/2fa/disable
+// -----------------------------------------------
+func (h *TwoFactorHandler) Disable(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserIDFromContext(r)
+	if !ok {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Password == "" || req.Code == "" {
+		http.Error(w, "password and code required", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Service.Disable(userID, req.Password, req.Code); err != nil {
+		writeTwoFactorError(w, err)
+		return
+	}
+
+	h.audit(r, "2fa.disable", userID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"message": "two-factor authentication disabled",
+	})
+}
@@ -0,0 +1,176 @@
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrUnknownKey = errors.New("token signed with an unknown or retired key")
+
+// Key is one signing or verification key.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	// SignKey is the private key (or HMAC secret); nil for verify-only keys.
+	SignKey any
+	// VerifyKey is the public key (or HMAC secret).
+	VerifyKey any
+
+	// NotAfter ends the grace window of a retired key. Zero means no limit.
+	NotAfter time.Time
+}
+
+// Keyset signs tokens with its active key and accepts tokens signed by the
+// active key or by a previous key still inside its grace window, so keys
+// can be rotated without logging everybody out.
+type Keyset struct {
+	Active   *Key
+	Previous []*Key
+	Issuer   string
+	Audience string
+}
+
+// Sign adds the standard claims (iss, aud, iat, nbf) and a kid header and
+// signs with the active key.
+func (ks *Keyset) Sign(claims jwt.MapClaims) (string, error) {
+	now := time.Now()
+	claims["iss"] = ks.Issuer
+	claims["aud"] = ks.Audience
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+
+	token := jwt.NewWithClaims(ks.Active.Method, claims)
+	token.Header["kid"] = ks.Active.ID
+
+	return token.SignedString(ks.Active.SignKey)
+}
+
+// Parse verifies a token's signature and its exp, nbf, iat, iss and aud
+// claims, and returns the claims.
+func (ks *Keyset) Parse(tokenStr string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+
+	token, err := jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc,
+		jwt.WithIssuer(ks.Issuer),
+		jwt.WithAudience(ks.Audience),
+		jwt.WithIssuedAt(),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, jwt.ErrTokenSignatureInvalid
+	}
+
+	return claims, nil
+}
+
+// keyFunc picks the verification key by kid and refuses algorithm
+// mismatches, so an RSA public key can never be used as an HMAC secret.
+func (ks *Keyset) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+
+	for _, key := range ks.usableKeys(time.Now()) {
+		if kid != "" && kid != key.ID {
+			continue
+		}
+		if t.Method.Alg() != key.Method.Alg() {
+			continue
+		}
+		return key.VerifyKey, nil
+	}
+
+	return nil, ErrUnknownKey
+}
+
+// usableKeys returns the active key plus previous keys still in their grace
+// window.
+func (ks *Keyset) usableKeys(now time.Time) []*Key {
+	keys := []*Key{ks.Active}
+	for _, key := range ks.Previous {
+		if key.NotAfter.IsZero() || now.Before(key.NotAfter) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// JWK is one entry of a JSON Web Key Set (RFC 7517).
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519 (OKP)
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet is the document served at /.well-known/jwks.json.
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes the public half of every usable asymmetric key. HMAC
+// secrets are never published.
+func (ks *Keyset) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+
+	for _, key := range ks.usableKeys(time.Now()) {
+		switch pub := key.VerifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "RSA",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				Kty: "OKP",
+				Kid: key.ID,
+				Use: "sig",
+				Alg: key.Method.Alg(),
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+
+	return set
+}
+
+// deriveKeyID returns a short, stable identifier for a key when none is
+// configured.
+func deriveKeyID(prefix string, material []byte) string {
+	sum := sha256.Sum256(material)
+	return prefix + "-" + hex.EncodeToString(sum[:8])
+}
+
+// publicKeyOf returns the public half of a private key.
+func publicKeyOf(private any) (crypto.PublicKey, error) {
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported private key type %T", private)
+	}
+	return signer.Public(), nil
+}
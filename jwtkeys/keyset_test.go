@@ -0,0 +1,257 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func hmacKey(id, secret string) *Key {
+	return &Key{ID: id, Method: jwt.SigningMethodHS256, SignKey: []byte(secret), VerifyKey: []byte(secret)}
+}
+
+func ed25519Key(t *testing.T, id string) *Key {
+	t.Helper()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodEdDSA, SignKey: private, VerifyKey: public}
+}
+
+func rsaKey(t *testing.T, id string) *Key {
+	t.Helper()
+
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Key{ID: id, Method: jwt.SigningMethodRS256, SignKey: private, VerifyKey: &private.PublicKey}
+}
+
+func newKeyset(active *Key, previous ...*Key) *Keyset {
+	return &Keyset{Active: active, Previous: previous, Issuer: "futuremarket", Audience: "futuremarket-api"}
+}
+
+func expiring() jwt.MapClaims {
+	return jwt.MapClaims{"user_id": 1, "exp": time.Now().Add(time.Minute).Unix()}
+}
+
+func TestKeysetRotation(t *testing.T) {
+	old := ed25519Key(t, "old")
+	retired := ed25519Key(t, "retired")
+	unbounded := ed25519Key(t, "unbounded")
+
+	old.NotAfter = time.Now().Add(time.Hour)
+	retired.NotAfter = time.Now().Add(-time.Second)
+
+	before := map[string]*Keyset{
+		"old":       newKeyset(old),
+		"retired":   newKeyset(retired),
+		"unbounded": newKeyset(unbounded),
+	}
+	tokens := map[string]string{}
+	for name, ks := range before {
+		token, err := ks.Sign(expiring())
+		if err != nil {
+			t.Fatal(err)
+		}
+		tokens[name] = token
+	}
+
+	ks := newKeyset(ed25519Key(t, "new"), old, retired, unbounded)
+	fresh, err := ks.Sign(expiring())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"active key", fresh, false},
+		{"previous key in its grace window", tokens["old"], false},
+		{"previous key without a deadline", tokens["unbounded"], false},
+		{"previous key past its grace window", tokens["retired"], true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ks.Parse(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Parse err = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr && !errors.Is(err, ErrUnknownKey) {
+				t.Errorf("err = %v, want ErrUnknownKey", err)
+			}
+		})
+	}
+
+	// New tokens always use the active key
+	token, _, err := jwt.NewParser().ParseUnverified(fresh, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Header["kid"] != "new" || token.Method.Alg() != "EdDSA" {
+		t.Errorf("header = %v", token.Header)
+	}
+}
+
+func TestKeysetParseRejects(t *testing.T) {
+	active := rsaKey(t, "rsa")
+	ks := newKeyset(active, hmacKey("hs", "previous-secret-previous-secret"))
+
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		t.Helper()
+
+		token := jwt.NewWithClaims(method, claims)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+	standard := func(overrides jwt.MapClaims) jwt.MapClaims {
+		now := time.Now()
+		claims := jwt.MapClaims{
+			"iss": "futuremarket",
+			"aud": "futuremarket-api",
+			"iat": now.Unix(),
+			"exp": now.Add(time.Minute).Unix(),
+		}
+		for k, v := range overrides {
+			if v == nil {
+				delete(claims, k)
+			} else {
+				claims[k] = v
+			}
+		}
+		return claims
+	}
+
+	// The RSA public key as an HMAC secret: the classic algorithm confusion
+	publicDER, err := x509.MarshalPKIXPublicKey(active.VerifyKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER})
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"public key as HMAC secret", sign(jwt.SigningMethodHS256, "rsa", publicPEM, standard(nil))},
+		{"public key as HMAC secret, no kid", sign(jwt.SigningMethodHS256, "", publicPEM, standard(nil))},
+		{"unknown kid", sign(jwt.SigningMethodRS256, "other", active.SignKey, standard(nil))},
+		{"kid of another algorithm", sign(jwt.SigningMethodRS256, "hs", active.SignKey, standard(nil))},
+		{"unsigned", sign(jwt.SigningMethodNone, "rsa", jwt.UnsafeAllowNoneSignatureType, standard(nil))},
+		{"wrong issuer", sign(jwt.SigningMethodRS256, "rsa", active.SignKey, standard(jwt.MapClaims{"iss": "someone-else"}))},
+		{"wrong audience", sign(jwt.SigningMethodRS256, "rsa", active.SignKey, standard(jwt.MapClaims{"aud": "other-api"}))},
+		{"no exp", sign(jwt.SigningMethodRS256, "rsa", active.SignKey, standard(jwt.MapClaims{"exp": nil}))},
+		{"expired", sign(jwt.SigningMethodRS256, "rsa", active.SignKey, standard(jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}))},
+		{"issued in the future", sign(jwt.SigningMethodRS256, "rsa", active.SignKey, standard(jwt.MapClaims{"iat": time.Now().Add(time.Hour).Unix()}))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ks.Parse(tt.token); err == nil {
+				t.Error("token accepted")
+			}
+		})
+	}
+
+	// Sanity check: the same construction with the right key passes, and
+	// so does the previous HMAC key with or without its kid
+	for _, token := range []string{
+		sign(jwt.SigningMethodRS256, "rsa", active.SignKey, standard(nil)),
+		sign(jwt.SigningMethodHS256, "hs", []byte("previous-secret-previous-secret"), standard(nil)),
+		sign(jwt.SigningMethodHS256, "", []byte("previous-secret-previous-secret"), standard(nil)),
+	} {
+		if _, err := ks.Parse(token); err != nil {
+			t.Errorf("valid token rejected: %v", err)
+		}
+	}
+}
+
+func TestJWKSPublishesOnlyPublicKeys(t *testing.T) {
+	retired := ed25519Key(t, "retired")
+	retired.NotAfter = time.Now().Add(-time.Second)
+	ks := newKeyset(rsaKey(t, "rsa"), ed25519Key(t, "ed"), hmacKey("hs", "secret"), retired)
+
+	set := ks.JWKS()
+
+	got := map[string]JWK{}
+	for _, k := range set.Keys {
+		got[k.Kid] = k
+	}
+	if len(got) != 2 {
+		t.Fatalf("published keys = %+v, want rsa and ed only", set.Keys)
+	}
+	if k := got["rsa"]; k.Kty != "RSA" || k.Alg != "RS256" || k.N == "" || k.E != "AQAB" {
+		t.Errorf("rsa = %+v", k)
+	}
+	if k := got["ed"]; k.Kty != "OKP" || k.Alg != "EdDSA" || k.Crv != "Ed25519" || k.X == "" {
+		t.Errorf("ed = %+v", k)
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privatePEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+
+	t.Run("asymmetric with previous secret", func(t *testing.T) {
+		t.Setenv("JWT_ALG", "EdDSA")
+		t.Setenv("JWT_PRIVATE_KEY", privatePEM)
+		t.Setenv("JWT_PREVIOUS_SECRET", "previous-secret")
+		t.Setenv("JWT_PREVIOUS_KEY_EXPIRES_AT", "2030-01-02T03:04:05Z")
+
+		ks, err := LoadFromEnv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ks.Active.Method.Alg() != "EdDSA" || ks.Active.ID == "" {
+			t.Errorf("active = %+v", ks.Active)
+		}
+		if len(ks.Previous) != 1 || ks.Previous[0].Method.Alg() != "HS256" ||
+			!ks.Previous[0].NotAfter.Equal(time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)) {
+			t.Errorf("previous = %+v", ks.Previous)
+		}
+	})
+
+	t.Run("algorithm doesn't match the key", func(t *testing.T) {
+		t.Setenv("JWT_ALG", "RS256")
+		t.Setenv("JWT_PRIVATE_KEY", privatePEM)
+
+		if _, err := LoadFromEnv(); err == nil {
+			t.Error("RS256 with an Ed25519 key accepted")
+		}
+	})
+
+	t.Run("HMAC without a secret", func(t *testing.T) {
+		t.Setenv("JWT_ALG", "HS256")
+		t.Setenv("JWT_SECRET", "")
+
+		if _, err := LoadFromEnv(); err == nil {
+			t.Error("empty JWT_SECRET accepted")
+		}
+	})
+}
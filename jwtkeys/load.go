@@ -0,0 +1,194 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"futuremarket/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LoadFromEnv builds the keyset from the environment.
+//
+//	JWT_ALG                      HS256 (default), RS256 or EdDSA
+//	JWT_SECRET                   HMAC secret when JWT_ALG=HS256
+//	JWT_PRIVATE_KEY[_FILE]       PEM private key for RS256/EdDSA
+//	JWT_KEY_ID                   kid of the active key (derived if empty)
+//	JWT_PREVIOUS_PUBLIC_KEY[_FILE], JWT_PREVIOUS_KEY_ID
+//	                             retired asymmetric key still accepted
+//	JWT_PREVIOUS_SECRET          retired HMAC secret still accepted
+//	JWT_PREVIOUS_KEY_EXPIRES_AT  RFC 3339 end of the grace window
+//	                             (default: startup + access token TTL)
+//	JWT_ISSUER, JWT_AUDIENCE     iss / aud claims
+func LoadFromEnv() (*Keyset, error) {
+	ks := &Keyset{
+		Issuer:   config.GetEnv("JWT_ISSUER", "futuremarket"),
+		Audience: config.GetEnv("JWT_AUDIENCE", "futuremarket-api"),
+	}
+
+	active, err := loadActiveKey()
+	if err != nil {
+		return nil, err
+	}
+	ks.Active = active
+
+	graceEnd := time.Now().Add(config.AccessTokenTTL())
+	if v := os.Getenv("JWT_PREVIOUS_KEY_EXPIRES_AT"); v != "" {
+		graceEnd, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PREVIOUS_KEY_EXPIRES_AT: %w", err)
+		}
+	}
+
+	if pemData, err := readPEMEnv("JWT_PREVIOUS_PUBLIC_KEY"); err != nil {
+		return nil, err
+	} else if pemData != nil {
+		prev, err := publicKeyFromPEM(pemData)
+		if err != nil {
+			return nil, fmt.Errorf("JWT_PREVIOUS_PUBLIC_KEY: %w", err)
+		}
+		prev.ID = config.GetEnv("JWT_PREVIOUS_KEY_ID", prev.ID)
+		prev.NotAfter = graceEnd
+		ks.Previous = append(ks.Previous, prev)
+	}
+
+	if secret := os.Getenv("JWT_PREVIOUS_SECRET"); secret != "" {
+		ks.Previous = append(ks.Previous, &Key{
+			ID:        deriveKeyID("hs", []byte(secret)),
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   []byte(secret),
+			VerifyKey: []byte(secret),
+			NotAfter:  graceEnd,
+		})
+	}
+
+	return ks, nil
+}
+
+func loadActiveKey() (*Key, error) {
+	alg := config.GetEnv("JWT_ALG", "HS256")
+
+	if alg == "HS256" {
+		secret := os.Getenv("JWT_SECRET")
+		if secret == "" {
+			return nil, errors.New("JWT_SECRET is not set")
+		}
+		return &Key{
+			ID:        config.GetEnv("JWT_KEY_ID", deriveKeyID("hs", []byte(secret))),
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   []byte(secret),
+			VerifyKey: []byte(secret),
+		}, nil
+	}
+
+	pemData, err := readPEMEnv("JWT_PRIVATE_KEY")
+	if err != nil {
+		return nil, err
+	}
+	if pemData == nil {
+		return nil, fmt.Errorf("JWT_ALG=%s needs JWT_PRIVATE_KEY or JWT_PRIVATE_KEY_FILE", alg)
+	}
+
+	key, err := privateKeyFromPEM(pemData)
+	if err != nil {
+		return nil, fmt.Errorf("JWT_PRIVATE_KEY: %w", err)
+	}
+	if key.Method.Alg() != alg {
+		return nil, fmt.Errorf("JWT_ALG=%s does not match the %s private key", alg, key.Method.Alg())
+	}
+
+	key.ID = config.GetEnv("JWT_KEY_ID", key.ID)
+	return key, nil
+}
+
+// readPEMEnv reads NAME, or the file named by NAME_FILE. It returns nil when
+// neither is set.
+func readPEMEnv(name string) ([]byte, error) {
+	if v := os.Getenv(name); v != "" {
+		return []byte(v), nil
+	}
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return data, nil
+	}
+	return nil, nil
+}
+
+func privateKeyFromPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var private any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	public, err := publicKeyOf(private)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := keyForPublic(public)
+	if err != nil {
+		return nil, err
+	}
+	key.SignKey = private
+	return key, nil
+}
+
+func publicKeyFromPEM(data []byte) (*Key, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var public any
+	var err error
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		public, err = x509.ParsePKCS1PublicKey(block.Bytes)
+	default:
+		public, err = x509.ParsePKIXPublicKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return keyForPublic(public)
+}
+
+// keyForPublic picks the signing method and a derived kid for a public key.
+func keyForPublic(public any) (*Key, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+
+	switch pub := public.(type) {
+	case *rsa.PublicKey:
+		return &Key{ID: deriveKeyID("rs", der), Method: jwt.SigningMethodRS256, VerifyKey: pub}, nil
+	case ed25519.PublicKey:
+		return &Key{ID: deriveKeyID("ed", der), Method: jwt.SigningMethodEdDSA, VerifyKey: pub}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T", public)
+	}
+}
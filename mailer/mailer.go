@@ -0,0 +1,63 @@
+package mailer
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"futuremarket/config"
+)
+
+// Message is a single outgoing email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Sender delivers email. Services only depend on this interface so a real
+// SMTP or API-backed provider can be plugged in without touching them.
+type Sender interface {
+	Send(msg Message) error
+}
+
+// LogSender writes every message to the application log. Handy for local
+// development where nobody wants real email going out.
+type LogSender struct{}
+
+func (LogSender) Send(msg Message) error {
+	log.Printf("MAIL → to=%s subject=%q\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// FileSender writes each message to its own .eml file inside Dir.
+type FileSender struct {
+	Dir string
+}
+
+func (s FileSender) Send(msg Message) error {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+
+	safeTo := strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To)
+	name := fmt.Sprintf("%d-%s.eml", time.Now().UnixNano(), safeTo)
+
+	content := fmt.Sprintf("To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		msg.To, msg.Subject, time.Now().Format(time.RFC1123Z), msg.Body)
+
+	return os.WriteFile(filepath.Join(s.Dir, name), []byte(content), 0o600)
+}
+
+// NewFromEnv picks a Sender based on MAIL_DRIVER ("log" or "file").
+func NewFromEnv() Sender {
+	switch config.GetEnv("MAIL_DRIVER", "log") {
+	case "file":
+		return FileSender{Dir: config.GetEnv("MAIL_DIR", "tmp/mail")}
+	default:
+		return LogSender{}
+	}
+}
@@ -5,13 +5,21 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
+	"futuremarket/config"
 	"futuremarket/db"
 	"futuremarket/handlers"
+	"futuremarket/jwtkeys"
+	"futuremarket/mailer"
 	"futuremarket/models"
+	"futuremarket/oidc"
+	"futuremarket/pagination"
 	"futuremarket/repository"
 	"futuremarket/routes"
 	"futuremarket/service"
+	"futuremarket/storage"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -19,6 +27,11 @@ import (
 
 func main() {
 
+	// Subcommands; without one the binary runs the API server
+	if len(os.Args) > 1 && os.Args[1] == "import-products" {
+		os.Exit(runImportProducts(os.Args[2:]))
+	}
+
 	database := db.InitDB()
 	seedAdminUser(database)
 	seedDemoProducts(database)
@@ -30,26 +43,142 @@ func main() {
 	cartRepo := repository.CartRepo{DB: database}
 	orderRepo := repository.OrderRepo{DB: database}
 	productRepo := repository.ProductRepo{DB: database}
+	variantRepo := repository.VariantRepo{DB: database}
+	categoryRepo := repository.CategoryRepo{DB: database}
 	reviewRepo := repository.ReviewRepo{DB: database}
 	blacklistRepo := repository.NewBlacklistRepository(database) // Blacklist
+	refreshTokenRepo := repository.RefreshTokenRepo{DB: database}
+	permissionRepo := repository.PermissionRepo{DB: database}
+	passwordResetRepo := repository.PasswordResetRepo{DB: database}
+	emailVerificationRepo := repository.EmailVerificationRepo{DB: database}
+	auditRepo := repository.AuditRepo{DB: database}
+	twoFactorRepo := repository.TwoFactorRepo{DB: database}
+	sessionRepo := repository.SessionRepo{DB: database}
+	oidcStateRepo := repository.OIDCStateRepo{DB: database}
+	userIdentityRepo := repository.UserIdentityRepo{DB: database}
+	apiKeyRepo := repository.APIKeyRepo{DB: database}
+	accountDataRepo := repository.AccountDataRepo{DB: database}
+
+	// ----------------------------
+	// SIGNING KEYS
+	// ----------------------------
+	keys, err := jwtkeys.LoadFromEnv()
+	if err != nil {
+		log.Fatalf("failed to load JWT signing keys: %v", err)
+	}
+
+	// Signs the next/prev cursors of paginated lists
+	cursors := pagination.LoadSignerFromEnv()
+
+	// ----------------------------
+	// MAIL
+	// ----------------------------
+	mailSender := mailer.NewFromEnv()
+
+	// ----------------------------
+	// FILE STORAGE (product images)
+	// ----------------------------
+	fileStorage, err := storage.NewFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure file storage: %v", err)
+	}
 
 	// ----------------------------
 	// SERVICES
 	// ----------------------------
-	userService := service.UserService{Repo: userRepo}
+	tokenService := service.TokenService{
+		Keys:       keys,
+		Repo:       refreshTokenRepo,
+		Sessions:   sessionRepo,
+		Challenges: repository.MFAChallengeRepo{DB: database},
+	}
+	sessionService := service.SessionService{
+		Repo:      sessionRepo,
+		Tokens:    tokenService,
+		Cache:     service.NewTTLCache(config.GetInt("AUTH_CACHE_MAX_ENTRIES", 100000)),
+		ActiveTTL: config.GetDuration("AUTH_CACHE_TTL", 10*time.Second),
+	}
 	cartService := service.CartService{
 		Repo:        cartRepo,
 		ProductRepo: productRepo,
+		Variants:    variantRepo,
 	}
 
 	orderService := service.OrderService{
-		OrderRepo:   orderRepo,
-		CartRepo:    cartRepo,
-		ProductRepo: productRepo,
+		OrderRepo:            orderRepo,
+		CartRepo:             cartRepo,
+		ProductRepo:          productRepo,
+		UserRepo:             userRepo,
+		Cursors:              cursors,
+		RequireVerifiedEmail: config.RequireVerifiedEmailForCheckout(),
+	}
+	attributeService := service.AttributeService{
+		Repo:       repository.AttributeRepo{DB: database},
+		Categories: categoryRepo,
+	}
+	productService := service.ProductService{
+		Repo:       productRepo,
+		Categories: categoryRepo,
+		Attributes: attributeService,
+		Cursors:    cursors,
+	}
+	reviewService := service.ReviewService{Repo: reviewRepo, Cursors: cursors}
+	blacklistService := service.BlacklistService{
+		Repo:        blacklistRepo,
+		Cache:       service.NewTTLCache(config.GetInt("AUTH_CACHE_MAX_ENTRIES", 100000)),
+		NegativeTTL: config.GetDuration("AUTH_CACHE_TTL", 10*time.Second),
+	}
+	go blacklistService.StartPurgeJob(config.GetDuration("BLACKLIST_PURGE_INTERVAL", time.Hour))
+	permissionService := service.PermissionService{Repo: permissionRepo}
+	passwordResetService := service.PasswordResetService{
+		Repo:         passwordResetRepo,
+		UserRepo:     userRepo,
+		TokenService: tokenService,
+		Mailer:       mailSender,
+	}
+	auditService := service.AuditService{Repo: auditRepo}
+	apiKeyService := service.APIKeyService{Repo: apiKeyRepo, UserRepo: userRepo}
+
+	// Failed-login counters: Postgres (shared by replicas) or in-memory
+	var attemptStore service.AttemptStore = repository.LoginAttemptRepo{DB: database}
+	if config.GetEnv("LOGIN_ATTEMPT_STORE", "postgres") == "memory" {
+		attemptStore = service.NewMemoryAttemptStore()
+	}
+	loginGuard := service.LoginGuard{
+		Store:    attemptStore,
+		Audit:    auditService,
+		Settings: config.LoginThrottleSettings(),
+	}
+
+	emailVerificationService := service.EmailVerificationService{
+		Repo:     emailVerificationRepo,
+		UserRepo: userRepo,
+		Mailer:   mailSender,
+	}
+	twoFactorService := service.TwoFactorService{
+		Repo:     twoFactorRepo,
+		UserRepo: userRepo,
+		Guard: service.LoginGuard{
+			Store:    attemptStore,
+			Audit:    auditService,
+			Settings: config.LoginThrottleSettings(),
+			Scope:    "2fa",
+		},
+	}
+	userService := service.UserService{
+		Repo:         userRepo,
+		Tokens:       tokenService,
+		Verification: emailVerificationService,
+	}
+
+	// Role → action policy lives in the role_permissions table
+	if err := permissionService.SeedDefaults(); err != nil {
+		log.Fatalf("failed to seed role permissions: %v", err)
 	}
-	productService := service.ProductService{Repo: productRepo}
-	reviewService := service.ReviewService{Repo: reviewRepo}
-	blacklistService := service.BlacklistService{Repo: blacklistRepo} // ⭐ NEW
+	if err := permissionService.Load(); err != nil {
+		log.Fatalf("failed to load role permissions: %v", err)
+	}
+	go permissionService.Watch(config.GetDuration("POLICY_RELOAD_INTERVAL", time.Minute))
 
 	// ----------------------------
 	// HANDLERS
@@ -58,10 +187,38 @@ func main() {
 	authHandler := &handlers.AuthHandler{
 		Service:          userService,
 		BlacklistService: blacklistService,
+		TokenService:     tokenService,
+		Sessions:         sessionService,
+		Verification:     emailVerificationService,
+		LoginGuard:       loginGuard,
+		TwoFactor:        twoFactorService,
+		Audit:            auditService,
 	}
 
 	productHandler := &handlers.ProductHandler{
 		Service: productService,
+		Variants: service.VariantService{
+			Repo:        variantRepo,
+			ProductRepo: productRepo,
+		},
+		Images: service.ProductImageService{
+			Repo:        repository.ProductImageRepo{DB: database},
+			ProductRepo: productRepo,
+			Storage:     fileStorage,
+			MaxBytes:    int64(config.GetInt("PRODUCT_IMAGE_MAX_BYTES", 5<<20)),
+			MaxImages:   config.GetInt("PRODUCT_IMAGE_MAX_COUNT", 10),
+		},
+		Audit: auditService,
+	}
+
+	categoryHandler := &handlers.CategoryHandler{
+		Service: service.CategoryService{Repo: categoryRepo, Attributes: attributeService},
+		Audit:   auditService,
+	}
+
+	attributeHandler := &handlers.AttributeHandler{
+		Service: attributeService,
+		Audit:   auditService,
 	}
 
 	cartHandler := &handlers.CartHandler{
@@ -76,18 +233,102 @@ func main() {
 		Service: reviewService,
 	}
 
+	passwordHandler := &handlers.PasswordHandler{
+		Service: passwordResetService,
+		Guard: service.LoginGuard{
+			Store:    attemptStore,
+			Audit:    auditService,
+			Settings: config.PasswordResetThrottleSettings(),
+			Scope:    "password_reset",
+		},
+	}
+
+	verificationHandler := &handlers.EmailVerificationHandler{
+		Service: emailVerificationService,
+	}
+
+	adminUserHandler := &handlers.AdminUserHandler{
+		Service:    userService,
+		LoginGuard: loginGuard,
+		Audit:      auditService,
+	}
+
+	accountHandler := &handlers.AccountHandler{
+		Service: userService,
+		Data: service.AccountDataService{
+			Repo:     accountDataRepo,
+			UserRepo: userRepo,
+		},
+		Audit: auditService,
+	}
+
+	twoFactorHandler := &handlers.TwoFactorHandler{
+		Service: twoFactorService,
+		Audit:   auditService,
+	}
+
+	sessionHandler := &handlers.SessionHandler{
+		Service: sessionService,
+	}
+	jwksHandler := &handlers.JWKSHandler{Keys: keys}
+
+	auditHandler := &handlers.AuditHandler{
+		Service: auditService,
+	}
+
+	apiKeyHandler := &handlers.APIKeyHandler{
+		Service: apiKeyService,
+		Audit:   auditService,
+	}
+
+	// "Sign in with" an external OpenID provider, off unless configured
+	var oidcHandler *handlers.OIDCHandler
+	if oidcSettings := config.OIDCSettings(); oidcSettings.Enabled() {
+		oidcHandler = &handlers.OIDCHandler{
+			Service: service.OIDCService{
+				Client:       oidc.NewClient(oidcSettings, nil),
+				States:       oidcStateRepo,
+				Identities:   userIdentityRepo,
+				UserRepo:     userRepo,
+				Verification: emailVerificationService,
+				Audit:        auditService,
+			},
+			Auth: authHandler,
+		}
+	}
+
 	// ----------------------------
 	// ROUTER
 	// ----------------------------
 	router := routes.SetupRouter(
 		authHandler,
 		productHandler,
+		categoryHandler,
+		attributeHandler,
 		cartHandler,
 		orderHandler,
 		reviewHandler,
+		passwordHandler,
+		verificationHandler,
+		adminUserHandler,
+		accountHandler,
+		twoFactorHandler,
+		sessionHandler,
+		jwksHandler,
+		oidcHandler,
+		apiKeyHandler,
+		auditHandler,
+		keys,
 		blacklistService,
+		sessionService,
+		apiKeyService,
 	)
 
+	// Files of the local storage backend are served by the API itself
+	if local, ok := fileStorage.(storage.Local); ok {
+		router.PathPrefix(storage.LocalPath + "/").Handler(local.Handler())
+	}
+
 	// ----------------------------
 	// START SERVER
 	// ----------------------------
@@ -121,12 +362,14 @@ func seedAdminUser(db *gorm.DB) {
 	password := "AdminPass123!"
 	hashed, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 
-	// Create admin user
+	// Create admin user (seeded address counts as verified)
+	verifiedAt := time.Now()
 	admin = models.User{
-		Name:         "System Admin",
-		Email:        "admin@futuremarket.com",
-		PasswordHash: string(hashed),
-		Role:         "admin",
+		Name:            "System Admin",
+		Email:           "admin@futuremarket.com",
+		PasswordHash:    string(hashed),
+		Role:            "admin",
+		EmailVerifiedAt: &verifiedAt,
 	}
 
 	db.Create(&admin)
@@ -159,6 +402,11 @@ func seedDemoProducts(db *gorm.DB) {
 
 		for _, p := range demoProducts {
 			product := p
+
+			// Demo categories are top-level and keyed by the old strings
+			category := seedCategory(db, product.Category)
+			product.CategoryID, product.Category = &category.ID, category.Name
+
 			db.Create(&product)
 
 			// Create Matching Stock row
@@ -179,7 +427,7 @@ func seedDemoProducts(db *gorm.DB) {
 
 	for _, p := range products {
 		var stock models.Stock
-		err := db.Where("product_id = ?", p.ID).First(&stock).Error
+		err := db.Where("product_id = ? AND variant_id IS NULL", p.ID).First(&stock).Error
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			log.Printf("Missing stock for product %d → Creating now...\n", p.ID)
@@ -193,3 +441,11 @@ func seedDemoProducts(db *gorm.DB) {
 
 	log.Println("Stock self-healing complete — all products now have stock entries.")
 }
+
+func seedCategory(db *gorm.DB, slug string) models.Category {
+	var category models.Category
+	db.Where(models.Category{Slug: slug}).
+		Attrs(models.Category{Name: strings.ToUpper(slug[:1]) + slug[1:]}).
+		FirstOrCreate(&category)
+	return category
+}
@@ -1,20 +1,38 @@
 package middleware
 
 import (
-	"fmt"
 	"net/http"
+
+	"futuremarket/config"
 )
 
+// AdminMiddleware guards the /admin routes. Who may do what there is up to
+// RequirePermission on each route, so any role granted an admin action in
+// role_permissions (e.g. "support" with manage:products) gets through; this
+// only makes sure there is a caller and that it logged in with 2FA.
 func AdminMiddleware(next http.Handler) http.Handler {
     return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
+        // API keys are created by admins; RequirePermission checks their
+        // scopes on every admin route.
+        if _, isAPIKey := r.Context().Value(ContextScopes).([]string); isAPIKey {
+            next.ServeHTTP(w, r)
+            return
+        }
+
         roleValue := r.Context().Value(ContextRole) // <-- FIX: use ctxKey, not string
         role, ok := roleValue.(string)
 
-        fmt.Println("ADMIN MIDDLEWARE → ROLE:", role)
+        if !ok || role == "" {
+            http.Error(w, "Forbidden", http.StatusForbidden)
+            return
+        }
 
-        if !ok || role != "admin" {
-            http.Error(w, "Forbidden: Admins only", http.StatusForbidden)
+        // Every role that can act here must have logged in with a second
+        // factor, not just "admin": any role may be granted admin actions
+        mfa, _ := r.Context().Value(ContextMFA).(bool)
+        if config.RequireAdmin2FA() && !mfa {
+            http.Error(w, "Forbidden: two-factor authentication required, enroll via /api/v1/me/2fa/enroll and log in again", http.StatusForbidden)
             return
         }
 
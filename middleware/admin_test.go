@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"futuremarket/config"
+)
+
+func TestAdminRoutePermissions(t *testing.T) {
+	config.SetPolicy(config.Policy{
+		"admin":    {config.WildcardAction: true},
+		"customer": {"manage:cart": true},
+		"support":  {"manage:products": true},
+	})
+	defer config.SetPolicy(config.DefaultPolicy())
+	t.Setenv("REQUIRE_ADMIN_2FA", "true")
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	tests := []struct {
+		name   string
+		ctx    map[ctxKey]any
+		action string
+		want   int
+	}{
+		{"admin with 2fa", map[ctxKey]any{ContextRole: "admin", ContextMFA: true}, "manage:users", http.StatusNoContent},
+		{"admin without 2fa", map[ctxKey]any{ContextRole: "admin", ContextMFA: false}, "manage:products", http.StatusForbidden},
+		{"support role granted the action", map[ctxKey]any{ContextRole: "support", ContextMFA: true}, "manage:products", http.StatusNoContent},
+		{"support role without 2fa", map[ctxKey]any{ContextRole: "support", ContextMFA: false}, "manage:products", http.StatusForbidden},
+		{"support role without the action", map[ctxKey]any{ContextRole: "support", ContextMFA: true}, "manage:users", http.StatusForbidden},
+		{"customer", map[ctxKey]any{ContextRole: "customer", ContextMFA: true}, "manage:products", http.StatusForbidden},
+		{"unknown role", map[ctxKey]any{ContextRole: "ghost"}, "manage:products", http.StatusForbidden},
+		{"no role", map[ctxKey]any{}, "manage:products", http.StatusForbidden},
+		{"api key with scope", map[ctxKey]any{ContextScopes: []string{"manage:products"}}, "manage:products", http.StatusNoContent},
+		{"api key needs no 2fa", map[ctxKey]any{ContextScopes: []string{"manage:products"}, ContextMFA: false}, "manage:products", http.StatusNoContent},
+		{"api key without scope", map[ctxKey]any{ContextScopes: []string{"read:audit"}}, "manage:products", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			for k, v := range tt.ctx {
+				ctx = context.WithValue(ctx, k, v)
+			}
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/admin/x", nil).WithContext(ctx)
+			w := httptest.NewRecorder()
+
+			AdminMiddleware(RequirePermission(tt.action)(ok)).ServeHTTP(w, r)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d (%s)", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAdmin2FAOptional(t *testing.T) {
+	config.SetPolicy(config.Policy{"support": {"manage:products": true}})
+	defer config.SetPolicy(config.DefaultPolicy())
+	t.Setenv("REQUIRE_ADMIN_2FA", "false")
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	ctx := context.WithValue(context.Background(), ContextRole, "support")
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/admin/x", nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+	AdminMiddleware(RequirePermission("manage:products")(ok)).ServeHTTP(w, r)
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status = %d, want %d (%s)", w.Code, http.StatusNoContent, w.Body.String())
+	}
+}
@@ -2,33 +2,61 @@ package middleware
 
 import (
 	"context"
-	"fmt"
 	"net/http"
-	"os"
 	"strings"
 
+	"futuremarket/config"
+	"futuremarket/jwtkeys"
+	"futuremarket/utils"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
-// This interface allows your service to be injected cleanly.
+// This interface allows your service to be injected cleanly. claims are
+// the token's verified claims.
 type BlacklistService interface {
-	IsTokenBlacklisted(token string) (bool, error)
+	IsTokenBlacklisted(token string, claims jwt.MapClaims) (bool, error)
+}
+
+// SessionChecker tells the middleware whether the session a token belongs
+// to has been signed out.
+type SessionChecker interface {
+	IsSessionActive(sessionID, userID uint) (bool, error)
+}
+
+// APIKeyAuthenticator validates a raw X-API-Key value and returns the key's
+// ID and scopes. An ID of 0 means the key is unknown, expired or revoked.
+type APIKeyAuthenticator interface {
+	AuthenticateAPIKey(rawKey, ip string) (uint, []string, error)
 }
 
 type AuthMiddlewareConfig struct {
+	Keys             *jwtkeys.Keyset
 	BlacklistService BlacklistService
+	Sessions         SessionChecker
+	APIKeys          APIKeyAuthenticator
 }
 
 type ctxKey string
 
 const (
-	ContextUserID ctxKey = "user_id"
-	ContextRole   ctxKey = "role"
+	ContextUserID      ctxKey = "user_id"
+	ContextRole        ctxKey = "role"
+	ContextSessionID   ctxKey = "session_id"
+	ContextMFA         ctxKey = "mfa"
+	ContextAPIKeyID    ctxKey = "api_key_id"
+	ContextScopes      ctxKey = "scopes"
 )
 
 func (cfg AuthMiddlewareConfig) AuthMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
+		// Server-to-server callers authenticate with an API key instead
+		if rawKey := r.Header.Get("X-API-Key"); rawKey != "" && cfg.APIKeys != nil {
+			cfg.authenticateAPIKey(w, r, next, rawKey)
+			return
+		}
+
 		// 1. Read Authorization header
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" || !strings.HasPrefix(authHeader, "Bearer ") {
@@ -38,9 +66,17 @@ func (cfg AuthMiddlewareConfig) AuthMiddleware(next http.Handler) http.Handler {
 
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 
-		// 2. 🔥 Blacklist check BEFORE parsing JWT
+		// 2. Parse and validate JWT (signature by kid, exp/nbf/iat/iss/aud)
+		claims, err := cfg.Keys.Parse(tokenStr)
+		if err != nil {
+			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
+			return
+		}
+
+		// 3. Blacklist check (after the signature check, so forged tokens
+		// never reach the blacklist store)
 		if cfg.BlacklistService != nil {
-			isBlacklisted, err := cfg.BlacklistService.IsTokenBlacklisted(tokenStr)
+			isBlacklisted, err := cfg.BlacklistService.IsTokenBlacklisted(tokenStr, claims)
 			if err != nil {
 				http.Error(w, "server error", http.StatusInternalServerError)
 				return
@@ -52,27 +88,12 @@ func (cfg AuthMiddlewareConfig) AuthMiddleware(next http.Handler) http.Handler {
 			}
 		}
 
-		// 3. Parse and validate JWT
-		token, err := jwt.Parse(tokenStr, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, http.ErrAbortHandler
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-
-		if err != nil || !token.Valid {
-			http.Error(w, "invalid or expired token", http.StatusUnauthorized)
-			return
-		}
-
 		// 4. Extract claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			http.Error(w, "invalid token claims", http.StatusUnauthorized)
+		// Two-factor challenge tokens only work on /login/2fa
+		if _, isChallenge := claims["purpose"]; isChallenge {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
 			return
 		}
-		fmt.Println("ROLE CLAIM FROM JWT:", claims["role"])       // ← ADD THIS
-		fmt.Println("USER ID CLAIM FROM JWT:", claims["user_id"]) // optional
 
 		// user_id
 		userIDFloat, ok := claims["user_id"].(float64)
@@ -89,16 +110,67 @@ func (cfg AuthMiddlewareConfig) AuthMiddleware(next http.Handler) http.Handler {
 			return
 		}
 
-		fmt.Println("AUTH MIDDLEWARE → Role claim:", role)
-
 		// 5. Store values in context
 		ctx := context.WithValue(r.Context(), ContextUserID, userID)
 		ctx = context.WithValue(ctx, ContextRole, role)
 
+		// mfa is true when the login passed a second factor
+		mfa, _ := claims["mfa"].(bool)
+		ctx = context.WithValue(ctx, ContextMFA, mfa)
+
+		// sid ties the token to a session that can be signed out remotely
+		sessionIDFloat, ok := claims["sid"].(float64)
+		if !ok || sessionIDFloat < 1 {
+			http.Error(w, "token has no session, please log in again", http.StatusUnauthorized)
+			return
+		}
+		sessionID := uint(sessionIDFloat)
+
+		if cfg.Sessions != nil {
+			active, err := cfg.Sessions.IsSessionActive(sessionID, uint(userID))
+			if err != nil {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			if !active {
+				http.Error(w, "session has been signed out", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		ctx = context.WithValue(ctx, ContextSessionID, sessionID)
+
 		// 6. Continue the request
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
-} 
+}
+
+// authenticateAPIKey serves a request made with an X-API-Key header. The
+// request carries the key's scopes instead of a user and role, so only
+// routes guarded by RequirePermission with a matching scope accept it.
+func (cfg AuthMiddlewareConfig) authenticateAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, rawKey string) {
+	keyID, scopes, err := cfg.APIKeys.AuthenticateAPIKey(rawKey, utils.ClientIP(r, config.TrustProxyHeaders()))
+	if err != nil {
+		http.Error(w, "server error", http.StatusInternalServerError)
+		return
+	}
+	if keyID == 0 {
+		http.Error(w, "invalid, expired or revoked API key", http.StatusUnauthorized)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), ContextAPIKeyID, keyID)
+	ctx = context.WithValue(ctx, ContextScopes, scopes)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// GetSessionIDFromContext returns the session the current token belongs to.
+func GetSessionIDFromContext(r *http.Request) (uint, bool) {
+	id, ok := r.Context().Value(ContextSessionID).(uint)
+	return id, ok && id > 0
+}
+
 // GetUserIDFromContext returns the user ID from the request context 
 func GetUserIDFromContext(r *http.Request) (uint, bool) {
 	val := r.Context().Value(ContextUserID)
@@ -112,3 +184,9 @@ func GetUserIDFromContext(r *http.Request) (uint, bool) {
 
 	return 0, false
 }
+
+// GetAPIKeyIDFromContext returns the API key the request was made with.
+func GetAPIKeyIDFromContext(r *http.Request) (uint, bool) {
+	id, ok := r.Context().Value(ContextAPIKeyID).(uint)
+	return id, ok && id > 0
+}
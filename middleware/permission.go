@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"net/http"
+
+	"futuremarket/config"
+)
+
+// RequirePermission returns a middleware that only lets the request through
+// when the caller's role may perform action according to
+// config.RolePermission. API key requests need action among the key's
+// scopes instead. It must run after AuthMiddleware.
+func RequirePermission(action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if scopes, isAPIKey := r.Context().Value(ContextScopes).([]string); isAPIKey {
+				if !hasScope(scopes, action) {
+					http.Error(w, "Forbidden: API key lacks scope "+action, http.StatusForbidden)
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			role, _ := r.Context().Value(ContextRole).(string)
+
+			if !config.RolePermission(role, action) {
+				http.Error(w, "Forbidden: missing permission "+action, http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func hasScope(scopes []string, action string) bool {
+	for _, s := range scopes {
+		if s == action {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"futuremarket/utils"
+)
+
+const ContextRequestID ctxKey = "request_id"
+
+// requestIDPattern limits what we accept from a caller's X-Request-ID so it
+// is safe to log and store.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// RequestID gives every request an ID, reusing a well-formed X-Request-ID
+// from the caller (e.g. the load balancer) and echoing it in the response.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			generated, err := utils.RandomToken(12)
+			if err != nil {
+				http.Error(w, "server error", http.StatusInternalServerError)
+				return
+			}
+			id = generated
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), ContextRequestID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// GetRequestIDFromContext returns the current request's ID.
+func GetRequestIDFromContext(r *http.Request) string {
+	id, _ := r.Context().Value(ContextRequestID).(string)
+	return id
+}
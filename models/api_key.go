@@ -0,0 +1,29 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// APIKey lets a server (warehouse, ERP, ...) call the API without a user
+// login. The raw key is shown once at creation; only its hash is stored.
+// Prefix is the public part of the key so admins can tell keys apart.
+type APIKey struct {
+	gorm.Model
+	Name        string     `gorm:"size:100"`
+	Prefix      string     `gorm:"size:16;uniqueIndex"`
+	KeyHash     string     `gorm:"size:64;uniqueIndex"` // sha256 of the raw key
+	Scopes      string     `gorm:"size:500"`            // space-separated actions, see config.IsAPIKeyScope
+	CreatedByID uint       `gorm:"index"`
+	ExpiresAt   *time.Time // nil = never expires
+	LastUsedAt  *time.Time
+	LastUsedIP  string `gorm:"size:64"`
+	RevokedAt   *time.Time
+}
+
+// ScopeList returns the key's scopes as a slice.
+func (k APIKey) ScopeList() []string {
+	return strings.Fields(k.Scopes)
+}
@@ -0,0 +1,26 @@
+package models
+
+import "gorm.io/gorm"
+
+// Attribute value types.
+const (
+	AttributeString  = "string"
+	AttributeNumber  = "number"
+	AttributeBoolean = "boolean"
+	AttributeEnum    = "enum"
+)
+
+// AttributeDefinition declares a custom product property for a category
+// and its subcategories, e.g. "screen_in" (number, inches) for TVs.
+// Products store the values in their Attributes JSON under Key.
+type AttributeDefinition struct {
+	gorm.Model
+	CategoryID uint   `gorm:"index"`
+	Key        string `gorm:"size:50;index"`
+	Label      string `gorm:"size:100"`
+	Type       string `gorm:"size:10"`
+	Options    string `gorm:"type:text"` // JSON array of the allowed enum values
+	Unit       string `gorm:"size:20"`
+	Required   bool
+	Position   int
+}
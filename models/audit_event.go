@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AuditEvent is one append-only entry in the security audit log.
+// Rows are never updated or deleted, so there is no gorm.Model here.
+type AuditEvent struct {
+	ID            uint      `gorm:"primaryKey"`
+	CreatedAt     time.Time `gorm:"index"`
+	ActorID       *uint     `gorm:"index"` // nil for anonymous/system actions
+	ActorAPIKeyID *uint     `gorm:"index"` // set when an API key made the request
+	Action        string    `gorm:"size:100;index"`
+	TargetType    string    `gorm:"size:50;index:idx_audit_target"`
+	TargetID      string    `gorm:"size:100;index:idx_audit_target"`
+	IP            string    `gorm:"size:64"`
+	RequestID     string    `gorm:"size:64;index"`
+	Before        string    `gorm:"type:text"` // JSON of the changed fields before the action
+	After         string    `gorm:"type:text"` // JSON of the changed fields after the action
+	Details       string    `gorm:"type:text"` // free-form JSON
+}
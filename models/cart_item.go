@@ -5,8 +5,10 @@ import "gorm.io/gorm"
 // CartItem links a product to a cart with a quantity.
 type CartItem struct {
 	gorm.Model
-	CartID    uint    `gorm:"index"`
-	ProductID uint    `gorm:"index"`
-	Quantity  int     // must be > 0
-	Product   Product `gorm:"foreignKey:ProductID"` // preload support
+	CartID    uint            `gorm:"index"`
+	ProductID uint            `gorm:"index"`
+	VariantID *uint           `gorm:"index"` // required when the product has variants
+	Quantity  int             // must be > 0
+	Product   Product         `gorm:"foreignKey:ProductID"` // preload support
+	Variant   *ProductVariant `gorm:"foreignKey:VariantID"`
 }
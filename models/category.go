@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// Category is a node in the catalog tree. Top-level categories have no
+// parent; siblings are shown by Position, then name.
+type Category struct {
+	gorm.Model
+	ParentID *uint  `gorm:"index"`
+	Name     string `gorm:"size:100"`
+	Slug     string `gorm:"size:100;uniqueIndex:idx_categories_slug,where:deleted_at IS NULL"`
+	Position int
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// EmailVerificationToken proves a user controls Email. Only the hash of the
+// mailed token is stored.
+type EmailVerificationToken struct {
+	gorm.Model
+	UserID    uint   `gorm:"index"`
+	Email     string `gorm:"size:255"` // address the link was sent to
+	TokenHash string `gorm:"size:64;uniqueIndex"`
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginAttempt counts recent failed logins for one key, either
+// "account:<email>" or "ip:<address>". Other guards prefix their keys with
+// their scope, e.g. "password_reset:ip:<address>".
+type LoginAttempt struct {
+	ID           uint   `gorm:"primaryKey"`
+	Key          string `gorm:"size:300;uniqueIndex"`
+	Failures     int
+	LastFailedAt time.Time
+	LockedUntil  *time.Time
+}
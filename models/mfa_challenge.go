@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// MFAChallenge is a two-factor login whose password step succeeded and
+// that is waiting for its code. The challenge token carries JTI; answering
+// it deletes the row, so every challenge token works once.
+type MFAChallenge struct {
+	ID        uint      `gorm:"primaryKey"`
+	JTI       string    `gorm:"size:64;uniqueIndex"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
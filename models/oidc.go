@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// OIDCState is a started "sign in with" login waiting for the provider to
+// redirect back. It is deleted when the callback consumes it. Only the
+// hash of the state parameter is stored; the nonce and PKCE verifier never
+// leave the server.
+type OIDCState struct {
+	ID           uint      `gorm:"primaryKey"`
+	StateHash    string    `gorm:"size:64;uniqueIndex"`
+	Nonce        string    `gorm:"size:64"`
+	CodeVerifier string    `gorm:"size:128"`
+	DeviceName   string    `gorm:"size:100"`
+	ExpiresAt    time.Time `gorm:"index"`
+	CreatedAt    time.Time
+}
+
+// UserIdentity links a user to an account at an external OpenID provider.
+// (Issuer, Subject) is the provider's stable identifier for that account.
+type UserIdentity struct {
+	gorm.Model
+	UserID  uint   `gorm:"index"`
+	Issuer  string `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject"`
+	Subject string `gorm:"size:255;uniqueIndex:idx_identity_issuer_subject"`
+	Email   string `gorm:"size:255"` // email the provider reported at link time
+}
@@ -7,6 +7,11 @@ type OrderItem struct {
 
     OrderID    uint `gorm:"index"`
     ProductID  uint `gorm:"index"`
+    VariantID  *uint `gorm:"index"`
+    SKU        string `gorm:"size:64"` // variant SKU at the time of the order
     Quantity   int
     PriceCents int64
+
+    // Loaded with archived products too, so order history still shows them
+    Product *Product `gorm:"foreignKey:ProductID"`
 }
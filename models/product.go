@@ -1,21 +1,42 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// Product statuses. Drafts are being prepared, archived products are
+// withdrawn but kept for order history.
+const (
+	ProductDraft    = "draft"
+	ProductActive   = "active"
+	ProductArchived = "archived"
+)
+
 // Product is an item that can be listed, searched and bought.
 type Product struct {
 	gorm.Model
+	SKU         string `gorm:"size:64;uniqueIndex:idx_products_sku,where:sku <> '' AND deleted_at IS NULL"` // external ID for imports; optional
 	Name        string `gorm:"size:255"`
 	Description string `gorm:"type:text"`
-	Category    string `gorm:"size:100"`
+	CategoryID  *uint  `gorm:"index"`
+	Category    string `gorm:"size:100"` // name of CategoryID, kept for search and display
 	PriceCents  int64  // store price in cents
 	Stock       int64  // NEW FIELD for inventory
 	ImageURL    string `gorm:"size:500"`
 
+	// Customers only see active products inside the optional publish
+	// window; see Live
+	Status      string     `gorm:"size:10;not null;default:'active';index"`
+	PublishAt   *time.Time // hidden until then when set
+	UnpublishAt *time.Time // hidden from then on when set
+
+	// Custom attributes as a JSON object, e.g. {"brand": "Sony"}; keys
+	// are declared by AttributeDefinitions on the category
+	Attributes json.RawMessage `gorm:"type:jsonb;not null;default:'{}'"`
+
 	// Denormalised rating info (Epic 6.3)
 	AverageRating float32
 	ReviewCount   int64
@@ -23,3 +44,10 @@ type Product struct {
 	CreatedAt time.Time
 	UpdatedAt time.Time
 }
+
+// Live reports whether customers can see and buy the product at now.
+func (p Product) Live(now time.Time) bool {
+	return p.Status == ProductActive &&
+		(p.PublishAt == nil || !p.PublishAt.After(now)) &&
+		(p.UnpublishAt == nil || p.UnpublishAt.After(now))
+}
@@ -0,0 +1,18 @@
+package models
+
+import "gorm.io/gorm"
+
+// ProductImage is an uploaded product photo. The files live in storage
+// under Key (the original) and ThumbKey; Position orders a product's
+// images, and the first one is mirrored into Product.ImageURL.
+type ProductImage struct {
+	gorm.Model
+	ProductID   uint `gorm:"index"`
+	Position    int
+	Key         string `gorm:"size:255"`
+	ThumbKey    string `gorm:"size:255"`
+	ContentType string `gorm:"size:50"`
+	Width       int
+	Height      int
+	SizeBytes   int64
+}
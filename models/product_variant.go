@@ -0,0 +1,23 @@
+package models
+
+import "gorm.io/gorm"
+
+// ProductVariant is one buyable version of a product, e.g. a shoe in size
+// 42 and black. Each variant has its own Stock row; without a price or
+// image it falls back to the product's.
+type ProductVariant struct {
+	gorm.Model
+	ProductID  uint   `gorm:"uniqueIndex:idx_product_variants_options,where:deleted_at IS NULL"`
+	SKU        string `gorm:"size:64;uniqueIndex:idx_product_variants_sku,where:deleted_at IS NULL"`
+	Options    string `gorm:"type:text;uniqueIndex:idx_product_variants_options,where:deleted_at IS NULL"` // JSON object of option values, keys sorted
+	PriceCents *int64 // overrides Product.PriceCents when set
+	ImageURL   string `gorm:"size:500"`
+}
+
+// EffectivePrice is the variant's price, or the product's when it has none.
+func (v ProductVariant) EffectivePrice(product Product) int64 {
+	if v.PriceCents != nil {
+		return *v.PriceCents
+	}
+	return product.PriceCents
+}
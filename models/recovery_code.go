@@ -0,0 +1,16 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryCode is a one-time backup code that replaces a TOTP code when the
+// user has lost their authenticator. Only the hash is stored.
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"index"`
+	CodeHash string `gorm:"size:64;index"`
+	UsedAt   *time.Time
+}
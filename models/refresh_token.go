@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RefreshToken is an opaque token a client exchanges for a new access token.
+//
+// Every login starts a new family, owned by a Session. Each refresh marks the presented token as
+// used and issues a new one in the same family, so presenting a used token
+// again means it was stolen and the whole family gets revoked.
+type RefreshToken struct {
+	gorm.Model
+	UserID    uint   `gorm:"index"`
+	FamilyID  string `gorm:"size:64;index"`
+	TokenHash string `gorm:"size:64;uniqueIndex"` // sha256 of the raw token, never the token itself
+	ExpiresAt time.Time
+	UsedAt    *time.Time // set when the token is rotated
+	RevokedAt *time.Time // set when the family is revoked
+}
@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// RolePermission grants one action to one role. The rows are loaded into
+// config.RolePermission at startup so new roles (e.g. "support",
+// "warehouse") can be added without recompiling. Action "*" grants
+// everything.
+type RolePermission struct {
+	gorm.Model
+	Role   string `gorm:"size:50;uniqueIndex:idx_role_action"`
+	Action string `gorm:"size:100;uniqueIndex:idx_role_action"`
+}
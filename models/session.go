@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Session is one signed-in device. Every login creates one; its refresh
+// tokens share FamilyID and its access tokens carry the session ID in the
+// "sid" claim, so revoking the session signs that device out completely.
+type Session struct {
+	gorm.Model
+	UserID     uint   `gorm:"index"`
+	FamilyID   string `gorm:"size:64;uniqueIndex"` // refresh token family
+	DeviceName string `gorm:"size:100"`
+	IP         string `gorm:"size:64"` // last seen IP
+	UserAgent  string `gorm:"size:500"`
+	MFA        bool   // login completed a second factor
+	LastSeenAt time.Time
+	RevokedAt  *time.Time
+}
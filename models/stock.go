@@ -2,9 +2,12 @@ package models
 
 import "gorm.io/gorm"
 
-// Stock tracks how many units are available for a product.
+// Stock tracks how many units are available for a product, or for one of
+// its variants when VariantID is set. Each product has one product-level
+// row (VariantID nil) and each variant one row of its own.
 type Stock struct {
 	gorm.Model
-	ProductID uint `gorm:"uniqueIndex"` // stock is 1-to-1 with product
+	ProductID uint  `gorm:"index:idx_stocks_product;uniqueIndex:idx_stocks_product_level,where:variant_id IS NULL"`
+	VariantID *uint `gorm:"uniqueIndex"`
 	Quantity  int
 }
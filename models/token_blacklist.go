@@ -1,9 +1,16 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"time"
 
-// TokenBlacklist stores invalidated JWT tokens
+	"gorm.io/gorm"
+)
+
+// TokenBlacklist stores invalidated JWTs until they would have expired anyway.
+// Key is "jti:<id>" for tokens with a jti claim, otherwise "sha256:<hash>";
+// the raw token is never stored.
 type TokenBlacklist struct {
 	gorm.Model
-	Token string `gorm:"uniqueIndex"`
+	Key       string    `gorm:"size:100;uniqueIndex"`
+	ExpiresAt time.Time `gorm:"index"`
 }
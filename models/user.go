@@ -1,7 +1,7 @@
 package models
 
 import (
-	
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,8 +14,30 @@ import (
 type User struct {
 	gorm.Model
 	Name         string `gorm:"size:100"`
-	Email        string `gorm:"size:255;uniqueIndex"`
+	Email        string `gorm:"size:255;uniqueIndex:idx_users_email_active,where:deleted_at IS NULL"` // deleted accounts free their address
 	PasswordHash string `gorm:"size:255"`
 	Role         string `gorm:"size:20"` // "customer" or "admin"
-	
+
+	// EmailVerifiedAt is nil until the user clicks their verification link.
+	EmailVerifiedAt *time.Time
+
+	// PendingEmail holds a requested new address until it is verified.
+	PendingEmail string `gorm:"size:255"`
+
+	// TOTP two-factor authentication. TOTPPendingSecret holds a secret
+	// during enrollment until the first code confirms it; TOTPLastStep
+	// stops a code from being used twice.
+	TOTPSecret        string `gorm:"size:64"`
+	TOTPPendingSecret string `gorm:"size:64"`
+	TOTPEnabledAt     *time.Time
+	TOTPLastStep      int64
+
+	// DisabledAt is set when an admin disables the account; disabled users
+	// can't log in or refresh tokens.
+	DisabledAt *time.Time
+
+	// AnonymizedAt is set when the user deleted their account. The row stays
+	// so orders and reviews keep their user_id, but every personal field has
+	// been overwritten.
+	AnonymizedAt *time.Time
 }
@@ -0,0 +1,209 @@
+// Package oidc implements the relying-party side of the OpenID Connect
+// authorization code flow with PKCE: discovery, the authorization URL, the
+// code exchange and ID token validation.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"futuremarket/config"
+)
+
+var (
+	ErrDiscovery     = errors.New("oidc: provider discovery failed")
+	ErrTokenExchange = errors.New("oidc: code exchange failed")
+	ErrInvalidToken  = errors.New("oidc: invalid id token")
+)
+
+// ProviderMetadata is the subset of the discovery document we use.
+type ProviderMetadata struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// TokenResponse is what the token endpoint returns for a code.
+type TokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	IDToken     string `json:"id_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// Client talks to one OpenID provider. Discovery and signing keys are
+// fetched lazily and cached.
+type Client struct {
+	Config     config.OIDC
+	HTTPClient *http.Client
+
+	mu       sync.Mutex
+	metadata *ProviderMetadata
+	keys     map[string]any // kid → public key
+	keysAt   time.Time
+}
+
+// NewClient returns a client for cfg. httpClient may be nil.
+func NewClient(cfg config.OIDC, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{Config: cfg, HTTPClient: httpClient}
+}
+
+// Discover fetches (once) the provider's /.well-known/openid-configuration.
+func (c *Client) Discover(ctx context.Context) (*ProviderMetadata, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.metadata != nil {
+		return c.metadata, nil
+	}
+
+	var meta ProviderMetadata
+	if err := c.getJSON(ctx, c.Config.Issuer+"/.well-known/openid-configuration", &meta); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDiscovery, err)
+	}
+
+	// The document must describe the issuer we were configured with,
+	// otherwise ID tokens from it can't be trusted (OIDC Discovery §4.3).
+	if strings.TrimSuffix(meta.Issuer, "/") != c.Config.Issuer {
+		return nil, fmt.Errorf("%w: issuer mismatch %q", ErrDiscovery, meta.Issuer)
+	}
+	if meta.AuthorizationEndpoint == "" || meta.TokenEndpoint == "" || meta.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: incomplete metadata", ErrDiscovery)
+	}
+
+	c.metadata = &meta
+	return c.metadata, nil
+}
+
+// AuthCodeURL builds the URL the browser is sent to.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, codeVerifier string) (string, error) {
+	meta, err := c.Discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	q := url.Values{}
+	q.Set("response_type", "code")
+	q.Set("client_id", c.Config.ClientID)
+	q.Set("redirect_uri", c.Config.RedirectURL)
+	q.Set("scope", strings.Join(c.Config.Scopes, " "))
+	q.Set("state", state)
+	q.Set("nonce", nonce)
+	q.Set("code_challenge", CodeChallenge(codeVerifier))
+	q.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(meta.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return meta.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange trades an authorization code for tokens.
+func (c *Client) Exchange(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	meta, err := c.Discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.Config.RedirectURL)
+	form.Set("code_verifier", codeVerifier)
+	form.Set("client_id", c.Config.ClientID)
+
+	useBasic := c.Config.ClientSecret != "" && supportsBasicAuth(meta.TokenAuthMethods)
+	if c.Config.ClientSecret != "" && !useBasic {
+		form.Set("client_secret", c.Config.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, meta.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if useBasic {
+		req.SetBasicAuth(url.QueryEscape(c.Config.ClientID), url.QueryEscape(c.Config.ClientSecret))
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: status %d: %s", ErrTokenExchange, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var tokens TokenResponse
+	if err := json.Unmarshal(body, &tokens); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenExchange, err)
+	}
+	if tokens.IDToken == "" {
+		return nil, fmt.Errorf("%w: response has no id_token", ErrTokenExchange)
+	}
+
+	return &tokens, nil
+}
+
+// CodeChallenge derives the S256 PKCE challenge for a verifier (RFC 7636).
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// supportsBasicAuth follows the spec default: client_secret_basic unless
+// the provider lists methods without it.
+func supportsBasicAuth(methods []string) bool {
+	if len(methods) == 0 {
+		return true
+	}
+	for _, m := range methods {
+		if m == "client_secret_basic" {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Client) getJSON(ctx context.Context, url string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: status %d", url, resp.StatusCode)
+	}
+
+	return json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out)
+}
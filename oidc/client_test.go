@@ -0,0 +1,404 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"futuremarket/config"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// fakeProvider is a minimal OpenID provider: discovery, JWKS and a token
+// endpoint that checks PKCE the way a real one does.
+type fakeProvider struct {
+	*httptest.Server
+
+	mu          sync.Mutex
+	issuer      string // overrides the issuer in the discovery document
+	authMethods []string
+	keys        map[string]*rsa.PrivateKey
+	discoveries int
+
+	// code → the code_challenge it was issued for, and the ID token to hand out
+	challenges map[string]string
+	idTokens   map[string]string
+	lastForm   url.Values
+	lastBasic  [2]string
+}
+
+func newFakeProvider(t *testing.T) *fakeProvider {
+	t.Helper()
+
+	p := &fakeProvider{
+		keys:       map[string]*rsa.PrivateKey{},
+		challenges: map[string]string{},
+		idTokens:   map[string]string{},
+	}
+	p.addKey(t, "k1")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		p.discoveries++
+		issuer := p.URL
+		if p.issuer != "" {
+			issuer = p.issuer
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                                issuer,
+			"authorization_endpoint":                p.URL + "/authorize",
+			"token_endpoint":                        p.URL + "/token",
+			"jwks_uri":                              p.URL + "/jwks",
+			"token_endpoint_auth_methods_supported": p.authMethods,
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		keys := []map[string]string{}
+		for kid, k := range p.keys {
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"kid": kid,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": keys})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+
+		r.ParseForm()
+		p.lastForm = r.PostForm
+		user, pass, _ := r.BasicAuth()
+		p.lastBasic = [2]string{user, pass}
+
+		code := r.PostForm.Get("code")
+		challenge, ok := p.challenges[code]
+		if !ok || CodeChallenge(r.PostForm.Get("code_verifier")) != challenge {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		delete(p.challenges, code)
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"access_token": "at",
+			"token_type":   "Bearer",
+			"id_token":     p.idTokens[code],
+		})
+	})
+
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func (p *fakeProvider) addKey(t *testing.T, kid string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.mu.Lock()
+	p.keys[kid] = key
+	p.mu.Unlock()
+}
+
+// sign returns an ID token from kid for the standard claims plus overrides;
+// a nil override removes the claim.
+func (p *fakeProvider) sign(t *testing.T, kid string, overrides jwt.MapClaims) string {
+	t.Helper()
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.URL,
+		"sub":            "user-1",
+		"aud":            "client",
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          "nonce",
+		"email":          "user@example.com",
+		"email_verified": true,
+	}
+	for k, v := range overrides {
+		if v == nil {
+			delete(claims, k)
+		} else {
+			claims[k] = v
+		}
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+
+	p.mu.Lock()
+	key := p.keys[kid]
+	p.mu.Unlock()
+
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func (p *fakeProvider) client(secret string) *Client {
+	return NewClient(config.OIDC{
+		Issuer:       p.URL,
+		ClientID:     "client",
+		ClientSecret: secret,
+		RedirectURL:  "https://shop.example.com/api/v1/oidc/callback",
+		Scopes:       []string{"openid", "email"},
+		StateTTL:     time.Minute,
+	}, p.Server.Client())
+}
+
+func TestDiscover(t *testing.T) {
+	p := newFakeProvider(t)
+	c := p.client("")
+
+	meta, err := c.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta.TokenEndpoint != p.URL+"/token" {
+		t.Errorf("token endpoint = %q", meta.TokenEndpoint)
+	}
+	if _, err := c.Discover(context.Background()); err != nil || p.discoveries != 1 {
+		t.Errorf("second call: %v, %d fetches; want the cached document", err, p.discoveries)
+	}
+
+	// A document for another issuer is refused
+	p.issuer = "https://evil.example.com"
+	if _, err := p.client("").Discover(context.Background()); !errors.Is(err, ErrDiscovery) {
+		t.Errorf("issuer mismatch: err = %v, want ErrDiscovery", err)
+	}
+
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+	c = NewClient(config.OIDC{Issuer: down.URL, ClientID: "client"}, down.Client())
+	if _, err := c.Discover(context.Background()); !errors.Is(err, ErrDiscovery) {
+		t.Errorf("no discovery document: err = %v, want ErrDiscovery", err)
+	}
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// RFC 7636 Appendix B
+	got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if want := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"; got != want {
+		t.Errorf("CodeChallenge = %q, want %q", got, want)
+	}
+}
+
+func TestExchangeWithPKCE(t *testing.T) {
+	tests := []struct {
+		name        string
+		secret      string
+		authMethods []string
+		wantBasic   bool
+		wantPost    bool
+	}{
+		{"public client", "", nil, false, false},
+		{"basic by default", "s3cret", nil, true, false},
+		{"basic when listed", "s3cret", []string{"client_secret_post", "client_secret_basic"}, true, false},
+		{"post when basic isn't listed", "s3cret", []string{"client_secret_post"}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newFakeProvider(t)
+			p.authMethods = tt.authMethods
+			c := p.client(tt.secret)
+
+			authURL, err := c.AuthCodeURL(context.Background(), "state", "nonce", "verifier-verifier-verifier-verifier-verif")
+			if err != nil {
+				t.Fatal(err)
+			}
+			u, _ := url.Parse(authURL)
+			q := u.Query()
+			if q.Get("code_challenge_method") != "S256" || q.Get("state") != "state" || q.Get("nonce") != "nonce" ||
+				q.Get("client_id") != "client" || q.Get("scope") != "openid email" || q.Get("response_type") != "code" {
+				t.Fatalf("authorization URL = %s", authURL)
+			}
+
+			// The provider issues a code bound to the challenge it saw
+			p.challenges["code"] = q.Get("code_challenge")
+			p.idTokens["code"] = "id-token"
+
+			if _, err := c.Exchange(context.Background(), "code", "some-other-verifier"); !errors.Is(err, ErrTokenExchange) {
+				t.Fatalf("wrong verifier: err = %v, want ErrTokenExchange", err)
+			}
+			tokens, err := c.Exchange(context.Background(), "code", "verifier-verifier-verifier-verifier-verif")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tokens.IDToken != "id-token" {
+				t.Errorf("id token = %q", tokens.IDToken)
+			}
+
+			if got := p.lastBasic[1] == tt.secret && tt.secret != ""; got != tt.wantBasic {
+				t.Errorf("basic auth = %v, want %v", p.lastBasic, tt.wantBasic)
+			}
+			if got := p.lastForm.Get("client_secret") != ""; got != tt.wantPost {
+				t.Errorf("client_secret in form = %v, want %v", got, tt.wantPost)
+			}
+			if p.lastForm.Get("redirect_uri") != c.Config.RedirectURL {
+				t.Errorf("redirect_uri = %q", p.lastForm.Get("redirect_uri"))
+			}
+		})
+	}
+}
+
+func TestExchangeNeedsIDToken(t *testing.T) {
+	p := newFakeProvider(t)
+	c := p.client("")
+	p.challenges["code"] = CodeChallenge("v")
+
+	if _, err := c.Exchange(context.Background(), "code", "v"); !errors.Is(err, ErrTokenExchange) {
+		t.Errorf("no id_token: err = %v, want ErrTokenExchange", err)
+	}
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	p := newFakeProvider(t)
+	c := p.client("")
+
+	hmacToken, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": p.URL, "sub": "user-1", "aud": "client", "nonce": "nonce",
+		"iat": time.Now().Unix(), "exp": time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("secret"))
+
+	tests := []struct {
+		name  string
+		token string
+		nonce string
+		want  *IDTokenClaims
+	}{
+		{
+			name:  "valid",
+			token: p.sign(t, "k1", nil),
+			nonce: "nonce",
+			want:  &IDTokenClaims{Issuer: p.URL, Subject: "user-1", Email: "user@example.com", EmailVerified: true},
+		},
+		{
+			name:  "email_verified as a string",
+			token: p.sign(t, "k1", jwt.MapClaims{"email_verified": "true", "name": "Ann"}),
+			nonce: "nonce",
+			want:  &IDTokenClaims{Issuer: p.URL, Subject: "user-1", Email: "user@example.com", EmailVerified: true, Name: "Ann"},
+		},
+		{
+			name:  "several audiences with us as azp",
+			token: p.sign(t, "k1", jwt.MapClaims{"aud": []string{"client", "other"}, "azp": "client"}),
+			nonce: "nonce",
+			want:  &IDTokenClaims{Issuer: p.URL, Subject: "user-1", Email: "user@example.com", EmailVerified: true},
+		},
+		{name: "wrong nonce", token: p.sign(t, "k1", nil), nonce: "other"},
+		{name: "no nonce in token", token: p.sign(t, "k1", jwt.MapClaims{"nonce": nil}), nonce: "nonce"},
+		{name: "no nonce expected", token: p.sign(t, "k1", jwt.MapClaims{"nonce": ""}), nonce: ""},
+		{name: "wrong audience", token: p.sign(t, "k1", jwt.MapClaims{"aud": "other"}), nonce: "nonce"},
+		{name: "several audiences without azp", token: p.sign(t, "k1", jwt.MapClaims{"aud": []string{"client", "other"}}), nonce: "nonce"},
+		{name: "several audiences, other azp", token: p.sign(t, "k1", jwt.MapClaims{"aud": []string{"client", "other"}, "azp": "other"}), nonce: "nonce"},
+		{name: "wrong issuer", token: p.sign(t, "k1", jwt.MapClaims{"iss": "https://evil.example.com"}), nonce: "nonce"},
+		{name: "expired", token: p.sign(t, "k1", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), nonce: "nonce"},
+		{name: "no exp", token: p.sign(t, "k1", jwt.MapClaims{"exp": nil}), nonce: "nonce"},
+		{name: "no sub", token: p.sign(t, "k1", jwt.MapClaims{"sub": nil}), nonce: "nonce"},
+		{name: "HMAC", token: hmacToken, nonce: "nonce"},
+		{name: "garbage", token: "not.a.jwt", nonce: "nonce"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := c.VerifyIDToken(context.Background(), tt.token, tt.nonce)
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("err = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if *got != *tt.want {
+				t.Errorf("claims = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenKeyRotation(t *testing.T) {
+	p := newFakeProvider(t)
+	c := p.client("")
+
+	if _, err := c.VerifyIDToken(context.Background(), p.sign(t, "k1", nil), "nonce"); err != nil {
+		t.Fatal(err)
+	}
+
+	forged := p.sign(t, "k1", nil)
+	forged = forged[:len(forged)-2] + "AA"
+	if _, err := c.VerifyIDToken(context.Background(), forged, "nonce"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("bad signature: err = %v, want ErrInvalidToken", err)
+	}
+
+	// The provider rotates. Unknown kids only refetch the JWKS once it's
+	// old enough, so forged kids can't make us hammer the provider.
+	p.addKey(t, "k2")
+	rotated := p.sign(t, "k2", nil)
+	if _, err := c.VerifyIDToken(context.Background(), rotated, "nonce"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("new kid within the refresh interval: err = %v, want ErrInvalidToken", err)
+	}
+	c.keysAt = time.Now().Add(-2 * jwksRefreshInterval)
+	if _, err := c.VerifyIDToken(context.Background(), rotated, "nonce"); err != nil {
+		t.Errorf("new kid after the refresh interval: %v", err)
+	}
+}
+
+func TestPickKey(t *testing.T) {
+	rsa1, _ := rsa.GenerateKey(rand.Reader, 1024)
+	rsa2, _ := rsa.GenerateKey(rand.Reader, 1024)
+	one := map[string]any{"a": &rsa1.PublicKey}
+	two := map[string]any{"a": &rsa1.PublicKey, "b": &rsa2.PublicKey}
+
+	tests := []struct {
+		name   string
+		keys   map[string]any
+		kid    string
+		method jwt.SigningMethod
+		want   any
+	}{
+		{"kid", two, "b", jwt.SigningMethodRS256, &rsa2.PublicKey},
+		{"unknown kid", two, "c", jwt.SigningMethodRS256, nil},
+		{"kid of the wrong key type", two, "a", jwt.SigningMethodES256, nil},
+		{"no kid, one key", one, "", jwt.SigningMethodRS512, &rsa1.PublicKey},
+		{"no kid, several keys", two, "", jwt.SigningMethodRS256, nil},
+		{"no kid, no key of that type", one, "", jwt.SigningMethodEdDSA, nil},
+		{"no keys", nil, "a", jwt.SigningMethodRS256, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pickKey(tt.keys, tt.kid, tt.method)
+			if ok != (tt.want != nil) || (ok && got != tt.want) {
+				t.Errorf("pickKey = %v, %v; want %v", got, ok, tt.want)
+			}
+		})
+	}
+}
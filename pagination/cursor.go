@@ -0,0 +1,89 @@
+package pagination
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"strings"
+
+	"futuremarket/config"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks a row in a listing by its sort key and id. A next cursor
+// continues after that row, a prev cursor (Prev) continues before it.
+type Cursor struct {
+	List string `json:"l"`           // listing and sort it was issued for, e.g. "products:price_asc"
+	Key  string `json:"k,omitempty"` // sort key value of the row
+	ID   uint   `json:"i"`
+	Prev bool   `json:"p,omitempty"`
+}
+
+// Signer turns cursors into opaque tokens and back. Tokens are HMAC-signed
+// so clients can't forge positions or switch them between listings.
+type Signer struct {
+	secret []byte
+}
+
+func NewSigner(secret []byte) *Signer {
+	return &Signer{secret: secret}
+}
+
+// LoadSignerFromEnv signs with CURSOR_SECRET. Without it a random secret
+// is used, so cursors stop working on restart and across replicas.
+func LoadSignerFromEnv() *Signer {
+	if secret := config.GetEnv("CURSOR_SECRET", ""); secret != "" {
+		return NewSigner([]byte(secret))
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		log.Fatalf("failed to generate cursor secret: %v", err)
+	}
+	log.Println("CURSOR_SECRET is not set; pagination cursors won't survive a restart")
+
+	return NewSigner(secret)
+}
+
+// Encode returns the token for c.
+func (s *Signer) Encode(c Cursor) string {
+	payload, _ := json.Marshal(c)
+	body := base64.RawURLEncoding.EncodeToString(payload)
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.sign(body))
+}
+
+// Decode verifies a token and checks it was issued for list.
+func (s *Signer) Decode(token, list string) (Cursor, error) {
+	body, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, s.sign(body)) {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(body)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(payload, &c); err != nil || c.List != list || c.ID == 0 {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	return c, nil
+}
+
+func (s *Signer) sign(body string) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(body))
+	return mac.Sum(nil)
+}
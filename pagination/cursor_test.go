@@ -0,0 +1,65 @@
+package pagination
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignerRoundTrip(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+
+	tests := []Cursor{
+		{List: "products:price_asc", Key: "19.99", ID: 7},
+		{List: "products:newest", ID: 1, Prev: true},
+		{List: "orders", Key: "a.b", ID: 42},
+	}
+	for _, want := range tests {
+		got, err := s.Decode(s.Encode(want), want.List)
+		if err != nil {
+			t.Errorf("Decode(Encode(%+v)): %v", want, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Decode(Encode(%+v)) = %+v", want, got)
+		}
+	}
+}
+
+func TestSignerRejects(t *testing.T) {
+	s := NewSigner([]byte("secret"))
+	valid := s.Encode(Cursor{List: "products:name_asc", Key: "apple", ID: 3})
+	body, sig, _ := strings.Cut(valid, ".")
+
+	// Same payload signed with another secret
+	forged := NewSigner([]byte("other")).Encode(Cursor{List: "products:name_asc", Key: "apple", ID: 3})
+
+	tests := []struct {
+		name  string
+		token string
+		list  string
+	}{
+		{"empty", "", "products:name_asc"},
+		{"no signature", body, "products:name_asc"},
+		{"other secret", forged, "products:name_asc"},
+		{"tampered body", "x" + body + "." + sig, "products:name_asc"},
+		{"tampered signature", body + "." + sig[:len(sig)-2] + "AA", "products:name_asc"},
+		{"signature not base64", body + ".!!", "products:name_asc"},
+		{"other listing", valid, "products:price_asc"},
+		{"zero id", s.Encode(Cursor{List: "products:name_asc"}), "products:name_asc"},
+		{"body not json", signed(s, "bm90LWpzb24"), "products:name_asc"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Decode(tt.token, tt.list); !errors.Is(err, ErrInvalidCursor) {
+				t.Errorf("err = %v, want ErrInvalidCursor", err)
+			}
+		})
+	}
+}
+
+// signed returns body with a valid signature, to get past the HMAC check.
+func signed(s *Signer, body string) string {
+	return body + "." + base64.RawURLEncoding.EncodeToString(s.sign(body))
+}
@@ -0,0 +1,57 @@
+package pagination
+
+// MaxLimit is the largest page any list endpoint returns.
+const MaxLimit = 100
+
+// ClampLimit applies the default for a missing limit and caps it at MaxLimit.
+func ClampLimit(limit, fallback int) int {
+	if limit <= 0 {
+		limit = fallback
+	}
+	if limit > MaxLimit {
+		limit = MaxLimit
+	}
+	return limit
+}
+
+// Request asks for one page of a listing. With a Cursor, Page is ignored
+// and the page starts next to the cursor's row; without one it is the
+// classic page/limit offset.
+type Request struct {
+	Page      int
+	Limit     int
+	Cursor    *Cursor
+	SkipCount bool // leave out the total, which costs a COUNT(*)
+}
+
+// Offset is the number of rows to skip in page/limit mode.
+func (r Request) Offset() int {
+	if r.Cursor != nil || r.Page < 1 {
+		return 0
+	}
+	return (r.Page - 1) * r.Limit
+}
+
+// Backward reports whether the page is read in reverse (a prev cursor).
+func (r Request) Backward() bool {
+	return r.Cursor != nil && r.Cursor.Prev
+}
+
+// Trim cuts rows fetched with Limit+1 down to one page, puts rows read
+// backwards into listing order and reports whether there are rows on
+// either side of the page.
+func Trim[T any](rows []T, req Request) (page []T, hasNext, hasPrev bool) {
+	more := len(rows) > req.Limit
+	if more {
+		rows = rows[:req.Limit]
+	}
+
+	if req.Backward() {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+		return rows, true, more
+	}
+
+	return rows, more, req.Cursor != nil || req.Offset() > 0
+}
@@ -0,0 +1,75 @@
+package pagination
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestClampLimit(t *testing.T) {
+	tests := []struct {
+		limit, fallback, want int
+	}{
+		{0, 20, 20},
+		{-5, 20, 20},
+		{10, 20, 10},
+		{MaxLimit + 1, 20, MaxLimit},
+		{0, MaxLimit * 2, MaxLimit},
+	}
+	for _, tt := range tests {
+		if got := ClampLimit(tt.limit, tt.fallback); got != tt.want {
+			t.Errorf("ClampLimit(%d, %d) = %d, want %d", tt.limit, tt.fallback, got, tt.want)
+		}
+	}
+}
+
+func TestOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		req  Request
+		want int
+	}{
+		{"first page", Request{Page: 1, Limit: 10}, 0},
+		{"third page", Request{Page: 3, Limit: 10}, 20},
+		{"no page", Request{Limit: 10}, 0},
+		{"cursor ignores page", Request{Page: 3, Limit: 10, Cursor: &Cursor{ID: 1}}, 0},
+	}
+	for _, tt := range tests {
+		if got := tt.req.Offset(); got != tt.want {
+			t.Errorf("%s: Offset() = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTrim(t *testing.T) {
+	next := &Cursor{ID: 1}
+	prev := &Cursor{ID: 1, Prev: true}
+
+	tests := []struct {
+		name              string
+		rows              []int
+		req               Request
+		want              []int
+		wantNext, wantPrv bool
+	}{
+		{"first page, more", []int{1, 2, 3, 4}, Request{Page: 1, Limit: 3}, []int{1, 2, 3}, true, false},
+		{"first page, last", []int{1, 2}, Request{Page: 1, Limit: 3}, []int{1, 2}, false, false},
+		{"later page", []int{4, 5}, Request{Page: 2, Limit: 3}, []int{4, 5}, false, true},
+		{"empty", nil, Request{Page: 1, Limit: 3}, nil, false, false},
+		{"next cursor, more", []int{4, 5, 6, 7}, Request{Limit: 3, Cursor: next}, []int{4, 5, 6}, true, true},
+		{"next cursor, last", []int{4}, Request{Limit: 3, Cursor: next}, []int{4}, false, true},
+		// Rows read backwards arrive nearest-first and are flipped
+		{"prev cursor, more", []int{6, 5, 4, 3}, Request{Limit: 3, Cursor: prev}, []int{4, 5, 6}, true, true},
+		{"prev cursor, first", []int{2, 1}, Request{Limit: 3, Cursor: prev}, []int{1, 2}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, hasNext, hasPrev := Trim(tt.rows, tt.req)
+			if !slices.Equal(page, tt.want) {
+				t.Errorf("page = %v, want %v", page, tt.want)
+			}
+			if hasNext != tt.wantNext || hasPrev != tt.wantPrv {
+				t.Errorf("hasNext, hasPrev = %v, %v, want %v, %v", hasNext, hasPrev, tt.wantNext, tt.wantPrv)
+			}
+		})
+	}
+}
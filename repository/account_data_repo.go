@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+// AnonymizedName replaces the name of a deleted account, so reviews show it
+// as the author.
+const AnonymizedName = "Deleted user"
+
+// AccountDataRepo reads and erases everything stored about one user, for
+// data export and account deletion requests.
+type AccountDataRepo struct {
+	DB *gorm.DB
+}
+
+// ListCarts returns the user's carts with their items.
+func (r AccountDataRepo) ListCarts(userID uint) ([]models.Cart, error) {
+	var carts []models.Cart
+	err := r.DB.Preload("Items").Where("user_id = ?", userID).Order("id").Find(&carts).Error
+	return carts, err
+}
+
+// ListOrders returns every order the user placed, with items.
+func (r AccountDataRepo) ListOrders(userID uint) ([]models.Order, error) {
+	var orders []models.Order
+	err := r.DB.Preload("Items").Where("user_id = ?", userID).Order("created_at").Find(&orders).Error
+	return orders, err
+}
+
+// ListReviews returns every review the user wrote.
+func (r AccountDataRepo) ListReviews(userID uint) ([]models.Review, error) {
+	var reviews []models.Review
+	err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&reviews).Error
+	return reviews, err
+}
+
+// ListSessions returns all of the user's sessions, including ended ones.
+func (r AccountDataRepo) ListSessions(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListIdentities returns the user's linked external sign-in accounts.
+func (r AccountDataRepo) ListIdentities(userID uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	err := r.DB.Where("user_id = ?", userID).Order("created_at").Find(&identities).Error
+	return identities, err
+}
+
+// Anonymize erases a user's personal data in one transaction. Orders (kept
+// for accounting) and reviews (kept for product ratings) stay attached to
+// the now anonymous user row; carts, credentials and sign-in links are
+// deleted and every login is revoked and stripped of device details.
+func (r AccountDataRepo) Anonymize(userID uint, at time.Time) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.User{}).
+			Where("id = ? AND anonymized_at IS NULL", userID).
+			Updates(map[string]any{
+				"name":                AnonymizedName,
+				"email":               fmt.Sprintf("deleted-%d@deleted.invalid", userID),
+				"password_hash":       "",
+				"pending_email":       "",
+				"email_verified_at":   nil,
+				"totp_secret":         "",
+				"totp_pending_secret": "",
+				"totp_enabled_at":     nil,
+				"disabled_at":         at,
+				"anonymized_at":       at,
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		if err := tx.Where("cart_id IN (?)", tx.Model(&models.Cart{}).Select("id").Where("user_id = ?", userID)).
+			Unscoped().Delete(&models.CartItem{}).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []any{
+			&models.Cart{},
+			&models.RecoveryCode{},
+			&models.UserIdentity{},
+			&models.PasswordResetToken{},
+			&models.EmailVerificationToken{},
+		} {
+			if err := tx.Unscoped().Where("user_id = ?", userID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := (RefreshTokenRepo{DB: tx}).RevokeAllForUser(userID); err != nil {
+			return err
+		}
+		if err := (SessionRepo{DB: tx}).RevokeAllForUser(userID); err != nil {
+			return err
+		}
+
+		// Device details of past logins are personal data too
+		return tx.Model(&models.Session{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]any{"device_name": "", "ip": "", "user_agent": ""}).Error
+	})
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type APIKeyRepo struct {
+	DB *gorm.DB
+}
+
+// Create stores a new key.
+func (r APIKeyRepo) Create(key *models.APIKey) error {
+	return r.DB.Create(key).Error
+}
+
+// FindByHash looks up a key by the SHA-256 of its raw value.
+func (r APIKeyRepo) FindByHash(hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.DB.Where("key_hash = ?", hash).First(&key).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetByID fetches a key by primary key.
+func (r APIKeyRepo) GetByID(id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.DB.First(&key, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// List returns every key, newest first.
+func (r APIKeyRepo) List() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := r.DB.Order("created_at DESC").Find(&keys).Error
+	return keys, err
+}
+
+// Touch records that a key was used.
+func (r APIKeyRepo) Touch(id uint, at time.Time, ip string) error {
+	return r.DB.Model(&models.APIKey{}).
+		Where("id = ?", id).
+		Updates(map[string]any{"last_used_at": at, "last_used_ip": ip}).Error
+}
+
+// Revoke disables a key. It returns false if it was already revoked.
+func (r APIKeyRepo) Revoke(id uint, at time.Time) (bool, error) {
+	res := r.DB.Model(&models.APIKey{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+// AttributeRepo stores the custom attribute definitions of categories.
+type AttributeRepo struct {
+	DB *gorm.DB
+}
+
+// ListForCategories returns the definitions of the given categories in
+// display order.
+func (r AttributeRepo) ListForCategories(categoryIDs []uint) ([]models.AttributeDefinition, error) {
+	var defs []models.AttributeDefinition
+	if len(categoryIDs) == 0 {
+		return defs, nil
+	}
+	err := r.DB.Where("category_id IN ?", categoryIDs).
+		Order("position, key, id").
+		Find(&defs).Error
+	return defs, err
+}
+
+// ListByKeys returns every definition using one of keys, in any category.
+func (r AttributeRepo) ListByKeys(keys []string) ([]models.AttributeDefinition, error) {
+	var defs []models.AttributeDefinition
+	if len(keys) == 0 {
+		return defs, nil
+	}
+	err := r.DB.Where("key IN ?", keys).Order("id").Find(&defs).Error
+	return defs, err
+}
+
+func (r AttributeRepo) GetByID(id uint) (models.AttributeDefinition, error) {
+	var def models.AttributeDefinition
+	err := r.DB.First(&def, id).Error
+	return def, err
+}
+
+func (r AttributeRepo) Create(def *models.AttributeDefinition) error {
+	return r.DB.Create(def).Error
+}
+
+func (r AttributeRepo) Update(def *models.AttributeDefinition) error {
+	return r.DB.Save(def).Error
+}
+
+// HasValues reports whether a product in categoryIDs has a value for key.
+func (r AttributeRepo) HasValues(categoryIDs []uint, key string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.Product{}).
+		Where("category_id IN ? AND attributes -> ? IS NOT NULL", categoryIDs, key).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Delete removes a definition and its values from the products in
+// categoryIDs (the definition's category and subcategories).
+func (r AttributeRepo) Delete(def models.AttributeDefinition, categoryIDs []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Product{}).
+			Where("category_id IN ? AND attributes -> ? IS NOT NULL", categoryIDs, def.Key).
+			Update("attributes", gorm.Expr("attributes - ?", def.Key)).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&def).Error
+	})
+}
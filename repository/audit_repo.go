@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type AuditRepo struct {
+	DB *gorm.DB
+}
+
+// AuditFilter narrows ListEvents. Zero values mean "any".
+type AuditFilter struct {
+	ActorID      *uint
+	APIKeyID     *uint
+	Action       string // exact match, or a prefix when it ends in "*" (e.g. "auth.*")
+	TargetType   string
+	TargetID     string
+	RequestID    string
+	IP           string
+	Since, Until *time.Time
+}
+
+// Create appends an event to the audit log.
+func (r AuditRepo) Create(event *models.AuditEvent) error {
+	return r.DB.Create(event).Error
+}
+
+// List returns a page of events matching filter, newest first, and the total
+// number of matches.
+func (r AuditRepo) List(filter AuditFilter, page, limit int) ([]models.AuditEvent, int64, error) {
+	query := r.DB.Model(&models.AuditEvent{})
+
+	if filter.ActorID != nil {
+		query = query.Where("actor_id = ?", *filter.ActorID)
+	}
+	if filter.APIKeyID != nil {
+		query = query.Where("actor_api_key_id = ?", *filter.APIKeyID)
+	}
+	if filter.Action != "" {
+		if prefix, ok := strings.CutSuffix(filter.Action, "*"); ok {
+			query = query.Where("action LIKE ? ESCAPE '\\'", escapeLike(prefix)+"%")
+		} else {
+			query = query.Where("action = ?", filter.Action)
+		}
+	}
+	if filter.TargetType != "" {
+		query = query.Where("target_type = ?", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		query = query.Where("target_id = ?", filter.TargetID)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.IP != "" {
+		query = query.Where("ip = ?", filter.IP)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []models.AuditEvent
+	err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&events).Error
+
+	return events, total, err
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
@@ -1,9 +1,12 @@
 package repository
 
 import (
+	"time"
+
 	"futuremarket/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type BlacklistRepository struct {
@@ -14,20 +17,27 @@ func NewBlacklistRepository(db *gorm.DB) BlacklistRepository {
 	return BlacklistRepository{DB: db}
 }
 
-// Add stores a token in the blacklist
-func (r BlacklistRepository) Add(token string) error {
-	entry := models.TokenBlacklist{Token: token}
-	return r.DB.Create(&entry).Error
+// Add stores a token key in the blacklist until expiresAt
+func (r BlacklistRepository) Add(key string, expiresAt time.Time) error {
+	entry := models.TokenBlacklist{Key: key, ExpiresAt: expiresAt}
+	return r.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&entry).Error
 }
 
-// Exists checks if a token has been blacklisted already
-func (r BlacklistRepository) Exists(token string) (bool, error) {
-	var entry models.TokenBlacklist
-	err := r.DB.Where("token = ?", token).First(&entry).Error
+// Exists checks if a token key has been blacklisted and hasn't expired yet
+func (r BlacklistRepository) Exists(key string) (bool, error) {
+	var count int64
+	err := r.DB.Model(&models.TokenBlacklist{}).
+		Where("key = ? AND expires_at > ?", key, time.Now()).
+		Count(&count).Error
 
-	if err == gorm.ErrRecordNotFound {
-		return false, nil
-	}
+	return count > 0, err
+}
 
-	return err == nil, err
+// DeleteExpired permanently removes entries whose token has expired.
+// Rows left over from before entries had an expiry are removed as well.
+func (r BlacklistRepository) DeleteExpired(now time.Time) (int64, error) {
+	res := r.DB.Unscoped().
+		Where("expires_at < ? OR expires_at IS NULL OR key IS NULL", now).
+		Delete(&models.TokenBlacklist{})
+	return res.RowsAffected, res.Error
 }
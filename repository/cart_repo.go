@@ -28,20 +28,31 @@ func (r CartRepo) GetOrCreateCart(userID uint) (*models.Cart, error) {
 
 func (r CartRepo) FindCartItems(cartID uint) ([]models.CartItem, error) {
     var items []models.CartItem
-    err := r.DB.Preload("Product").Where("cart_id = ?", cartID).Find(&items).Error
+    err := r.DB.Preload("Product").Preload("Variant").Where("cart_id = ?", cartID).Find(&items).Error
     return items, err
 }
 
-func (r CartRepo) AddOrIncreaseItem(cartID, productID uint) error {
+// itemQuery matches the cart line for a product, or for one of its
+// variants when variantID is set.
+func (r CartRepo) itemQuery(cartID, productID uint, variantID *uint) *gorm.DB {
+    query := r.DB.Where("cart_id = ? AND product_id = ?", cartID, productID)
+    if variantID != nil {
+        return query.Where("variant_id = ?", *variantID)
+    }
+    return query.Where("variant_id IS NULL")
+}
+
+func (r CartRepo) AddOrIncreaseItem(cartID, productID uint, variantID *uint) error {
     var item models.CartItem
 
-    err := r.DB.Where("cart_id = ? AND product_id = ?", cartID, productID).
+    err := r.itemQuery(cartID, productID, variantID).
         First(&item).Error
 
     if err == gorm.ErrRecordNotFound {
         item = models.CartItem{
             CartID:    cartID,
             ProductID: productID,
+            VariantID: variantID,
             Quantity:  1,
         }
         return r.DB.Create(&item).Error
@@ -50,13 +61,13 @@ func (r CartRepo) AddOrIncreaseItem(cartID, productID uint) error {
     return r.DB.Model(&item).Update("quantity", item.Quantity+1).Error
 }
 
-func (r CartRepo) UpdateItemQuantity(cartID, productID uint, qty int) error {
-    return r.DB.Model(&models.CartItem{}).
-        Where("cart_id = ? AND product_id = ?", cartID, productID).
+func (r CartRepo) UpdateItemQuantity(cartID, productID uint, variantID *uint, qty int) error {
+    return r.itemQuery(cartID, productID, variantID).
+        Model(&models.CartItem{}).
         Update("quantity", qty).Error
 }
 
-func (r CartRepo) RemoveItem(cartID, productID uint) error {
-    return r.DB.Where("cart_id = ? AND product_id = ?", cartID, productID).
+func (r CartRepo) RemoveItem(cartID, productID uint, variantID *uint) error {
+    return r.itemQuery(cartID, productID, variantID).
         Delete(&models.CartItem{}).Error
 }
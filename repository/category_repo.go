@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+// CategoryRepo stores the catalog category tree.
+type CategoryRepo struct {
+	DB *gorm.DB
+}
+
+// List returns every category, siblings in display order.
+func (r CategoryRepo) List() ([]models.Category, error) {
+	var categories []models.Category
+	err := r.DB.Order("position, name, id").Find(&categories).Error
+	return categories, err
+}
+
+func (r CategoryRepo) GetByID(id uint) (models.Category, error) {
+	var c models.Category
+	err := r.DB.First(&c, id).Error
+	return c, err
+}
+
+func (r CategoryRepo) GetBySlug(slug string) (models.Category, error) {
+	var c models.Category
+	err := r.DB.Where("slug = ?", slug).First(&c).Error
+	return c, err
+}
+
+// SlugTaken reports whether another category uses slug.
+func (r CategoryRepo) SlugTaken(slug string, exceptID uint) (bool, error) {
+	var n int64
+	err := r.DB.Model(&models.Category{}).
+		Where("slug = ? AND id <> ?", slug, exceptID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+func (r CategoryRepo) Create(c *models.Category) error {
+	return r.DB.Create(c).Error
+}
+
+// Update saves a category and copies its name onto its products, which
+// keep it for search and display.
+func (r CategoryRepo) Update(c *models.Category) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(c).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Product{}).
+			Where("category_id = ? AND category <> ?", c.ID, c.Name).
+			Update("category", c.Name).Error
+	})
+}
+
+func (r CategoryRepo) Delete(c models.Category) error {
+	return r.DB.Delete(&c).Error
+}
+
+// CountUsage returns how many child categories and products a category has.
+func (r CategoryRepo) CountUsage(id uint) (children int64, products int64, err error) {
+	if err = r.DB.Model(&models.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+		return 0, 0, err
+	}
+	err = r.DB.Model(&models.Product{}).Where("category_id = ?", id).Count(&products).Error
+	return children, products, err
+}
+
+// categoryWithDescendants is a subquery of the ids of the category with
+// the given slug and everything below it.
+const categoryWithDescendants = `WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE slug = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT c.id FROM categories c JOIN tree ON c.parent_id = tree.id WHERE c.deleted_at IS NULL
+	) SELECT id FROM tree`
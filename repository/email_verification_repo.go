@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type EmailVerificationRepo struct {
+	DB *gorm.DB
+}
+
+// Create stores a new verification token.
+func (r EmailVerificationRepo) Create(token *models.EmailVerificationToken) error {
+	return r.DB.Create(token).Error
+}
+
+// FindByHash looks up a verification token by the SHA-256 of its raw value.
+func (r EmailVerificationRepo) FindByHash(hash string) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	err := r.DB.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes a token. It returns false if it was already used.
+func (r EmailVerificationRepo) MarkUsed(id uint, at time.Time) (bool, error) {
+	res := r.DB.Model(&models.EmailVerificationToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// InvalidateForUser consumes every outstanding token for a user so only the
+// most recent link works.
+func (r EmailVerificationRepo) InvalidateForUser(userID uint) error {
+	return r.DB.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
+
+// LatestForUser returns the most recently issued token for a user.
+func (r EmailVerificationRepo) LatestForUser(userID uint) (*models.EmailVerificationToken, error) {
+	var token models.EmailVerificationToken
+	err := r.DB.Where("user_id = ?", userID).Order("created_at DESC").First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// CountSince counts tokens issued to a user after a point in time.
+func (r EmailVerificationRepo) CountSince(userID uint, since time.Time) (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.EmailVerificationToken{}).
+		Where("user_id = ? AND created_at > ?", userID, since).
+		Count(&count).Error
+	return count, err
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// LoginAttemptRepo is the Postgres-backed login attempt store. Counters live
+// in the database so every replica sees the same failures.
+type LoginAttemptRepo struct {
+	DB *gorm.DB
+}
+
+// Get returns the record for key, or an empty record if there is none.
+func (r LoginAttemptRepo) Get(key string) (models.LoginAttempt, error) {
+	var attempt models.LoginAttempt
+	err := r.DB.Where("key = ?", key).First(&attempt).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.LoginAttempt{Key: key}, nil
+	}
+	return attempt, err
+}
+
+// RecordFailure atomically bumps the failure counter for key.
+func (r LoginAttemptRepo) RecordFailure(key string, at time.Time) (models.LoginAttempt, error) {
+	attempt := models.LoginAttempt{Key: key, Failures: 1, LastFailedAt: at}
+
+	err := r.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"failures":       gorm.Expr("login_attempts.failures + 1"),
+			"last_failed_at": at,
+		}),
+	}).Create(&attempt).Error
+	if err != nil {
+		return models.LoginAttempt{}, err
+	}
+
+	return r.Get(key)
+}
+
+// Lock blocks key until the given time.
+func (r LoginAttemptRepo) Lock(key string, until time.Time) error {
+	return r.DB.Model(&models.LoginAttempt{}).
+		Where("key = ?", key).
+		Update("locked_until", until).Error
+}
+
+// Reset forgets every failure recorded for key.
+func (r LoginAttemptRepo) Reset(key string) error {
+	return r.DB.Where("key = ?", key).Delete(&models.LoginAttempt{}).Error
+}
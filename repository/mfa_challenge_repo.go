@@ -0,0 +1,31 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type MFAChallengeRepo struct {
+	DB *gorm.DB
+}
+
+// Create stores an issued challenge.
+func (r MFAChallengeRepo) Create(challenge *models.MFAChallenge) error {
+	return r.DB.Create(challenge).Error
+}
+
+// Consume deletes the unexpired challenge with the given jti for userID.
+// It returns false when there is none, e.g. because it was already used.
+func (r MFAChallengeRepo) Consume(jti string, userID uint, now time.Time) (bool, error) {
+	res := r.DB.Where("jti = ? AND user_id = ? AND expires_at > ?", jti, userID, now).
+		Delete(&models.MFAChallenge{})
+	return res.RowsAffected == 1, res.Error
+}
+
+// DeleteExpired removes challenges that were never answered.
+func (r MFAChallengeRepo) DeleteExpired(now time.Time) error {
+	return r.DB.Where("expires_at < ?", now).Delete(&models.MFAChallenge{}).Error
+}
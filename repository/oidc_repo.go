@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OIDCStateRepo struct {
+	DB *gorm.DB
+}
+
+// Create stores a started login.
+func (r OIDCStateRepo) Create(state *models.OIDCState) error {
+	return r.DB.Create(state).Error
+}
+
+// Consume deletes and returns the state with the given hash, so each state
+// can complete at most one login.
+func (r OIDCStateRepo) Consume(hash string) (*models.OIDCState, error) {
+	var states []models.OIDCState
+	err := r.DB.Clauses(clause.Returning{}).
+		Where("state_hash = ?", hash).
+		Delete(&states).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(states) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &states[0], nil
+}
+
+// DeleteExpired removes logins that were started but never finished.
+func (r OIDCStateRepo) DeleteExpired(now time.Time) error {
+	return r.DB.Where("expires_at < ?", now).Delete(&models.OIDCState{}).Error
+}
+
+type UserIdentityRepo struct {
+	DB *gorm.DB
+}
+
+// Find returns the identity for a provider account.
+func (r UserIdentityRepo) Find(issuer, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.DB.Where("issuer = ? AND subject = ?", issuer, subject).First(&identity).Error
+	if err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Create links a provider account to a user.
+func (r UserIdentityRepo) Create(identity *models.UserIdentity) error {
+	return r.DB.Create(identity).Error
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
     "futuremarket/models"
+    "futuremarket/pagination"
     "gorm.io/gorm"
 )
 
@@ -11,7 +12,7 @@ type OrderRepo struct {
 
 func (r OrderRepo) ListOrders(userID uint) ([]models.Order, error) {
     var orders []models.Order
-    err := r.DB.Preload("Items").
+    err := preloadItems(r.DB).
         Where("user_id = ?", userID).
         Order("created_at DESC").
         Find(&orders).Error
@@ -19,11 +20,11 @@ func (r OrderRepo) ListOrders(userID uint) ([]models.Order, error) {
     return orders, err
 }
 
-// ListOrdersPaginated returns orders with pagination support.
+// ListOrdersPaginated returns one page of a user's orders, newest first.
+// The total is 0 when req.SkipCount is set.
 func (r OrderRepo) ListOrdersPaginated(
     userID uint,
-    page int,
-    limit int,
+    req pagination.Request,
 ) ([]models.Order, int64, error) {
 
     var orders []models.Order
@@ -32,18 +33,19 @@ func (r OrderRepo) ListOrdersPaginated(
     query := r.DB.Model(&models.Order{}).Where("user_id = ?", userID)
 
     // Count total results
-    if err := query.Count(&total).Error; err != nil {
-        return nil, 0, err
+    if !req.SkipCount {
+        if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+            return nil, 0, err
+        }
     }
 
-    offset := (page - 1) * limit
+    query, err := newestFirst(query, "orders", req)
+    if err != nil {
+        return nil, 0, err
+    }
 
     // Fetch paginated rows
-    err := query.
-        Preload("Items").
-        Order("created_at DESC").
-        Offset(offset).
-        Limit(limit).
+    err = preloadItems(query).
         Find(&orders).Error
 
     if err != nil {
@@ -53,3 +55,10 @@ func (r OrderRepo) ListOrdersPaginated(
     return orders, total, nil
 }
 
+// preloadItems loads order items with their products, whatever their
+// status and even if deleted, so past orders always show what was bought.
+func preloadItems(query *gorm.DB) *gorm.DB {
+    return query.Preload("Items.Product", func(db *gorm.DB) *gorm.DB {
+        return db.Unscoped()
+    })
+}
@@ -0,0 +1,32 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/pagination"
+
+	"gorm.io/gorm"
+)
+
+// newestFirst pages query by (created_at, id) descending, the order orders
+// and reviews are listed in. It fetches req.Limit+1 rows so the caller can
+// tell whether another page follows (see pagination.Trim).
+func newestFirst(query *gorm.DB, table string, req pagination.Request) (*gorm.DB, error) {
+	op, dir := "<", "DESC"
+	if req.Backward() {
+		op, dir = ">", "ASC"
+	}
+
+	if req.Cursor != nil {
+		key, err := time.Parse(time.RFC3339Nano, req.Cursor.Key)
+		if err != nil {
+			return nil, pagination.ErrInvalidCursor
+		}
+		query = query.Where("("+table+".created_at, "+table+".id) "+op+" (?, ?)", key, req.Cursor.ID)
+	}
+
+	return query.
+		Order(table + ".created_at " + dir + ", " + table + ".id " + dir).
+		Offset(req.Offset()).
+		Limit(req.Limit + 1), nil
+}
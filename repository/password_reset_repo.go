@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type PasswordResetRepo struct {
+	DB *gorm.DB
+}
+
+// Create stores a new reset token.
+func (r PasswordResetRepo) Create(token *models.PasswordResetToken) error {
+	return r.DB.Create(token).Error
+}
+
+// FindByHash looks up a reset token by the SHA-256 of its raw value.
+func (r PasswordResetRepo) FindByHash(hash string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	err := r.DB.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed consumes a token. It returns false if it was already used.
+func (r PasswordResetRepo) MarkUsed(id uint, at time.Time) (bool, error) {
+	res := r.DB.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// InvalidateForUser consumes every outstanding token for a user so only the
+// most recent link works.
+func (r PasswordResetRepo) InvalidateForUser(userID uint) error {
+	return r.DB.Model(&models.PasswordResetToken{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Update("used_at", time.Now()).Error
+}
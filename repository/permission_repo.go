@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type PermissionRepo struct {
+	DB *gorm.DB
+}
+
+// ListAll returns every role → action grant.
+func (r PermissionRepo) ListAll() ([]models.RolePermission, error) {
+	var rows []models.RolePermission
+	err := r.DB.Order("role, action").Find(&rows).Error
+	return rows, err
+}
+
+// Count returns the number of grants stored.
+func (r PermissionRepo) Count() (int64, error) {
+	var count int64
+	err := r.DB.Model(&models.RolePermission{}).Count(&count).Error
+	return count, err
+}
+
+// CreateMany inserts several grants at once.
+func (r PermissionRepo) CreateMany(rows []models.RolePermission) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	return r.DB.Create(&rows).Error
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"encoding/json"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Attribute filter operators, as in attr.<key>[gte]=50. A bare attr.<key>=
+// is eq.
+const (
+	AttributeEq  = "eq"
+	AttributeGt  = "gt"
+	AttributeGte = "gte"
+	AttributeLt  = "lt"
+	AttributeLte = "lte"
+)
+
+var attributeOps = map[string]string{
+	AttributeGt:  ">",
+	AttributeGte: ">=",
+	AttributeLt:  "<",
+	AttributeLte: "<=",
+}
+
+// IsAttributeOp reports whether op is an allowed attribute filter operator.
+func IsAttributeOp(op string) bool {
+	_, ok := attributeOps[op]
+	return ok || op == AttributeEq
+}
+
+// AttributeFilter is one attr.<key> condition. Raw holds the values as
+// given in the query; Values holds them converted to the attribute's type
+// and is what the query uses. Several eq values match any of them, several
+// range values must all hold.
+type AttributeFilter struct {
+	Key    string
+	Op     string
+	Raw    []string
+	Values []any
+}
+
+// apply adds the condition to query. Equality uses JSON containment so the
+// GIN index on products.attributes serves it; ranges compare numbers only.
+func (f AttributeFilter) apply(query *gorm.DB) *gorm.DB {
+	if f.Op == AttributeEq {
+		conds := make([]string, len(f.Values))
+		vars := make([]any, len(f.Values))
+		for i, v := range f.Values {
+			doc, _ := json.Marshal(map[string]any{f.Key: v})
+			conds[i] = "products.attributes @> CAST(? AS jsonb)"
+			vars[i] = string(doc)
+		}
+		return query.Where("("+strings.Join(conds, " OR ")+")", vars...)
+	}
+
+	// CASE keeps non-numbers away from the cast
+	for _, v := range f.Values {
+		query = query.Where(`CASE WHEN jsonb_typeof(products.attributes -> ?) = 'number'
+			THEN (products.attributes ->> ?)::numeric END `+attributeOps[f.Op]+` ?`, f.Key, f.Key, v)
+	}
+	return query
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+// TestAttributeFilterSQL checks the generated conditions without running
+// them: the JSON operators only exist in Postgres.
+func TestAttributeFilterSQL(t *testing.T) {
+	db := newTestDB(t)
+
+	tests := []struct {
+		name     string
+		filter   AttributeFilter
+		wantSQL  string
+		wantVars []any
+	}{
+		{
+			"eq",
+			AttributeFilter{Key: "brand", Op: AttributeEq, Values: []any{"Sony"}},
+			`(products.attributes @> CAST(? AS jsonb))`,
+			[]any{`{"brand":"Sony"}`},
+		},
+		{
+			"eq with several values matches any",
+			AttributeFilter{Key: "screen_in", Op: AttributeEq, Values: []any{55.0, true}},
+			`(products.attributes @> CAST(? AS jsonb) OR products.attributes @> CAST(? AS jsonb))`,
+			[]any{`{"screen_in":55}`, `{"screen_in":true}`},
+		},
+		{
+			"eq escapes the key",
+			AttributeFilter{Key: `a"b`, Op: AttributeEq, Values: []any{"x"}},
+			`(products.attributes @> CAST(? AS jsonb))`,
+			[]any{`{"a\"b":"x"}`},
+		},
+		{
+			"range",
+			AttributeFilter{Key: "screen_in", Op: AttributeGte, Values: []any{50.0}},
+			`CASE WHEN jsonb_typeof(products.attributes -> ?) = 'number' THEN (products.attributes ->> ?)::numeric END >= ?`,
+			[]any{"screen_in", "screen_in", 50.0},
+		},
+		{
+			"several ranges must all hold",
+			AttributeFilter{Key: "screen_in", Op: AttributeLt, Values: []any{65.0, 70.0}},
+			`CASE WHEN jsonb_typeof(products.attributes -> ?) = 'number' THEN (products.attributes ->> ?)::numeric END < ? AND ` +
+				`CASE WHEN jsonb_typeof(products.attributes -> ?) = 'number' THEN (products.attributes ->> ?)::numeric END < ?`,
+			[]any{"screen_in", "screen_in", 65.0, "screen_in", "screen_in", 70.0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var products []models.Product
+			stmt := tt.filter.apply(db.Session(&gorm.Session{DryRun: true}).Model(&models.Product{})).
+				Find(&products).Statement
+
+			sql := strings.Join(strings.Fields(stmt.SQL.String()), " ")
+			where := sql[strings.Index(sql, " WHERE ")+len(" WHERE "):]
+			want := strings.Join(strings.Fields(tt.wantSQL), " ")
+			if !strings.Contains(where, want) {
+				t.Errorf("WHERE %s\nwant   %s", where, want)
+			}
+			if vars := stmt.Vars; !reflect.DeepEqual(vars, tt.wantVars) {
+				t.Errorf("vars = %#v, want %#v", vars, tt.wantVars)
+			}
+		})
+	}
+}
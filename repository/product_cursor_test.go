@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/pagination"
+)
+
+// TestListProductsCursorWalk pages through a listing with ties on the sort
+// key, forward to the end and back to the start, the way the handler
+// builds cursors from the first and last row of each page.
+func TestListProductsCursorWalk(t *testing.T) {
+	db := newTestDB(t, &models.Product{})
+	r := ProductRepo{DB: db}
+
+	for _, p := range []struct {
+		name  string
+		price int64
+	}{
+		{"A", 300}, {"B", 100}, {"C", 200}, {"D", 100}, {"E", 200},
+	} {
+		product := models.Product{Name: p.name, PriceCents: p.price, Status: models.ProductActive, Attributes: json.RawMessage(`{}`)}
+		if err := db.Create(&product).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := ProductFilter{Sort: "price_asc"}
+	page := func(cursor *pagination.Cursor) ([]string, []ProductRow, bool, bool) {
+		t.Helper()
+		req := pagination.Request{Limit: 2, Cursor: cursor, SkipCount: true}
+		rows, _, err := r.ListProductsFiltered(req, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows, hasNext, hasPrev := pagination.Trim(rows, req)
+		var names []string
+		for _, p := range rows {
+			names = append(names, p.Name)
+		}
+		return names, rows, hasNext, hasPrev
+	}
+
+	var forward [][]string
+	var cursor *pagination.Cursor
+	var last []ProductRow
+	for {
+		names, rows, hasNext, _ := page(cursor)
+		forward = append(forward, names)
+		last = rows
+		if !hasNext {
+			break
+		}
+		end := rows[len(rows)-1]
+		cursor = &pagination.Cursor{Key: end.SortKey, ID: end.ID}
+	}
+
+	want := [][]string{{"B", "D"}, {"C", "E"}, {"A"}}
+	if !slices.EqualFunc(forward, want, slices.Equal) {
+		t.Fatalf("forward pages = %q, want %q", forward, want)
+	}
+
+	var backward [][]string
+	for {
+		first := last[0]
+		names, rows, _, hasPrev := page(&pagination.Cursor{Key: first.SortKey, ID: first.ID, Prev: true})
+		backward = append(backward, names)
+		last = rows
+		if !hasPrev {
+			break
+		}
+	}
+
+	want = [][]string{{"C", "E"}, {"B", "D"}}
+	if !slices.EqualFunc(backward, want, slices.Equal) {
+		t.Errorf("backward pages = %q, want %q", backward, want)
+	}
+}
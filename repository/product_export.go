@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"futuremarket/models"
+)
+
+// ExportRow is a product with the quantity of its own stock row, which is
+// what checkout draws from (products.stock can lag behind it).
+type ExportRow struct {
+	models.Product
+	StockQuantity int64 `gorm:"column:stock_quantity"`
+}
+
+// ExportBatch returns up to limit products with ids above afterID, in id
+// order, whatever their status. Callers page through the catalogue by
+// passing the last id they got.
+func (r ProductRepo) ExportBatch(afterID uint, limit int) ([]ExportRow, error) {
+	var rows []ExportRow
+	err := r.DB.Model(&models.Product{}).
+		Select("products.*, COALESCE(stocks.quantity, products.stock) AS stock_quantity").
+		Joins("LEFT JOIN stocks ON stocks.product_id = products.id AND stocks.variant_id IS NULL AND stocks.deleted_at IS NULL").
+		Where("products.id > ?", afterID).
+		Order("products.id").
+		Limit(limit).
+		Find(&rows).Error
+	return rows, err
+}
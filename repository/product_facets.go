@@ -0,0 +1,174 @@
+package repository
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Facets that CountFacets can compute.
+const (
+	FacetCategory = "category"
+	FacetPrice    = "price"
+	FacetRating   = "rating"
+)
+
+// maxCategoryFacets caps the category values returned, most common first.
+const maxCategoryFacets = 50
+
+// ratingFacetSteps are the "N stars & up" buckets of the rating facet.
+var ratingFacetSteps = []int{4, 3, 2, 1}
+
+// IsProductFacet reports whether name is a facet CountFacets knows.
+func IsProductFacet(name string) bool {
+	switch name {
+	case FacetCategory, FacetPrice, FacetRating:
+		return true
+	}
+	return false
+}
+
+// FacetValue is one value of a facet and how many products have it. For
+// categories Value is the slug to filter by and Label the name.
+type FacetValue struct {
+	Value string `json:"value"`
+	Label string `json:"label,omitempty"`
+	Count int64  `json:"count"`
+}
+
+// PriceBucket counts the products priced from MinCents up to, but not
+// including, MaxCents. The last bucket has no MaxCents.
+type PriceBucket struct {
+	MinCents int64  `json:"min_cents"`
+	MaxCents *int64 `json:"max_cents"`
+	Count    int64  `json:"count"`
+}
+
+// RatingBucket counts the products with an average rating of at least
+// MinRating stars.
+type RatingBucket struct {
+	MinRating int   `json:"min_rating"`
+	Count     int64 `json:"count"`
+}
+
+// ProductFacets holds the counts of the facets that were asked for.
+type ProductFacets struct {
+	Category []FacetValue   `json:"category,omitempty"`
+	Price    []PriceBucket  `json:"price,omitempty"`
+	Rating   []RatingBucket `json:"rating,omitempty"`
+}
+
+// CountFacets counts products per facet value under filter. Each facet
+// ignores its own filter, so picking a category still shows how many
+// products the other categories would have. priceBounds are the ascending
+// bucket boundaries in cents.
+func (r ProductRepo) CountFacets(filter ProductFilter, names []string, priceBounds []int64) (ProductFacets, error) {
+	var facets ProductFacets
+	var err error
+
+	for _, name := range names {
+		switch name {
+		case FacetCategory:
+			facets.Category, err = r.categoryFacet(filter)
+		case FacetPrice:
+			facets.Price, err = r.priceFacet(filter, priceBounds)
+		case FacetRating:
+			facets.Rating, err = r.ratingFacet(filter)
+		}
+		if err != nil {
+			return ProductFacets{}, err
+		}
+	}
+
+	return facets, nil
+}
+
+func (r ProductRepo) categoryFacet(filter ProductFilter) ([]FacetValue, error) {
+	query, _ := r.filteredQuery(filter, FacetCategory)
+
+	// Counts are per assigned category, not rolled up to parents
+	var values []FacetValue
+	err := query.
+		Select("categories.slug AS value, categories.name AS label, COUNT(*) AS count").
+		Joins("JOIN categories ON categories.id = products.category_id AND categories.deleted_at IS NULL").
+		Group("categories.slug, categories.name").
+		Order("count DESC, categories.name").
+		Limit(maxCategoryFacets).
+		Scan(&values).Error
+
+	return values, err
+}
+
+func (r ProductRepo) priceFacet(filter ProductFilter, bounds []int64) ([]PriceBucket, error) {
+	query, _ := r.filteredQuery(filter, FacetPrice)
+
+	// CASE WHEN price_cents < b0 THEN 0 WHEN price_cents < b1 THEN 1 ... ELSE n END
+	var expr strings.Builder
+	vars := make([]any, 0, len(bounds))
+
+	expr.WriteString("CASE")
+	for i, b := range bounds {
+		expr.WriteString(" WHEN price_cents < ? THEN " + strconv.Itoa(i))
+		vars = append(vars, b)
+	}
+	expr.WriteString(" ELSE " + strconv.Itoa(len(bounds)) + " END AS bucket, COUNT(*) AS count")
+
+	var rows []struct {
+		Bucket int
+		Count  int64
+	}
+	err := query.
+		Select(expr.String(), vars...).
+		Group("bucket").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// Every bucket is listed, including the empty ones
+	buckets := make([]PriceBucket, len(bounds)+1)
+	for i := range buckets {
+		if i > 0 {
+			buckets[i].MinCents = bounds[i-1]
+		}
+		if i < len(bounds) {
+			upper := bounds[i]
+			buckets[i].MaxCents = &upper
+		}
+	}
+	for _, row := range rows {
+		if row.Bucket >= 0 && row.Bucket < len(buckets) {
+			buckets[row.Bucket].Count = row.Count
+		}
+	}
+
+	return buckets, nil
+}
+
+func (r ProductRepo) ratingFacet(filter ProductFilter) ([]RatingBucket, error) {
+	query, _ := r.filteredQuery(filter, FacetRating)
+
+	var rows []struct {
+		Stars float64
+		Count int64
+	}
+	err := query.
+		Select("FLOOR(average_rating) AS stars, COUNT(*) AS count").
+		Group("stars").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// "N & up" buckets are cumulative over the whole-star counts
+	buckets := make([]RatingBucket, len(ratingFacetSteps))
+	for i, step := range ratingFacetSteps {
+		buckets[i].MinRating = step
+		for _, row := range rows {
+			if int(row.Stars) >= step {
+				buckets[i].Count += row.Count
+			}
+		}
+	}
+
+	return buckets, nil
+}
@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+
+	"futuremarket/models"
+)
+
+func TestCountFacets(t *testing.T) {
+	db := newTestDB(t, &models.Product{}, &models.Category{})
+	r := ProductRepo{DB: db}
+
+	electronics := models.Category{Name: "Electronics", Slug: "electronics"}
+	db.Create(&electronics)
+	phones := models.Category{Name: "Phones", Slug: "phones", ParentID: &electronics.ID}
+	db.Create(&phones)
+	garden := models.Category{Name: "Garden", Slug: "garden"}
+	db.Create(&garden)
+
+	for _, p := range []models.Product{
+		{Name: "TV", CategoryID: &electronics.ID, PriceCents: 50000, AverageRating: 4.2},
+		{Name: "Phone", CategoryID: &phones.ID, PriceCents: 30000, AverageRating: 3.9},
+		{Name: "Case", CategoryID: &phones.ID, PriceCents: 1000, AverageRating: 5},
+		{Name: "Hose", CategoryID: &garden.ID, PriceCents: 2500, AverageRating: 1.5},
+		{Name: "Rake", CategoryID: &garden.ID, PriceCents: 999, AverageRating: 0, Status: models.ProductDraft},
+	} {
+		if p.Status == "" {
+			p.Status = models.ProductActive
+		}
+		p.Attributes = json.RawMessage(`{}`)
+		if err := db.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bounds := []int64{2000, 10000}
+	phonesSlug := "phones"
+	minPrice := int64(2000)
+
+	facets, err := r.CountFacets(ProductFilter{Category: &phonesSlug, MinPrice: &minPrice},
+		[]string{FacetCategory, FacetPrice}, bounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The category facet ignores the category filter but keeps the price one
+	wantCategories := map[string]int64{"electronics": 1, "phones": 1, "garden": 1}
+	if len(facets.Category) != len(wantCategories) {
+		t.Errorf("category facet = %+v", facets.Category)
+	}
+	for _, v := range facets.Category {
+		if wantCategories[v.Value] != v.Count || v.Label == "" {
+			t.Errorf("category %q: %+v, want count %d", v.Value, v, wantCategories[v.Value])
+		}
+	}
+
+	// The price facet ignores the price filter but keeps the category one,
+	// which includes subcategories only below phones
+	wantPrices := []struct {
+		min   int64
+		max   *int64
+		count int64
+	}{
+		{0, &bounds[0], 1},
+		{2000, &bounds[1], 0},
+		{10000, nil, 1},
+	}
+	if len(facets.Price) != len(wantPrices) {
+		t.Fatalf("price facet = %+v", facets.Price)
+	}
+	for i, want := range wantPrices {
+		got := facets.Price[i]
+		if got.MinCents != want.min || got.Count != want.count || (got.MaxCents == nil) != (want.max == nil) ||
+			(got.MaxCents != nil && *got.MaxCents != *want.max) {
+			t.Errorf("price bucket %d = %+v, want %+v", i, got, want)
+		}
+	}
+
+	// Facets that weren't asked for stay empty
+	if facets.Rating != nil {
+		t.Errorf("rating facet = %+v, not requested", facets.Rating)
+	}
+
+	electronicsSlug := "electronics"
+	facets, err = r.CountFacets(ProductFilter{Category: &electronicsSlug}, []string{FacetPrice}, bounds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := facets.Price[0].Count + facets.Price[1].Count + facets.Price[2].Count; n != 3 {
+		t.Errorf("electronics and its subcategories: %d products, want 3", n)
+	}
+}
+
+func TestRatingFacet(t *testing.T) {
+	db := newTestDB(t, &models.Product{})
+	r := ProductRepo{DB: db}
+	if err := db.Exec("SELECT FLOOR(1.5)").Error; err != nil {
+		t.Skip("FLOOR needs SQLite math functions; run with -tags sqlite_math_functions")
+	}
+
+	for _, rating := range []float32{4.9, 4, 3.99, 1.2, 0} {
+		p := models.Product{Name: "P", AverageRating: rating, Status: models.ProductActive, Attributes: json.RawMessage(`{}`)}
+		db.Create(&p)
+	}
+
+	facets, err := r.CountFacets(ProductFilter{}, []string{FacetRating}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []RatingBucket{{4, 2}, {3, 3}, {2, 3}, {1, 4}}
+	for i := range want {
+		if facets.Rating[i] != want[i] {
+			t.Errorf("rating facet = %+v, want %+v", facets.Rating, want)
+			break
+		}
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+// ProductImageRepo stores the records of uploaded product images.
+type ProductImageRepo struct {
+	DB *gorm.DB
+}
+
+// ListByProduct returns a product's images in display order.
+func (r ProductImageRepo) ListByProduct(productID uint) ([]models.ProductImage, error) {
+	var images []models.ProductImage
+	err := r.DB.Where("product_id = ?", productID).Order("position, id").Find(&images).Error
+	return images, err
+}
+
+// GetForProduct returns an image only if it belongs to productID.
+func (r ProductImageRepo) GetForProduct(productID, imageID uint) (models.ProductImage, error) {
+	var img models.ProductImage
+	err := r.DB.Where("product_id = ?", productID).First(&img, imageID).Error
+	return img, err
+}
+
+func (r ProductImageRepo) Create(img *models.ProductImage) error {
+	return r.DB.Create(img).Error
+}
+
+func (r ProductImageRepo) Delete(img models.ProductImage) error {
+	return r.DB.Delete(&img).Error
+}
+
+// Reorder sets the positions of a product's images to their index in ids.
+func (r ProductImageRepo) Reorder(productID uint, ids []uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		for i, id := range ids {
+			if err := tx.Model(&models.ProductImage{}).
+				Where("id = ? AND product_id = ?", id, productID).
+				Update("position", i).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SetProductImageURL updates the image URL shown for a product in listings.
+func (r ProductImageRepo) SetProductImageURL(productID uint, url string) error {
+	return r.DB.Model(&models.Product{}).Where("id = ?", productID).Update("image_url", url).Error
+}
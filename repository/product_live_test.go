@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"futuremarket/models"
+)
+
+// TestOnlyLiveMatchesProductLive checks the SQL filter and the Go method
+// agree on which products customers see.
+func TestOnlyLiveMatchesProductLive(t *testing.T) {
+	db := newTestDB(t, &models.Product{})
+
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		status    string
+		publish   *time.Time
+		unpublish *time.Time
+		live      bool
+	}{
+		{"active", models.ProductActive, nil, nil, true},
+		{"draft", models.ProductDraft, nil, nil, false},
+		{"archived", models.ProductArchived, nil, nil, false},
+		{"published", models.ProductActive, &past, nil, true},
+		{"publishes now", models.ProductActive, &now, nil, true},
+		{"scheduled", models.ProductActive, &future, nil, false},
+		{"still on sale", models.ProductActive, nil, &future, true},
+		{"unpublishes now", models.ProductActive, nil, &now, false},
+		{"expired", models.ProductActive, nil, &past, false},
+		{"inside the window", models.ProductActive, &past, &future, true},
+		{"scheduled draft", models.ProductDraft, &past, &future, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := models.Product{Name: tt.name, Status: tt.status, PublishAt: tt.publish, UnpublishAt: tt.unpublish, Attributes: json.RawMessage(`{}`)}
+			if err := db.Create(&p).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			if got := p.Live(now); got != tt.live {
+				t.Errorf("Live = %v, want %v", got, tt.live)
+			}
+
+			var count int64
+			if err := onlyLive(db.Model(&models.Product{}), now).Where("id = ?", p.ID).Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if (count == 1) != tt.live {
+				t.Errorf("onlyLive found %d rows, want live = %v", count, tt.live)
+			}
+		})
+	}
+}
@@ -1,7 +1,10 @@
 package repository
 
 import (
+	"time"
+
 	"futuremarket/models"
+	"futuremarket/pagination"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -32,10 +35,61 @@ func (r ProductRepo) GetProductByID(id uint) (models.Product, error) {
 	return product, err
 }
 
+// GetBySKU returns the product with the given SKU, whatever its status.
+func (r ProductRepo) GetBySKU(sku string) (models.Product, error) {
+	var product models.Product
+	err := r.DB.Where("sku = ?", sku).First(&product).Error
+	return product, err
+}
+
+// SKUTaken reports whether another product uses sku.
+func (r ProductRepo) SKUTaken(sku string, exceptID uint) (bool, error) {
+	var n int64
+	err := r.DB.Model(&models.Product{}).
+		Where("sku = ? AND id <> ?", sku, exceptID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// SetStock sets a product's own stock, creating its stock row if needed,
+// and mirrors it into products.stock.
+func (r ProductRepo) SetStock(productID uint, quantity int64) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		res := tx.Model(&models.Stock{}).
+			Where("product_id = ? AND variant_id IS NULL", productID).
+			Update("quantity", quantity)
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			if err := tx.Create(&models.Stock{ProductID: productID, Quantity: int(quantity)}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.Product{}).Where("id = ?", productID).Update("stock", quantity).Error
+	})
+}
+
+// GetLiveProductByID returns a product only if customers can see it now.
+func (r ProductRepo) GetLiveProductByID(id uint) (models.Product, error) {
+	var product models.Product
+	err := onlyLive(r.DB, time.Now()).First(&product, id).Error
+	return product, err
+}
+
+// onlyLive restricts query to the products customers can see at now: the
+// SQL form of models.Product.Live.
+func onlyLive(query *gorm.DB, now time.Time) *gorm.DB {
+	return query.Where("products.status = ?", models.ProductActive).
+		Where("products.publish_at IS NULL OR products.publish_at <= ?", now).
+		Where("products.unpublish_at IS NULL OR products.unpublish_at > ?", now)
+}
+
 // ⭐ REAL STOCK LOOKUP (used by CartService)
+// Returns the product-level row; variants have their own (see VariantRepo).
 func (r ProductRepo) GetStockByProductID(productID uint) (*models.Stock, error) {
 	var stock models.Stock
-	err := r.DB.Where("product_id = ?", productID).First(&stock).Error
+	err := r.DB.Where("product_id = ? AND variant_id IS NULL", productID).First(&stock).Error
 	if err != nil {
 		return nil, err
 	}
@@ -43,56 +97,119 @@ func (r ProductRepo) GetStockByProductID(productID uint) (*models.Stock, error)
 }
 
 // ⭐ LOCKED STOCK LOOKUP (used by OrderService inside transactions)
-func (r ProductRepo) GetStockLocked(tx *gorm.DB, productID uint) (*models.Stock, error) {
+// With a variantID it locks that variant's row instead of the product's.
+func (r ProductRepo) GetStockLocked(tx *gorm.DB, productID uint, variantID *uint) (*models.Stock, error) {
 	var stock models.Stock
-	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
-		Where("product_id = ?", productID).
-		First(&stock).Error
 
+	query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("product_id = ?", productID)
+	if variantID != nil {
+		query = query.Where("variant_id = ?", *variantID)
+	} else {
+		query = query.Where("variant_id IS NULL")
+	}
+
+	err := query.First(&stock).Error
 	if err != nil {
 		return nil, err
 	}
 	return &stock, nil
 }
 
-// ListProductsFiltered applies pagination + filtering for Epic 2
-func (r ProductRepo) ListProductsFiltered(
-	page int,
-	limit int,
-	minPrice *int64,
-	maxPrice *int64,
-	category *string,
-) ([]models.Product, int64, error) {
+// ProductFilter narrows ListProductsFiltered. Nil/empty fields don't filter.
+type ProductFilter struct {
+	MinPrice  *int64
+	MaxPrice  *int64
+	Category  *string // category slug; includes its subcategories
+	MinRating *float64
+	Query     string // full-text search over name, category and description
+	Sort      string // one of ProductSortKeys; empty means relevance for searches, else newest
 
-	var products []models.Product
-	var totalItems int64
+	Attributes []AttributeFilter // attr.<key>= conditions, all of which must match
+}
 
-	// Start query
-	query := r.DB.Model(&models.Product{})
+// ProductRow is a listed product with the value it was sorted by, which
+// cursors are built from.
+type ProductRow struct {
+	models.Product
+	SortKey string `gorm:"column:sort_key" json:"-"`
+}
 
-	// Apply filters
-	if minPrice != nil {
-		query = query.Where("price_cents >= ?", *minPrice)
+// filteredQuery builds the query over live products for filter, leaving out the
+// conditions of the facet named in skip ("" applies them all). For
+// searches it also returns the relevance ordering.
+func (r ProductRepo) filteredQuery(filter ProductFilter, skip string) (*gorm.DB, *productSort) {
+	query := onlyLive(r.DB.Model(&models.Product{}), time.Now())
+
+	if skip != FacetPrice {
+		if filter.MinPrice != nil {
+			query = query.Where("products.price_cents >= ?", *filter.MinPrice)
+		}
+		if filter.MaxPrice != nil {
+			query = query.Where("products.price_cents <= ?", *filter.MaxPrice)
+		}
 	}
-	if maxPrice != nil {
-		query = query.Where("price_cents <= ?", *maxPrice)
+	if skip != FacetCategory && filter.Category != nil {
+		query = query.Where("products.category_id IN ("+categoryWithDescendants+")", *filter.Category)
 	}
-	if category != nil {
-		query = query.Where("category = ?", *category)
+	if skip != FacetRating && filter.MinRating != nil {
+		query = query.Where("products.average_rating >= ?", *filter.MinRating)
+	}
+	for _, f := range filter.Attributes {
+		query = f.apply(query)
 	}
 
-	// Count total after filters
-	if err := query.Count(&totalItems).Error; err != nil {
-		return nil, 0, err
+	terms := searchTerms(filter.Query)
+	if len(terms) == 0 {
+		return query, nil
 	}
 
-	offset := (page - 1) * limit
+	query, relevance := r.applySearch(query, terms)
+	return query, &relevance
+}
+
+// ListProductsFiltered returns one page of products matching filter, in
+// the order filter.SortName() names. Searches are ordered by relevance
+// unless a sort is given. The total is 0 when req.SkipCount is set.
+func (r ProductRepo) ListProductsFiltered(req pagination.Request, filter ProductFilter) ([]ProductRow, int64, error) {
+
+	var products []ProductRow
+	var totalItems int64
+
+	// Start query with filters applied
+	query, relevance := r.filteredQuery(filter, "")
+
+	// An explicit sort wins over search relevance
+	order := productSorts[DefaultProductSort]
+	switch {
+	case filter.Sort != "":
+		order = productSorts[filter.Sort]
+	case relevance != nil:
+		order = *relevance
+	}
+	if order.join != nil {
+		query = order.join(query)
+	}
+
+	// Count total after filters (before the cursor narrows the rows)
+	if !req.SkipCount {
+		if err := query.Session(&gorm.Session{}).Count(&totalItems).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	if req.Cursor != nil {
+		var err error
+		if query, err = order.after(query, req.Cursor); err != nil {
+			return nil, 0, err
+		}
+	}
 
-	// Retrieve paginated products
-	err := query.
-		Offset(offset).
-		Limit(limit).
-		Order("created_at DESC").
+	// One extra row tells whether another page follows
+	err := order.selectKey(query).
+		Offset(req.Offset()).
+		Limit(req.Limit + 1).
+		Order(order.orderBy(req.Backward())).
 		Find(&products).Error
 
 	if err != nil {
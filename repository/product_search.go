@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// maxSearchTerms keeps pathological queries from building huge tsqueries.
+const maxSearchTerms = 8
+
+// headlineOptions configure ts_headline snippets.
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, MaxWords=25, MinWords=8, MaxFragments=2"
+
+// ProductHighlight holds the search snippets and relevance of one product.
+type ProductHighlight struct {
+	ID          uint    `json:"-"`
+	Name        string  `json:"name,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Rank        float64 `json:"rank"`
+}
+
+// searchTerms splits a user query into words made of letters and digits.
+// Everything else is dropped, so the result is always safe to turn into a
+// tsquery. Apostrophes are removed rather than split on ("kid's" → "kids").
+func searchTerms(q string) []string {
+	q = strings.NewReplacer("'", "", "’", "").Replace(strings.ToLower(q))
+	words := strings.FieldsFunc(q, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	return words
+}
+
+// prefixTSQuery turns terms into "a:* & b:*", so every word must match and
+// the last one can still be half-typed.
+func prefixTSQuery(terms []string) string {
+	parts := make([]string, len(terms))
+	for i, t := range terms {
+		parts[i] = t + ":*"
+	}
+	return strings.Join(parts, " & ")
+}
+
+func (r ProductRepo) usesPostgres() bool {
+	return r.DB.Dialector.Name() == "postgres"
+}
+
+// applySearch adds the search condition to query and returns the relevance
+// ordering. Postgres uses the search_vector column; other databases (e.g.
+// SQLite in tests) fall back to case-insensitive LIKE, ranking name matches
+// first.
+func (r ProductRepo) applySearch(query *gorm.DB, terms []string) (*gorm.DB, productSort) {
+	if r.usesPostgres() {
+		tsq := prefixTSQuery(terms)
+		query = query.Where("products.search_vector @@ to_tsquery('english', ?)", tsq)
+		return query, productSort{
+			column: "ts_rank_cd(products.search_vector, to_tsquery('english', ?))",
+			vars:   []any{tsq},
+			desc:   true,
+			kind:   keyFloat,
+		}
+	}
+
+	for _, t := range terms {
+		like := "%" + escapeLike(t) + "%"
+		query = query.Where(
+			"(LOWER(products.name) LIKE ? ESCAPE '\\' OR LOWER(products.category) LIKE ? ESCAPE '\\' OR LOWER(products.description) LIKE ? ESCAPE '\\')",
+			like, like, like)
+	}
+
+	first := "%" + escapeLike(terms[0]) + "%"
+	return query, productSort{
+		column: "CASE WHEN LOWER(products.name) LIKE ? ESCAPE '\\' THEN 0 ELSE 1 END",
+		vars:   []any{first},
+		kind:   keyInt,
+	}
+}
+
+// SearchHighlights returns snippets with the matched words wrapped in
+// <mark> for the given products. It runs on one page of results only, since
+// ts_headline is expensive. The LIKE fallback has no snippets.
+func (r ProductRepo) SearchHighlights(ids []uint, q string) (map[uint]ProductHighlight, error) {
+	out := map[uint]ProductHighlight{}
+
+	terms := searchTerms(q)
+	if len(ids) == 0 || len(terms) == 0 || !r.usesPostgres() {
+		return out, nil
+	}
+
+	tsq := prefixTSQuery(terms)
+
+	var rows []ProductHighlight
+	err := r.DB.Table("products").
+		Select(`id,
+			ts_headline('english', coalesce(name, ''), to_tsquery('english', ?), ?) AS name,
+			ts_headline('english', coalesce(description, ''), to_tsquery('english', ?), ?) AS description,
+			ts_rank_cd(search_vector, to_tsquery('english', ?)) AS rank`,
+			tsq, headlineOptions, tsq, headlineOptions, tsq).
+		Where("id IN ?", ids).
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		out[row.ID] = row
+	}
+	return out, nil
+}
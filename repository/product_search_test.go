@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/pagination"
+)
+
+func TestSearchTerms(t *testing.T) {
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		{"Red Lamp", []string{"red", "lamp"}},
+		{"  kid's   toys ", []string{"kids", "toys"}},
+		{"kid’s", []string{"kids"}},
+		{"100%_cotton", []string{"100", "cotton"}},
+		{"a & b | !c:* <-> (d)", []string{"a", "b", "c", "d"}},
+		{"Über café", []string{"über", "café"}},
+		{"'; DROP TABLE products; --", []string{"drop", "table", "products"}},
+		{"1 2 3 4 5 6 7 8 9 10", []string{"1", "2", "3", "4", "5", "6", "7", "8"}},
+		{"", nil},
+		{"%_-!", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			if got := searchTerms(tt.q); !slices.Equal(got, tt.want) {
+				t.Errorf("searchTerms(%q) = %q, want %q", tt.q, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrefixTSQuery(t *testing.T) {
+	tests := []struct {
+		terms []string
+		want  string
+	}{
+		{[]string{"lamp"}, "lamp:*"},
+		{[]string{"red", "lam"}, "red:* & lam:*"},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := prefixTSQuery(tt.terms); got != tt.want {
+			t.Errorf("prefixTSQuery(%q) = %q, want %q", tt.terms, got, tt.want)
+		}
+	}
+}
+
+func TestSearchLikeFallback(t *testing.T) {
+	db := newTestDB(t, &models.Product{})
+	r := ProductRepo{DB: db}
+
+	for _, p := range []models.Product{
+		{Name: "Desk", Description: "Comes with a lamp and a red cover", Status: models.ProductActive},
+		{Name: "Red Lamp", Description: "Bright", Status: models.ProductActive},
+		{Name: "Lamp shade", Category: "Lighting", Status: models.ProductActive},
+		{Name: "Red mug", Description: "50% off", Status: models.ProductActive},
+		{Name: "Mug set", Description: "50 mugs", Status: models.ProductActive},
+		{Name: "Snake_case mug", Status: models.ProductActive},
+		{Name: "Snakes mug", Status: models.ProductActive},
+		{Name: "Red lamp draft", Status: models.ProductDraft},
+	} {
+		p.Attributes = json.RawMessage(`{}`)
+		if err := db.Create(&p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	names := func(products []ProductRow) []string {
+		var out []string
+		for _, p := range products {
+			out = append(out, p.Name)
+		}
+		return out
+	}
+
+	tests := []struct {
+		q    string
+		want []string
+	}{
+		// Every word must match, name matches on the first word come first
+		{"red lamp", []string{"Red Lamp", "Desk"}},
+		{"lamp", []string{"Red Lamp", "Lamp shade", "Desk"}},
+		{"LIGHT", []string{"Lamp shade"}},
+		{"lamp chair", nil},
+		// Half-typed words match too
+		{"sha", []string{"Lamp shade"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.q, func(t *testing.T) {
+			rows, total, err := r.ListProductsFiltered(pagination.Request{Page: 1, Limit: 10}, ProductFilter{Query: tt.q})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := names(rows); !slices.Equal(got, tt.want) || total != int64(len(tt.want)) {
+				t.Errorf("results = %q (total %d), want %q", got, total, tt.want)
+			}
+		})
+	}
+
+	// searchTerms strips wildcards, but applySearch must not rely on that
+	escaped := []struct {
+		term string
+		want []string
+	}{
+		{"50%", []string{"Red mug"}},
+		{"snake_", []string{"Snake_case mug"}},
+	}
+	for _, tt := range escaped {
+		t.Run("literal "+tt.term, func(t *testing.T) {
+			query, _ := r.applySearch(db.Model(&models.Product{}), []string{tt.term})
+
+			var rows []models.Product
+			if err := query.Order("id").Find(&rows).Error; err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, p := range rows {
+				got = append(got, p.Name)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("results = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
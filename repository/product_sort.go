@@ -0,0 +1,137 @@
+package repository
+
+import (
+	"sort"
+	"strconv"
+	"time"
+
+	"futuremarket/pagination"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// keyKind is the type of a sort key, needed to read it back from a cursor.
+type keyKind int
+
+const (
+	keyTime keyKind = iota
+	keyInt
+	keyFloat
+)
+
+// productSort describes one ordering of the product listing. The id
+// tie-breaker runs in the same direction as the main key so the order is
+// total and stable, which keyset cursors rely on.
+type productSort struct {
+	column string // SQL expression of the sort key
+	vars   []any  // arguments of column, if any
+	desc   bool
+	kind   keyKind
+	join   func(*gorm.DB) *gorm.DB // extra joins the key needs, may be nil
+}
+
+// unitsSoldColumn is the best_selling sort key: units across all orders.
+const unitsSoldColumn = "COALESCE(sales.units_sold, 0)"
+
+// productSorts is the whitelist of sort= values.
+var productSorts = map[string]productSort{
+	"newest":       {column: "products.created_at", desc: true, kind: keyTime},
+	"price_asc":    {column: "products.price_cents", kind: keyInt},
+	"price_desc":   {column: "products.price_cents", desc: true, kind: keyInt},
+	"rating":       {column: "products.average_rating", desc: true, kind: keyFloat},
+	"review_count": {column: "products.review_count", desc: true, kind: keyInt},
+	"best_selling": {column: unitsSoldColumn, desc: true, kind: keyInt, join: joinUnitsSold},
+}
+
+// DefaultProductSort applies when neither sort= nor q= is given.
+const DefaultProductSort = "newest"
+
+// RelevanceSort names the search ranking used for q= without sort=.
+const RelevanceSort = "relevance"
+
+// IsProductSort reports whether key is an allowed sort= value.
+func IsProductSort(key string) bool {
+	_, ok := productSorts[key]
+	return ok
+}
+
+// ProductSortKeys lists the allowed sort= values.
+func ProductSortKeys() []string {
+	keys := make([]string, 0, len(productSorts))
+	for k := range productSorts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// SortName is the ordering a listing with this filter uses: sort= when
+// given, otherwise relevance for searches and newest for everything else.
+func (f ProductFilter) SortName() string {
+	switch {
+	case f.Sort != "":
+		return f.Sort
+	case len(searchTerms(f.Query)) > 0:
+		return RelevanceSort
+	default:
+		return DefaultProductSort
+	}
+}
+
+// orderBy returns the ORDER BY for s, flipped when reading backwards.
+func (s productSort) orderBy(backward bool) clause.OrderBy {
+	dir := "ASC"
+	if s.desc != backward {
+		dir = "DESC"
+	}
+	return clause.OrderBy{Expression: clause.Expr{
+		SQL:  s.column + " " + dir + ", products.id " + dir,
+		Vars: s.vars,
+	}}
+}
+
+// after restricts query to the rows past cursor c in listing order (before
+// it for prev cursors), comparing (key, id) as a row value.
+func (s productSort) after(query *gorm.DB, c *pagination.Cursor) (*gorm.DB, error) {
+	key, err := parseSortKey(s.kind, c.Key)
+	if err != nil {
+		return nil, pagination.ErrInvalidCursor
+	}
+
+	op := ">"
+	if s.desc != c.Prev {
+		op = "<"
+	}
+
+	vars := append(append([]any{}, s.vars...), key, c.ID)
+	return query.Where("("+s.column+", products.id) "+op+" (?, ?)", vars...), nil
+}
+
+// selectKey selects the products with their sort key as sort_key.
+func (s productSort) selectKey(query *gorm.DB) *gorm.DB {
+	return query.Select("products.*, "+s.column+" AS sort_key", s.vars...)
+}
+
+// parseSortKey reads a sort key written by a cursor back into its type.
+func parseSortKey(kind keyKind, v string) (any, error) {
+	switch kind {
+	case keyTime:
+		return time.Parse(time.RFC3339Nano, v)
+	case keyInt:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return strconv.ParseFloat(v, 64)
+	}
+}
+
+// joinUnitsSold attaches the number of units sold per product, summed over
+// order_items.
+func joinUnitsSold(query *gorm.DB) *gorm.DB {
+	return query.Joins(`LEFT JOIN (
+		SELECT product_id, SUM(quantity) AS units_sold
+		FROM order_items
+		WHERE deleted_at IS NULL
+		GROUP BY product_id
+	) AS sales ON sales.product_id = products.id`)
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/pagination"
+
+	"gorm.io/gorm/clause"
+)
+
+func TestProductFilterSortName(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter ProductFilter
+		want   string
+	}{
+		{"default", ProductFilter{}, DefaultProductSort},
+		{"search", ProductFilter{Query: "lamp"}, RelevanceSort},
+		{"search without words", ProductFilter{Query: "%%"}, DefaultProductSort},
+		{"explicit sort wins over search", ProductFilter{Query: "lamp", Sort: "price_asc"}, "price_asc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.SortName(); got != tt.want {
+				t.Errorf("SortName = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if !IsProductSort("best_selling") || IsProductSort(RelevanceSort) || IsProductSort("name; DROP TABLE products") {
+		t.Error("IsProductSort accepts the wrong keys")
+	}
+}
+
+func TestProductSortOrderBy(t *testing.T) {
+	tests := []struct {
+		sort     string
+		backward bool
+		want     string
+	}{
+		{"price_asc", false, "products.price_cents ASC, products.id ASC"},
+		{"price_asc", true, "products.price_cents DESC, products.id DESC"},
+		{"newest", false, "products.created_at DESC, products.id DESC"},
+		{"newest", true, "products.created_at ASC, products.id ASC"},
+	}
+
+	for _, tt := range tests {
+		expr := productSorts[tt.sort].orderBy(tt.backward).Expression.(clause.Expr)
+		if expr.SQL != tt.want {
+			t.Errorf("%s (backward %v): ORDER BY %s, want %s", tt.sort, tt.backward, expr.SQL, tt.want)
+		}
+	}
+}
+
+func TestListProductsSorted(t *testing.T) {
+	db := newTestDB(t, &models.Product{}, &models.OrderItem{})
+	r := ProductRepo{DB: db}
+
+	start := time.Now().Add(-time.Hour)
+	products := []models.Product{
+		{Name: "A", PriceCents: 300, AverageRating: 4.5, ReviewCount: 2},
+		{Name: "B", PriceCents: 100, AverageRating: 3, ReviewCount: 10},
+		{Name: "C", PriceCents: 200, AverageRating: 5, ReviewCount: 1},
+		{Name: "D", PriceCents: 100, AverageRating: 4.5, ReviewCount: 0},
+	}
+	for i := range products {
+		products[i].Status = models.ProductActive
+		products[i].Attributes = json.RawMessage(`{}`)
+		products[i].CreatedAt = start.Add(time.Duration(i) * time.Minute)
+		if err := db.Create(&products[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// C sold 5 units over two orders, A sold 3; a deleted line doesn't count
+	db.Create(&[]models.OrderItem{
+		{OrderID: 1, ProductID: products[2].ID, Quantity: 2},
+		{OrderID: 2, ProductID: products[2].ID, Quantity: 3},
+		{OrderID: 2, ProductID: products[0].ID, Quantity: 3},
+		{OrderID: 3, ProductID: products[3].ID, Quantity: 9},
+	})
+	db.Where("order_id = 3").Delete(&models.OrderItem{})
+
+	tests := []struct {
+		sort string
+		want []string
+	}{
+		{"", []string{"D", "C", "B", "A"}},
+		{"newest", []string{"D", "C", "B", "A"}},
+		// Ties are broken by id in the same direction
+		{"price_asc", []string{"B", "D", "C", "A"}},
+		{"price_desc", []string{"A", "C", "D", "B"}},
+		{"rating", []string{"C", "D", "A", "B"}},
+		{"review_count", []string{"B", "A", "C", "D"}},
+		{"best_selling", []string{"C", "A", "D", "B"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.sort, func(t *testing.T) {
+			rows, total, err := r.ListProductsFiltered(pagination.Request{Page: 1, Limit: 10}, ProductFilter{Sort: tt.sort})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, p := range rows {
+				got = append(got, p.Name)
+			}
+			if !slices.Equal(got, tt.want) || total != 4 {
+				t.Errorf("order = %q (total %d), want %q", got, total, tt.want)
+			}
+		})
+	}
+}
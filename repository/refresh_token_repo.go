@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type RefreshTokenRepo struct {
+	DB *gorm.DB
+}
+
+// Create stores a new refresh token row.
+func (r RefreshTokenRepo) Create(token *models.RefreshToken) error {
+	return r.DB.Create(token).Error
+}
+
+// FindByHash looks up a refresh token by the SHA-256 of its raw value.
+func (r RefreshTokenRepo) FindByHash(hash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.DB.Where("token_hash = ?", hash).First(&token).Error
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed flags a token as rotated. It returns false when another request
+// already used it, which the caller must treat as reuse.
+func (r RefreshTokenRepo) MarkUsed(id uint, at time.Time) (bool, error) {
+	res := r.DB.Model(&models.RefreshToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Update("used_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// RevokeFamily revokes every token issued from the same login.
+func (r RefreshTokenRepo) RevokeFamily(familyID string) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", familyID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser revokes every refresh token a user holds.
+func (r RefreshTokenRepo) RevokeAllForUser(userID uint) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUserExcept revokes every refresh token a user holds apart from
+// one family (typically the caller's current login).
+func (r RefreshTokenRepo) RevokeAllForUserExcept(userID uint, familyID string) error {
+	return r.DB.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND family_id <> ? AND revoked_at IS NULL", userID, familyID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"futuremarket/models"
+	"futuremarket/pagination"
 
 	"gorm.io/gorm"
 )
@@ -108,21 +109,26 @@ func (r *ReviewRepo) UpdateProductRating(productID uint, avg float64, count int6
 		}).Error
 }
 
-func (r ReviewRepo) ListReviewsPaginated(productID uint, page, limit int) ([]models.Review, int64, error) {
+// ListReviewsPaginated returns one page of a product's reviews, newest
+// first. The total is 0 when req.SkipCount is set.
+func (r ReviewRepo) ListReviewsPaginated(productID uint, req pagination.Request) ([]models.Review, int64, error) {
     var reviews []models.Review
     var total int64
 
     query := r.DB.Model(&models.Review{}).Where("product_id = ?", productID)
 
-    query.Count(&total)
+    if !req.SkipCount {
+        if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+            return nil, 0, err
+        }
+    }
 
-    offset := (page - 1) * limit
+    query, err := newestFirst(query, "reviews", req)
+    if err != nil {
+        return nil, 0, err
+    }
 
-    err := r.DB.Where("product_id = ?", productID).
-        Offset(offset).
-        Limit(limit).
-        Order("created_at DESC").
-        Find(&reviews).Error
+    err = query.Find(&reviews).Error
 
     return reviews, total, err
 }
@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+type SessionRepo struct {
+	DB *gorm.DB
+}
+
+// Create stores a new session.
+func (r SessionRepo) Create(session *models.Session) error {
+	return r.DB.Create(session).Error
+}
+
+// GetByID fetches a session by primary key.
+func (r SessionRepo) GetByID(id uint) (models.Session, error) {
+	var session models.Session
+	err := r.DB.First(&session, id).Error
+	return session, err
+}
+
+// GetByFamily fetches the session that owns a refresh token family.
+func (r SessionRepo) GetByFamily(familyID string) (models.Session, error) {
+	var session models.Session
+	err := r.DB.Where("family_id = ?", familyID).First(&session).Error
+	return session, err
+}
+
+// ListActiveForUser returns a user's sessions that haven't been revoked,
+// most recently used first.
+func (r SessionRepo) ListActiveForUser(userID uint) ([]models.Session, error) {
+	var sessions []models.Session
+	err := r.DB.
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Order("last_seen_at DESC").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// Touch records activity on a session.
+func (r SessionRepo) Touch(id uint, at time.Time, ip string) error {
+	updates := map[string]interface{}{"last_seen_at": at}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+	return r.DB.Model(&models.Session{}).
+		Where("id = ?", id).
+		Updates(updates).Error
+}
+
+// Revoke marks one session as signed out.
+func (r SessionRepo) Revoke(id uint) error {
+	return r.DB.Model(&models.Session{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUser signs out every session a user has.
+func (r SessionRepo) RevokeAllForUser(userID uint) error {
+	return r.DB.Model(&models.Session{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// RevokeAllForUserExcept signs out every session apart from keepID.
+func (r SessionRepo) RevokeAllForUserExcept(userID, keepID uint) error {
+	return r.DB.Model(&models.Session{}).
+		Where("user_id = ? AND id <> ? AND revoked_at IS NULL", userID, keepID).
+		Update("revoked_at", time.Now()).Error
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"time"
+
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+// TwoFactorRepo stores TOTP secrets on users and their recovery codes.
+type TwoFactorRepo struct {
+	DB *gorm.DB
+}
+
+// SetPendingSecret stores a secret that hasn't been confirmed yet.
+func (r TwoFactorRepo) SetPendingSecret(userID uint, secret string) error {
+	return r.DB.Model(&models.User{}).
+		Where("id = ?", userID).
+		Update("totp_pending_secret", secret).Error
+}
+
+// Enable promotes the pending secret to the active one.
+func (r TwoFactorRepo) Enable(userID uint, secret string, step int64, at time.Time) error {
+	return r.DB.Model(&models.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]interface{}{
+			"totp_secret":         secret,
+			"totp_pending_secret": "",
+			"totp_enabled_at":     at,
+			"totp_last_step":      step,
+		}).Error
+}
+
+// Disable removes TOTP and all recovery codes for a user.
+func (r TwoFactorRepo) Disable(userID uint) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).
+			Where("id = ?", userID).
+			Updates(map[string]interface{}{
+				"totp_secret":         "",
+				"totp_pending_secret": "",
+				"totp_enabled_at":     nil,
+				"totp_last_step":      0,
+			}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error
+	})
+}
+
+// AdvanceStep records the TOTP step just used. It returns false if that step
+// (or a later one) was already used, i.e. the code is being replayed.
+func (r TwoFactorRepo) AdvanceStep(userID uint, step int64) (bool, error) {
+	res := r.DB.Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ReplaceRecoveryCodes drops any old codes and stores new hashes.
+func (r TwoFactorRepo) ReplaceRecoveryCodes(userID uint, hashes []string) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+
+		codes := make([]models.RecoveryCode, 0, len(hashes))
+		for _, h := range hashes {
+			codes = append(codes, models.RecoveryCode{UserID: userID, CodeHash: h})
+		}
+		return tx.Create(&codes).Error
+	})
+}
+
+// UseRecoveryCode consumes a matching unused code. It returns false if no
+// such code exists.
+func (r TwoFactorRepo) UseRecoveryCode(userID uint, hash string) (bool, error) {
+	res := r.DB.Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hash).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
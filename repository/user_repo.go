@@ -1,8 +1,9 @@
 package repository
 
 import (
+	"time"
+
 	"futuremarket/models"
-	
 
 	"gorm.io/gorm"
 )
@@ -29,3 +30,108 @@ func (ur UserRepo) GetUserByEmail(email string) (models.User, error) {
 	
 	return *existing, nil
 }
+
+// GetUserByID fetches a user by primary key.
+func (ur UserRepo) GetUserByID(id uint) (models.User, error) {
+	var user models.User
+	err := ur.DB.First(&user, id).Error
+	return user, err
+}
+
+// UpdatePasswordHash replaces a user's bcrypt hash.
+func (ur UserRepo) UpdatePasswordHash(id uint, hash string) error {
+	return ur.DB.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("password_hash", hash).Error
+}
+
+// MarkEmailVerified stamps email_verified_at, but only while the account
+// still uses the address that was verified.
+func (ur UserRepo) MarkEmailVerified(id uint, email string, at time.Time) (bool, error) {
+	res := ur.DB.Model(&models.User{}).
+		Where("id = ? AND email = ?", id, email).
+		Update("email_verified_at", at)
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
+
+// ListUsers returns a page of users, optionally filtered by a case-insensitive
+// search on email or name.
+func (ur UserRepo) ListUsers(page, limit int, search string) ([]models.User, int64, error) {
+	var users []models.User
+	var total int64
+
+	query := ur.DB.Model(&models.User{})
+	if search != "" {
+		like := "%" + search + "%"
+		query = query.Where("email ILIKE ? OR name ILIKE ?", like, like)
+	}
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * limit
+
+	err := query.
+		Order("id ASC").
+		Offset(offset).
+		Limit(limit).
+		Find(&users).Error
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// UpdateRole changes a user's role.
+func (ur UserRepo) UpdateRole(id uint, role string) error {
+	return ur.DB.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("role", role).Error
+}
+
+// SetDisabledAt disables (non-nil) or re-enables (nil) a user.
+func (ur UserRepo) SetDisabledAt(id uint, at *time.Time) error {
+	return ur.DB.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("disabled_at", at).Error
+}
+
+// Delete soft-deletes a user.
+func (ur UserRepo) Delete(id uint) error {
+	return ur.DB.Delete(&models.User{}, id).Error
+}
+
+// UpdateName changes a user's display name.
+func (ur UserRepo) UpdateName(id uint, name string) error {
+	return ur.DB.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("name", name).Error
+}
+
+// SetPendingEmail stores an address waiting for verification.
+func (ur UserRepo) SetPendingEmail(id uint, email string) error {
+	return ur.DB.Model(&models.User{}).
+		Where("id = ?", id).
+		Update("pending_email", email).Error
+}
+
+// ApplyPendingEmail swaps in the pending address once it has been verified.
+// It returns false if the pending address changed in the meantime.
+func (ur UserRepo) ApplyPendingEmail(id uint, email string, at time.Time) (bool, error) {
+	res := ur.DB.Model(&models.User{}).
+		Where("id = ? AND pending_email = ?", id, email).
+		Updates(map[string]interface{}{
+			"email":             email,
+			"pending_email":     "",
+			"email_verified_at": at,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 1, nil
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"futuremarket/models"
+
+	"gorm.io/gorm"
+)
+
+// VariantRepo stores product variants and their stock rows.
+type VariantRepo struct {
+	DB *gorm.DB
+}
+
+// ListByProduct returns a product's variants in the order they were added.
+func (r VariantRepo) ListByProduct(productID uint) ([]models.ProductVariant, error) {
+	var variants []models.ProductVariant
+	err := r.DB.Where("product_id = ?", productID).Order("id").Find(&variants).Error
+	return variants, err
+}
+
+// CountByProduct returns how many variants a product has.
+func (r VariantRepo) CountByProduct(productID uint) (int64, error) {
+	var n int64
+	err := r.DB.Model(&models.ProductVariant{}).Where("product_id = ?", productID).Count(&n).Error
+	return n, err
+}
+
+// GetForProduct returns a variant only if it belongs to productID.
+func (r VariantRepo) GetForProduct(productID, variantID uint) (models.ProductVariant, error) {
+	var v models.ProductVariant
+	err := r.DB.Where("product_id = ?", productID).First(&v, variantID).Error
+	return v, err
+}
+
+// SKUTaken reports whether another live variant uses sku.
+func (r VariantRepo) SKUTaken(sku string, exceptID uint) (bool, error) {
+	var n int64
+	err := r.DB.Model(&models.ProductVariant{}).
+		Where("sku = ? AND id <> ?", sku, exceptID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// OptionsTaken reports whether another variant of the product has the
+// same option values.
+func (r VariantRepo) OptionsTaken(productID uint, options string, exceptID uint) (bool, error) {
+	var n int64
+	err := r.DB.Model(&models.ProductVariant{}).
+		Where("product_id = ? AND options = ? AND id <> ?", productID, options, exceptID).
+		Count(&n).Error
+	return n > 0, err
+}
+
+// Create inserts a variant together with its stock row.
+func (r VariantRepo) Create(v *models.ProductVariant, quantity int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(v).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.Stock{
+			ProductID: v.ProductID,
+			VariantID: &v.ID,
+			Quantity:  quantity,
+		}).Error
+	})
+}
+
+// Update saves a variant and, when quantity is set, its stock.
+func (r VariantRepo) Update(v *models.ProductVariant, quantity *int) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(v).Error; err != nil {
+			return err
+		}
+		if quantity == nil {
+			return nil
+		}
+		return tx.Model(&models.Stock{}).
+			Where("variant_id = ?", v.ID).
+			Update("quantity", *quantity).Error
+	})
+}
+
+// Delete soft-deletes a variant. Its stock row goes with it; order items
+// keep their variant_id and SKU.
+func (r VariantRepo) Delete(v models.ProductVariant) error {
+	return r.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("variant_id = ?", v.ID).Delete(&models.Stock{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&v).Error
+	})
+}
+
+// StockByVariant returns the stock of each given variant.
+func (r VariantRepo) StockByVariant(variantIDs []uint) (map[uint]int, error) {
+	out := map[uint]int{}
+	if len(variantIDs) == 0 {
+		return out, nil
+	}
+
+	var stocks []models.Stock
+	if err := r.DB.Where("variant_id IN ?", variantIDs).Find(&stocks).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range stocks {
+		out[*s.VariantID] = s.Quantity
+	}
+	return out, nil
+}
+
+// GetStock returns a variant's stock row.
+func (r VariantRepo) GetStock(variantID uint) (*models.Stock, error) {
+	var stock models.Stock
+	err := r.DB.Where("variant_id = ?", variantID).First(&stock).Error
+	if err != nil {
+		return nil, err
+	}
+	return &stock, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/gorilla/mux"
 
 	"futuremarket/handlers"
+	"futuremarket/jwtkeys"
 	"futuremarket/middleware"
 	"futuremarket/service"
 )
@@ -13,27 +14,61 @@ import (
 func SetupRouter(
 	authHandler *handlers.AuthHandler,
 	productHandler *handlers.ProductHandler,
+	categoryHandler *handlers.CategoryHandler,
+	attributeHandler *handlers.AttributeHandler,
 	cartHandler *handlers.CartHandler,
 	orderHandler *handlers.OrderHandler,
 	reviewHandler *handlers.ReviewHandler,
+	passwordHandler *handlers.PasswordHandler,
+	verificationHandler *handlers.EmailVerificationHandler,
+	adminUserHandler *handlers.AdminUserHandler,
+	accountHandler *handlers.AccountHandler,
+	twoFactorHandler *handlers.TwoFactorHandler,
+	sessionHandler *handlers.SessionHandler,
+	jwksHandler *handlers.JWKSHandler,
+	oidcHandler *handlers.OIDCHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
+	auditHandler *handlers.AuditHandler,
+	keys *jwtkeys.Keyset,
 	blacklistService service.BlacklistService,
+	sessionService service.SessionService,
+	apiKeyService service.APIKeyService,
 ) *mux.Router {
 
 	r := mux.NewRouter()
 
+	// Every request gets an ID (X-Request-ID) that ends up in the audit log
+	r.Use(middleware.RequestID)
+
 	// Health Check
 	r.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte("Welcome to the FutureMarket API"))
 	})
 
+	// PUBLIC SIGNING KEYS
+	r.HandleFunc("/.well-known/jwks.json", jwksHandler.JWKS).Methods(http.MethodGet)
+
 	// PUBLIC AUTH ROUTES
 	r.HandleFunc("/api/v1/register", authHandler.Register).Methods(http.MethodPost)
 	r.HandleFunc("/api/v1/login", authHandler.Login).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/login/2fa", authHandler.LoginTwoFactor).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/token/refresh", authHandler.Refresh).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/password/forgot", passwordHandler.Forgot).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/password/reset", passwordHandler.Reset).Methods(http.MethodPost)
+	r.HandleFunc("/api/v1/email/verify", verificationHandler.Verify).Methods(http.MethodPost)
+
+	// SIGN IN WITH AN EXTERNAL PROVIDER (only when OIDC_ISSUER is set)
+	if oidcHandler != nil {
+		r.HandleFunc("/api/v1/oidc/login", oidcHandler.Login).Methods(http.MethodGet)
+		r.HandleFunc("/api/v1/oidc/callback", oidcHandler.Callback).Methods(http.MethodGet)
+	}
 
 	// PUBLIC PRODUCT ROUTES
 	r.HandleFunc("/api/v1/products", productHandler.ListProducts).Methods(http.MethodGet)
 	r.HandleFunc("/api/v1/products/{id}", productHandler.GetProductByID).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/categories", categoryHandler.GetTree).Methods(http.MethodGet)
+	r.HandleFunc("/api/v1/categories/{id}/attributes", attributeHandler.ListAttributes).Methods(http.MethodGet)
 
 	// PUBLIC REVIEWS
 	r.HandleFunc("/api/v1/products/{id}/reviews", reviewHandler.ListReviews).Methods(http.MethodGet)
@@ -45,43 +80,101 @@ func SetupRouter(
 
 	protected.Use(
 		middleware.AuthMiddlewareConfig{
+			Keys:             keys,
 			BlacklistService: blacklistService,
+			Sessions:         sessionService,
+			APIKeys:          apiKeyService,
 		}.AuthMiddleware,
 	)
 
 	// LOGOUT
 	protected.HandleFunc("/logout", authHandler.Logout).Methods(http.MethodPost)
 
+	// OWN ACCOUNT
+	protected.HandleFunc("/me", accountHandler.GetMe).Methods(http.MethodGet)
+	protected.HandleFunc("/me", accountHandler.UpdateMe).Methods(http.MethodPatch)
+	protected.HandleFunc("/me", accountHandler.DeleteMe).Methods(http.MethodDelete)
+	protected.HandleFunc("/me/export", accountHandler.ExportMe).Methods(http.MethodGet)
+	protected.HandleFunc("/me/password", accountHandler.ChangePassword).Methods(http.MethodPost)
+
+	// SIGNED-IN DEVICES
+	protected.HandleFunc("/me/sessions", sessionHandler.ListSessions).Methods(http.MethodGet)
+	protected.HandleFunc("/me/sessions", sessionHandler.RevokeAllSessions).Methods(http.MethodDelete)
+	protected.HandleFunc("/me/sessions/{id}", sessionHandler.RevokeSession).Methods(http.MethodDelete)
+
+	// TWO-FACTOR AUTHENTICATION
+	protected.HandleFunc("/me/2fa/enroll", twoFactorHandler.Enroll).Methods(http.MethodPost)
+	protected.HandleFunc("/me/2fa/confirm", twoFactorHandler.Confirm).Methods(http.MethodPost)
+	protected.HandleFunc("/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes).Methods(http.MethodPost)
+	protected.HandleFunc("/me/2fa/disable", twoFactorHandler.Disable).Methods(http.MethodPost)
+
+	// EMAIL VERIFICATION
+	protected.HandleFunc("/email/verify/resend", verificationHandler.Resend).Methods(http.MethodPost)
+
 	// CART
-	protected.HandleFunc("/cart", cartHandler.GetCart).Methods(http.MethodGet)
-	protected.HandleFunc("/cart", cartHandler.AddToCart).Methods(http.MethodPost)
-	protected.HandleFunc("/cart/{product_id}", cartHandler.UpdateCartItem).Methods(http.MethodPatch)
-	protected.HandleFunc("/cart/{product_id}", cartHandler.RemoveCartItem).Methods(http.MethodDelete)
+	protected.Handle("/cart", withPermission("manage:cart", cartHandler.GetCart)).Methods(http.MethodGet)
+	protected.Handle("/cart", withPermission("manage:cart", cartHandler.AddToCart)).Methods(http.MethodPost)
+	protected.Handle("/cart/{product_id}", withPermission("manage:cart", cartHandler.UpdateCartItem)).Methods(http.MethodPatch)
+	protected.Handle("/cart/{product_id}", withPermission("manage:cart", cartHandler.RemoveCartItem)).Methods(http.MethodDelete)
 
 	// ORDERS
-	protected.HandleFunc("/checkout", orderHandler.Checkout).Methods(http.MethodPost)
-	protected.HandleFunc("/orders", orderHandler.ListOrders).Methods(http.MethodGet)
-	protected.HandleFunc("/orders/paginated", orderHandler.ListOrdersPaginated).Methods(http.MethodGet)
+	protected.Handle("/checkout", withPermission("checkout", orderHandler.Checkout)).Methods(http.MethodPost)
+	protected.Handle("/orders", withPermission("read:orders", orderHandler.ListOrders)).Methods(http.MethodGet)
+	protected.Handle("/orders/paginated", withPermission("read:orders", orderHandler.ListOrdersPaginated)).Methods(http.MethodGet)
 
 	// AUTHENTICATED REVIEW ROUTES
-	protected.HandleFunc("/products/{id}/reviews", reviewHandler.CreateOrUpdateReview).Methods(http.MethodPost)
-
-	// Public reviews
-	r.HandleFunc("/api/v1/products/{id}/reviews", reviewHandler.ListReviews).Methods(http.MethodGet)
-
-	// Authenticated reviews
-	protected.HandleFunc("/products/{id}/reviews", reviewHandler.CreateOrUpdateReview).Methods(http.MethodPost)
+	protected.Handle("/products/{id}/reviews", withPermission("write:review", reviewHandler.CreateOrUpdateReview)).Methods(http.MethodPost)
 
 	// ---------------------------------------
 	// ADMIN ROUTES
 	// ---------------------------------------
 	admin := protected.PathPrefix("/admin").Subrouter()
 
-	// IMPORTANT: AdminMiddleware added AFTER AuthMiddleware
+	// IMPORTANT: AdminMiddleware added AFTER AuthMiddleware. It only needs a
+	// caller; withPermission on each route decides which roles get in.
 	admin.Use(middleware.AdminMiddleware)
 
-	admin.HandleFunc("/products", productHandler.CreateProduct).Methods(http.MethodPost)
-	admin.HandleFunc("/products/{id}", productHandler.UpdateProduct).Methods(http.MethodPatch)
+	admin.Handle("/products", withPermission("manage:products", productHandler.CreateProduct)).Methods(http.MethodPost)
+	admin.Handle("/products/import", withPermission("manage:products", productHandler.ImportProducts)).Methods(http.MethodPost)
+	admin.Handle("/products/export", withPermission("manage:products", productHandler.ExportProducts)).Methods(http.MethodGet)
+	admin.Handle("/products/{id}", withPermission("manage:products", productHandler.UpdateProduct)).Methods(http.MethodPatch)
+	admin.Handle("/products/{id}", withPermission("manage:products", productHandler.ArchiveProduct)).Methods(http.MethodDelete)
+	admin.Handle("/products/{id}/variants", withPermission("manage:products", productHandler.CreateVariant)).Methods(http.MethodPost)
+	admin.Handle("/products/{id}/variants/{variant_id}", withPermission("manage:products", productHandler.UpdateVariant)).Methods(http.MethodPatch)
+	admin.Handle("/products/{id}/variants/{variant_id}", withPermission("manage:products", productHandler.DeleteVariant)).Methods(http.MethodDelete)
+	admin.Handle("/products/{id}/images", withPermission("manage:products", productHandler.UploadImages)).Methods(http.MethodPost)
+	admin.Handle("/products/{id}/images/order", withPermission("manage:products", productHandler.ReorderImages)).Methods(http.MethodPut)
+	admin.Handle("/products/{id}/images/{image_id}", withPermission("manage:products", productHandler.DeleteImage)).Methods(http.MethodDelete)
+	admin.Handle("/categories", withPermission("manage:products", categoryHandler.CreateCategory)).Methods(http.MethodPost)
+	admin.Handle("/categories/{id}", withPermission("manage:products", categoryHandler.UpdateCategory)).Methods(http.MethodPatch)
+	admin.Handle("/categories/{id}", withPermission("manage:products", categoryHandler.DeleteCategory)).Methods(http.MethodDelete)
+	admin.Handle("/categories/{id}/attributes", withPermission("manage:products", attributeHandler.CreateAttribute)).Methods(http.MethodPost)
+	admin.Handle("/attributes/{id}", withPermission("manage:products", attributeHandler.UpdateAttribute)).Methods(http.MethodPatch)
+	admin.Handle("/attributes/{id}", withPermission("manage:products", attributeHandler.DeleteAttribute)).Methods(http.MethodDelete)
+
+	// ADMIN USER MANAGEMENT
+	admin.Handle("/users", withPermission("manage:users", adminUserHandler.ListUsers)).Methods(http.MethodGet)
+	admin.Handle("/users/{id}", withPermission("manage:users", adminUserHandler.GetUser)).Methods(http.MethodGet)
+	admin.Handle("/users/{id}", withPermission("manage:users", adminUserHandler.DeleteUser)).Methods(http.MethodDelete)
+	admin.Handle("/users/{id}/role", withPermission("manage:users", adminUserHandler.ChangeRole)).Methods(http.MethodPatch)
+	admin.Handle("/users/{id}/disable", withPermission("manage:users", adminUserHandler.DisableUser)).Methods(http.MethodPost)
+	admin.Handle("/users/{id}/enable", withPermission("manage:users", adminUserHandler.EnableUser)).Methods(http.MethodPost)
+	admin.Handle("/users/{id}/unlock", withPermission("manage:users", adminUserHandler.Unlock)).Methods(http.MethodPost)
+
+	// API KEYS (server-to-server integrations)
+	admin.Handle("/api-keys", withPermission("manage:api_keys", apiKeyHandler.ListKeys)).Methods(http.MethodGet)
+	admin.Handle("/api-keys", withPermission("manage:api_keys", apiKeyHandler.CreateKey)).Methods(http.MethodPost)
+	admin.Handle("/api-keys/{id}", withPermission("manage:api_keys", apiKeyHandler.GetKey)).Methods(http.MethodGet)
+	admin.Handle("/api-keys/{id}", withPermission("manage:api_keys", apiKeyHandler.RevokeKey)).Methods(http.MethodDelete)
+
+	// AUDIT LOG
+	admin.Handle("/audit", withPermission("read:audit", auditHandler.ListEvents)).Methods(http.MethodGet)
 
 	return r
 }
+
+// withPermission wraps a handler so it only runs when the caller's role is
+// allowed to perform action (see config.RolePermission).
+func withPermission(action string, h http.HandlerFunc) http.Handler {
+	return middleware.RequirePermission(action)(h)
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+var ErrAdminAccountDeletion = errors.New("admin accounts can't be deleted here; ask another admin to change your role first")
+
+// AccountExport is the archive a user receives for a data export request.
+type AccountExport struct {
+	ExportedAt       time.Time             `json:"exported_at"`
+	Profile          UserView              `json:"profile"`
+	TwoFactorEnabled bool                  `json:"two_factor_enabled"`
+	Carts            []models.Cart         `json:"carts"`
+	Orders           []models.Order        `json:"orders"`
+	Reviews          []models.Review       `json:"reviews"`
+	Sessions         []SessionView         `json:"sessions"`
+	LinkedIdentities []models.UserIdentity `json:"linked_identities"`
+}
+
+// AccountDataService answers data subject requests: export everything we
+// hold about a user, or erase it.
+type AccountDataService struct {
+	Repo     repository.AccountDataRepo
+	UserRepo repository.UserRepo
+}
+
+// Export collects the user's profile, carts, orders with items, reviews,
+// sessions and linked sign-in accounts.
+func (s AccountDataService) Export(userID uint) (AccountExport, error) {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	export := AccountExport{
+		ExportedAt:       time.Now().UTC(),
+		Profile:          NewUserView(user),
+		TwoFactorEnabled: user.TOTPEnabledAt != nil,
+	}
+
+	if export.Carts, err = s.Repo.ListCarts(userID); err != nil {
+		return AccountExport{}, err
+	}
+	if export.Orders, err = s.Repo.ListOrders(userID); err != nil {
+		return AccountExport{}, err
+	}
+	if export.Reviews, err = s.Repo.ListReviews(userID); err != nil {
+		return AccountExport{}, err
+	}
+	if export.LinkedIdentities, err = s.Repo.ListIdentities(userID); err != nil {
+		return AccountExport{}, err
+	}
+
+	sessions, err := s.Repo.ListSessions(userID)
+	if err != nil {
+		return AccountExport{}, err
+	}
+	export.Sessions = make([]SessionView, 0, len(sessions))
+	for _, sess := range sessions {
+		export.Sessions = append(export.Sessions, SessionView{
+			ID:         sess.ID,
+			DeviceName: sess.DeviceName,
+			IP:         sess.IP,
+			UserAgent:  sess.UserAgent,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: sess.LastSeenAt,
+		})
+	}
+
+	return export, nil
+}
+
+// DeleteAccount anonymises the user after re-checking their password
+// (accounts without a password, e.g. created through OIDC, skip that step).
+// Orders and reviews are kept without personal data; all sessions end.
+func (s AccountDataService) DeleteAccount(userID uint, currentPassword string) error {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+
+	if user.PasswordHash != "" {
+		if err := checkPassword(user, currentPassword); err != nil {
+			return err
+		}
+	}
+
+	// Keep at least the admin team intact; admins get demoted first.
+	if user.Role == "admin" {
+		return ErrAdminAccountDeletion
+	}
+
+	return s.Repo.Anonymize(userID, time.Now())
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestDeleteAccount(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.Cart{}, &models.CartItem{}, &models.Order{}, &models.OrderItem{},
+		&models.Review{}, &models.Session{}, &models.RefreshToken{}, &models.RecoveryCode{}, &models.UserIdentity{},
+		&models.PasswordResetToken{}, &models.EmailVerificationToken{})
+	s := AccountDataService{Repo: repository.AccountDataRepo{DB: db}, UserRepo: repository.UserRepo{DB: db}}
+	tokens := TokenService{
+		Keys:     testKeyset(),
+		Repo:     repository.RefreshTokenRepo{DB: db},
+		Sessions: repository.SessionRepo{DB: db},
+	}
+
+	user := createTestUser(t, db, "leaving@example.com")
+	hash, _ := bcrypt.GenerateFromPassword([]byte("Passw0rd!x"), bcrypt.MinCost)
+	db.Model(&user).Update("password_hash", string(hash))
+
+	cart := models.Cart{UserID: user.ID, Items: []models.CartItem{{ProductID: 1, Quantity: 2}}}
+	db.Create(&cart)
+	db.Create(&models.Order{UserID: user.ID, Status: "paid", Total: 100, Items: []models.OrderItem{{ProductID: 1, Quantity: 1}}})
+	db.Create(&models.Review{UserID: user.ID, ProductID: 1, Rating: 5, Text: "great"})
+	db.Create(&models.UserIdentity{UserID: user.ID, Issuer: "https://idp.example.com", Subject: "sub"})
+	db.Create(&models.RecoveryCode{UserID: user.ID, CodeHash: "hash"})
+	pair, err := tokens.IssueTokens(user, false, SessionMeta{DeviceName: "Phone", IP: "10.0.0.1", UserAgent: "UA"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	export, err := s.Export(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Profile.Email != "leaving@example.com" || len(export.Carts) != 1 || len(export.Carts[0].Items) != 1 ||
+		len(export.Orders) != 1 || len(export.Orders[0].Items) != 1 || len(export.Reviews) != 1 ||
+		len(export.Sessions) != 1 || len(export.LinkedIdentities) != 1 {
+		t.Errorf("export = %+v", export)
+	}
+
+	admin := createTestUser(t, db, "admin@example.com")
+	db.Model(&admin).Update("role", "admin")
+	if err := s.DeleteAccount(admin.ID, ""); !errors.Is(err, ErrAdminAccountDeletion) {
+		t.Errorf("admin: err = %v, want ErrAdminAccountDeletion", err)
+	}
+	if err := s.DeleteAccount(user.ID, "wrong"); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong password: err = %v, want ErrWrongPassword", err)
+	}
+
+	if err := s.DeleteAccount(user.ID, "Passw0rd!x"); err != nil {
+		t.Fatal(err)
+	}
+
+	deleted, _ := s.UserRepo.GetUserByID(user.ID)
+	if deleted.Name != repository.AnonymizedName || deleted.Email == "leaving@example.com" ||
+		deleted.PasswordHash != "" || deleted.AnonymizedAt == nil || deleted.DisabledAt == nil {
+		t.Errorf("user after deletion = %+v", deleted)
+	}
+
+	counts := map[string]any{
+		"carts":          &models.Cart{},
+		"cart items":     &models.CartItem{},
+		"identities":     &models.UserIdentity{},
+		"recovery codes": &models.RecoveryCode{},
+	}
+	for name, model := range counts {
+		var n int64
+		db.Unscoped().Model(model).Count(&n)
+		if n != 0 {
+			t.Errorf("%d %s left", n, name)
+		}
+	}
+
+	var orders, reviews int64
+	db.Model(&models.Order{}).Where("user_id = ?", user.ID).Count(&orders)
+	db.Model(&models.Review{}).Where("user_id = ?", user.ID).Count(&reviews)
+	if orders != 1 || reviews != 1 {
+		t.Errorf("orders = %d, reviews = %d; want both kept", orders, reviews)
+	}
+
+	var session models.Session
+	db.Where("user_id = ?", user.ID).First(&session)
+	if session.RevokedAt == nil || session.DeviceName != "" || session.IP != "" || session.UserAgent != "" {
+		t.Errorf("session after deletion = %+v", session)
+	}
+	if _, err := tokens.Refresh(pair.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after deletion: err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	// Deleting twice finds nothing to delete
+	if err := s.DeleteAccount(user.ID, ""); err == nil {
+		t.Error("second deletion succeeded")
+	}
+}
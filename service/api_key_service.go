@@ -0,0 +1,232 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/utils"
+
+	"gorm.io/gorm"
+)
+
+// apiKeyPrefix marks our keys so secret scanners and humans recognise them.
+const apiKeyPrefix = "fmk_"
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidScope   = errors.New("invalid scope")
+	ErrAPIKeyName     = errors.New("name must be 1-100 characters")
+	ErrAPIKeyExpiry   = errors.New("expires_at must be in the future")
+)
+
+// APIKeyView is how a key is shown to admins. It never contains the secret.
+type APIKeyView struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Scopes      []string   `json:"scopes"`
+	CreatedByID uint       `json:"created_by_id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP  string     `json:"last_used_ip,omitempty"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+func NewAPIKeyView(k models.APIKey) APIKeyView {
+	return APIKeyView{
+		ID:          k.ID,
+		Name:        k.Name,
+		Prefix:      k.Prefix,
+		Scopes:      k.ScopeList(),
+		CreatedByID: k.CreatedByID,
+		CreatedAt:   k.CreatedAt,
+		ExpiresAt:   k.ExpiresAt,
+		LastUsedAt:  k.LastUsedAt,
+		LastUsedIP:  k.LastUsedIP,
+		RevokedAt:   k.RevokedAt,
+	}
+}
+
+// CreatedAPIKey is returned once, when the key is created.
+type CreatedAPIKey struct {
+	Key string `json:"key"` // the only time the raw key is ever shown
+	APIKeyView
+}
+
+// APIKeyService manages server-to-server API keys and authenticates them
+// for AuthMiddleware.
+type APIKeyService struct {
+	Repo     repository.APIKeyRepo
+	UserRepo repository.UserRepo
+}
+
+// CreateKey issues a key with the given scopes. Every scope must be a
+// grantable API key action that the creator's own role is allowed to do.
+func (s APIKeyService) CreateKey(creatorID uint, creatorRole, name string, scopes []string, expiresAt *time.Time) (CreatedAPIKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 100 {
+		return CreatedAPIKey{}, ErrAPIKeyName
+	}
+
+	scopes, err := normalizeScopes(creatorRole, scopes)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return CreatedAPIKey{}, ErrAPIKeyExpiry
+	}
+
+	prefix, err := utils.RandomToken(6) // 8 URL-safe characters
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	secret, err := utils.RandomToken(32)
+	if err != nil {
+		return CreatedAPIKey{}, err
+	}
+	raw := apiKeyPrefix + prefix + "_" + secret
+
+	key := models.APIKey{
+		Name:        name,
+		Prefix:      apiKeyPrefix + prefix,
+		KeyHash:     utils.HashToken(raw),
+		Scopes:      strings.Join(scopes, " "),
+		CreatedByID: creatorID,
+		ExpiresAt:   expiresAt,
+	}
+	if err := s.Repo.Create(&key); err != nil {
+		return CreatedAPIKey{}, err
+	}
+
+	return CreatedAPIKey{Key: raw, APIKeyView: NewAPIKeyView(key)}, nil
+}
+
+// ListKeys returns every key, including revoked ones.
+func (s APIKeyService) ListKeys() ([]APIKeyView, error) {
+	keys, err := s.Repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]APIKeyView, 0, len(keys))
+	for _, k := range keys {
+		views = append(views, NewAPIKeyView(k))
+	}
+	return views, nil
+}
+
+// GetKey returns one key.
+func (s APIKeyService) GetKey(id uint) (APIKeyView, error) {
+	key, err := s.Repo.GetByID(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return APIKeyView{}, ErrAPIKeyNotFound
+		}
+		return APIKeyView{}, err
+	}
+	return NewAPIKeyView(*key), nil
+}
+
+// RevokeKey disables a key immediately.
+func (s APIKeyService) RevokeKey(id uint) error {
+	if _, err := s.GetKey(id); err != nil {
+		return err
+	}
+	_, err := s.Repo.Revoke(id, time.Now())
+	return err
+}
+
+// AuthenticateAPIKey validates a raw key from the X-API-Key header and
+// returns its ID and scopes, or ID 0 when the key is unknown, expired or
+// revoked. A key acts for the admin who created it, so it also stops
+// working once they are disabled or deleted, and loses any scope their role
+// no longer has. Last-used tracking is throttled like session
+// last_seen_at.
+func (s APIKeyService) AuthenticateAPIKey(raw, ip string) (uint, []string, error) {
+	if !strings.HasPrefix(raw, apiKeyPrefix) {
+		return 0, nil, nil
+	}
+
+	key, err := s.Repo.FindByHash(utils.HashToken(raw))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, nil
+		}
+		return 0, nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil || (key.ExpiresAt != nil && now.After(*key.ExpiresAt)) {
+		return 0, nil, nil
+	}
+
+	creator, err := s.UserRepo.GetUserByID(key.CreatedByID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil, nil
+		}
+		return 0, nil, err
+	}
+	if creator.DisabledAt != nil || creator.AnonymizedAt != nil {
+		return 0, nil, nil
+	}
+
+	scopes := grantedScopes(creator.Role, key.ScopeList())
+	if len(scopes) == 0 {
+		return 0, nil, nil
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > touchInterval || key.LastUsedIP != ip {
+		if err := s.Repo.Touch(key.ID, now, ip); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	return key.ID, scopes, nil
+}
+
+// grantedScopes keeps the scopes that can still be granted to a key and
+// that role still has.
+func grantedScopes(role string, scopes []string) []string {
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if config.IsAPIKeyScope(scope) && config.RolePermission(role, scope) {
+			out = append(out, scope)
+		}
+	}
+	return out
+}
+
+// normalizeScopes de-duplicates scopes and checks each one.
+func normalizeScopes(creatorRole string, scopes []string) ([]string, error) {
+	if len(scopes) == 0 {
+		return nil, fmt.Errorf("%w: at least one scope is required (one of %s)",
+			ErrInvalidScope, strings.Join(config.APIKeyScopes(), ", "))
+	}
+
+	seen := map[string]bool{}
+	out := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		scope = strings.TrimSpace(scope)
+		if seen[scope] {
+			continue
+		}
+		if !config.IsAPIKeyScope(scope) {
+			return nil, fmt.Errorf("%w %q: must be one of %s",
+				ErrInvalidScope, scope, strings.Join(config.APIKeyScopes(), ", "))
+		}
+		if !config.RolePermission(creatorRole, scope) {
+			return nil, fmt.Errorf("%w %q: your role does not have it", ErrInvalidScope, scope)
+		}
+		seen[scope] = true
+		out = append(out, scope)
+	}
+	return out, nil
+}
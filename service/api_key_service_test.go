@@ -0,0 +1,120 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func TestAPIKeyScopes(t *testing.T) {
+	tests := []struct {
+		name    string
+		role    string
+		scopes  []string
+		want    []string
+		wantErr bool
+	}{
+		{"admin", "admin", []string{"manage:products", " read:audit", "manage:products"}, []string{"manage:products", "read:audit"}, false},
+		{"no scopes", "admin", nil, nil, true},
+		{"users can't be managed by keys", "admin", []string{"manage:users"}, nil, true},
+		{"keys can't manage keys", "admin", []string{"manage:api_keys"}, nil, true},
+		{"customer action", "admin", []string{"checkout"}, nil, true},
+		{"scope the creator lacks", "customer", []string{"manage:products"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizeScopes(tt.role, tt.scopes)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidScope) {
+				t.Errorf("err = %v, want ErrInvalidScope", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("scopes = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticateAPIKeyFollowsCreator(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.APIKey{})
+	s := APIKeyService{Repo: repository.APIKeyRepo{DB: db}, UserRepo: repository.UserRepo{DB: db}}
+
+	newKey := func(t *testing.T, email string) (models.User, CreatedAPIKey) {
+		t.Helper()
+
+		admin := createTestUser(t, db, email)
+		db.Model(&admin).Update("role", "admin")
+		key, err := s.CreateKey(admin.ID, "admin", "erp", []string{"manage:products", "read:audit"}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return admin, key
+	}
+
+	t.Run("active admin", func(t *testing.T) {
+		_, key := newKey(t, "active@example.com")
+
+		id, scopes, err := s.AuthenticateAPIKey(key.Key, "10.0.0.1")
+		if err != nil || id != key.ID || !slices.Equal(scopes, []string{"manage:products", "read:audit"}) {
+			t.Errorf("AuthenticateAPIKey = %d, %v, %v", id, scopes, err)
+		}
+		if id, _, _ := s.AuthenticateAPIKey(key.Key+"x", "10.0.0.1"); id != 0 {
+			t.Error("wrong key accepted")
+		}
+	})
+
+	t.Run("creator disabled", func(t *testing.T) {
+		admin, key := newKey(t, "disabled@example.com")
+		db.Model(&admin).Update("disabled_at", time.Now())
+
+		if id, _, err := s.AuthenticateAPIKey(key.Key, ""); err != nil || id != 0 {
+			t.Errorf("AuthenticateAPIKey = %d, %v; want the key refused", id, err)
+		}
+	})
+
+	t.Run("creator deleted", func(t *testing.T) {
+		admin, key := newKey(t, "deleted@example.com")
+		db.Delete(&admin)
+
+		if id, _, err := s.AuthenticateAPIKey(key.Key, ""); err != nil || id != 0 {
+			t.Errorf("AuthenticateAPIKey = %d, %v; want the key refused", id, err)
+		}
+	})
+
+	t.Run("creator demoted", func(t *testing.T) {
+		admin, key := newKey(t, "demoted@example.com")
+		db.Model(&admin).Update("role", DefaultRole)
+
+		if id, _, err := s.AuthenticateAPIKey(key.Key, ""); err != nil || id != 0 {
+			t.Errorf("AuthenticateAPIKey = %d, %v; want the key refused", id, err)
+		}
+	})
+
+	t.Run("scope no longer grantable", func(t *testing.T) {
+		_, key := newKey(t, "legacy@example.com")
+		db.Model(&models.APIKey{}).Where("id = ?", key.ID).Update("scopes", "manage:users read:audit")
+
+		_, scopes, err := s.AuthenticateAPIKey(key.Key, "")
+		if err != nil || !slices.Equal(scopes, []string{"read:audit"}) {
+			t.Errorf("scopes = %v, %v; want read:audit only", scopes, err)
+		}
+	})
+
+	t.Run("revoked", func(t *testing.T) {
+		_, key := newKey(t, "revoked@example.com")
+		if err := s.RevokeKey(key.ID); err != nil {
+			t.Fatal(err)
+		}
+
+		if id, _, err := s.AuthenticateAPIKey(key.Key, ""); err != nil || id != 0 {
+			t.Errorf("AuthenticateAPIKey = %d, %v; want the key refused", id, err)
+		}
+	})
+}
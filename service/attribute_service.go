@@ -0,0 +1,546 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrAttributeNotFound     = errors.New("attribute not found")
+	ErrInvalidAttribute      = errors.New("invalid attribute definition")
+	ErrDuplicateAttribute    = errors.New("this attribute is already defined for the category or a parent/subcategory")
+	ErrInvalidAttributeValue = errors.New("invalid attribute value")
+	ErrInvalidAttributeQuery = errors.New("invalid attribute filter")
+)
+
+// attributeKeyPattern is what attribute keys look like: they appear in
+// query strings as attr.<key>.
+var attributeKeyPattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// maxAttributeString caps string attribute values.
+const maxAttributeString = 255
+
+// AttributeView is an attribute definition as shown to clients.
+// CategoryID is the category that declares it, which may be a parent of
+// the one asked for.
+type AttributeView struct {
+	ID         uint     `json:"id"`
+	CategoryID uint     `json:"category_id"`
+	Key        string   `json:"key"`
+	Label      string   `json:"label"`
+	Type       string   `json:"type"`
+	Options    []string `json:"options,omitempty"`
+	Unit       string   `json:"unit,omitempty"`
+	Required   bool     `json:"required"`
+	Position   int      `json:"position"`
+}
+
+// AttributeInput creates or updates an attribute definition. Nil fields
+// are left alone on update; key and type are fixed once created since
+// products already store values under them.
+type AttributeInput struct {
+	Key      *string  `json:"key"`
+	Label    *string  `json:"label"`
+	Type     *string  `json:"type"`
+	Options  []string `json:"options"`
+	Unit     *string  `json:"unit"`
+	Required *bool    `json:"required"`
+	Position *int     `json:"position"`
+}
+
+// AttributeService manages the custom attributes categories declare and
+// checks product attribute values against them.
+type AttributeService struct {
+	Repo       repository.AttributeRepo
+	Categories repository.CategoryRepo
+}
+
+// ListForCategory returns the attributes products in a category can have:
+// its own and those inherited from its parents.
+func (s AttributeService) ListForCategory(categoryID uint) ([]AttributeView, error) {
+	defs, err := s.definitionsFor(categoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]AttributeView, len(defs))
+	for i, d := range defs {
+		views[i] = newAttributeView(d)
+	}
+	return views, nil
+}
+
+// CreateAttribute declares a new attribute on a category. Key, label and
+// type are required, and enums need options.
+func (s AttributeService) CreateAttribute(categoryID uint, in AttributeInput) (AttributeView, error) {
+	if _, err := s.Categories.GetByID(categoryID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return AttributeView{}, ErrCategoryNotFound
+		}
+		return AttributeView{}, err
+	}
+
+	if in.Key == nil || in.Label == nil || in.Type == nil {
+		return AttributeView{}, fmt.Errorf("%w: key, label and type are required", ErrInvalidAttribute)
+	}
+	if !attributeKeyPattern.MatchString(*in.Key) {
+		return AttributeView{}, fmt.Errorf("%w: key must be lower-case letters, digits and underscores", ErrInvalidAttribute)
+	}
+	switch *in.Type {
+	case models.AttributeString, models.AttributeNumber, models.AttributeBoolean, models.AttributeEnum:
+	default:
+		return AttributeView{}, fmt.Errorf("%w: type must be string, number, boolean or enum", ErrInvalidAttribute)
+	}
+
+	if err := s.checkKey(categoryID, *in.Key, *in.Type); err != nil {
+		return AttributeView{}, err
+	}
+
+	def := models.AttributeDefinition{CategoryID: categoryID, Key: *in.Key, Type: *in.Type}
+	if err := s.apply(&def, in); err != nil {
+		return AttributeView{}, err
+	}
+
+	if err := s.Repo.Create(&def); err != nil {
+		return AttributeView{}, err
+	}
+	return newAttributeView(def), nil
+}
+
+// UpdateAttribute changes the label, options, unit, required flag or
+// position of a definition. It returns the definition before and after.
+func (s AttributeService) UpdateAttribute(id uint, in AttributeInput) (AttributeView, AttributeView, error) {
+	def, err := s.getAttribute(id)
+	if err != nil {
+		return AttributeView{}, AttributeView{}, err
+	}
+	before := newAttributeView(def)
+
+	if (in.Key != nil && *in.Key != def.Key) || (in.Type != nil && *in.Type != def.Type) {
+		return AttributeView{}, AttributeView{}, fmt.Errorf("%w: key and type can't be changed", ErrInvalidAttribute)
+	}
+
+	if err := s.apply(&def, in); err != nil {
+		return AttributeView{}, AttributeView{}, err
+	}
+
+	if err := s.Repo.Update(&def); err != nil {
+		return AttributeView{}, AttributeView{}, err
+	}
+	return before, newAttributeView(def), nil
+}
+
+// DeleteAttribute removes a definition along with the values products in
+// its category (and subcategories) have for it.
+func (s AttributeService) DeleteAttribute(id uint) (AttributeView, error) {
+	def, err := s.getAttribute(id)
+	if err != nil {
+		return AttributeView{}, err
+	}
+
+	tree, err := s.loadTree()
+	if err != nil {
+		return AttributeView{}, err
+	}
+
+	return newAttributeView(def), s.Repo.Delete(def, tree.descendants(def.CategoryID))
+}
+
+// ApplyValues merges changes into a product's attributes and validates the
+// result against the definitions of its category. A null value removes an
+// attribute. Required attributes are checked when checkRequired is set,
+// so old products don't fail unrelated updates once a new required
+// attribute is added.
+func (s AttributeService) ApplyValues(p *models.Product, changes map[string]json.RawMessage, checkRequired bool) error {
+	values := map[string]json.RawMessage{}
+	if len(p.Attributes) > 0 {
+		if err := json.Unmarshal(p.Attributes, &values); err != nil {
+			return err
+		}
+	}
+	for key, raw := range changes {
+		if raw == nil || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+			delete(values, key)
+		} else {
+			values[key] = raw
+		}
+	}
+
+	var defs []models.AttributeDefinition
+	if p.CategoryID != nil {
+		var err error
+		if defs, err = s.definitionsFor(*p.CategoryID); err != nil {
+			return err
+		}
+	}
+
+	byKey := make(map[string]models.AttributeDefinition, len(defs))
+	for _, d := range defs {
+		byKey[d.Key] = d
+	}
+
+	for key, raw := range values {
+		def, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%w: %q is not an attribute of this product's category", ErrInvalidAttributeValue, key)
+		}
+		if err := checkAttributeValue(def, raw); err != nil {
+			return err
+		}
+	}
+
+	if checkRequired {
+		for _, d := range defs {
+			if _, ok := values[d.Key]; d.Required && !ok {
+				return fmt.Errorf("%w: %q is required", ErrInvalidAttributeValue, d.Key)
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	p.Attributes = encoded
+	return nil
+}
+
+// ResolveFilters checks attr.<key> filters against the known attributes
+// and converts their values to the attribute's type.
+func (s AttributeService) ResolveFilters(filters []repository.AttributeFilter) ([]repository.AttributeFilter, error) {
+	if len(filters) == 0 {
+		return filters, nil
+	}
+
+	keys := make([]string, len(filters))
+	for i, f := range filters {
+		keys[i] = f.Key
+	}
+	defs, err := s.Repo.ListByKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+
+	// A key has the same type in every category that declares it
+	types := make(map[string]string, len(defs))
+	for _, d := range defs {
+		types[d.Key] = d.Type
+	}
+
+	resolved := make([]repository.AttributeFilter, len(filters))
+	for i, f := range filters {
+		typ, ok := types[f.Key]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown attribute %q", ErrInvalidAttributeQuery, f.Key)
+		}
+		if !repository.IsAttributeOp(f.Op) {
+			return nil, fmt.Errorf("%w: %s[%s]: use eq, gt, gte, lt or lte", ErrInvalidAttributeQuery, f.Key, f.Op)
+		}
+		if f.Op != repository.AttributeEq && typ != models.AttributeNumber {
+			return nil, fmt.Errorf("%w: %s[%s]: ranges only apply to number attributes", ErrInvalidAttributeQuery, f.Key, f.Op)
+		}
+
+		f.Values = make([]any, len(f.Raw))
+		for j, raw := range f.Raw {
+			if f.Values[j], err = parseAttributeQuery(typ, raw); err != nil {
+				return nil, fmt.Errorf("%w: %s=%q is not a %s", ErrInvalidAttributeQuery, f.Key, raw, typ)
+			}
+		}
+		resolved[i] = f
+	}
+	return resolved, nil
+}
+
+// definitionsFor returns the definitions of a category and its parents,
+// in display order.
+func (s AttributeService) definitionsFor(categoryID uint) ([]models.AttributeDefinition, error) {
+	tree, err := s.loadTree()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := tree.parents[categoryID]; !ok {
+		return nil, ErrCategoryNotFound
+	}
+	return s.Repo.ListForCategories(tree.lineage(categoryID))
+}
+
+// checkKey makes sure key isn't already declared in the category's lineage
+// (which would give products two definitions) and isn't used with another
+// type elsewhere (which would break filtering across categories).
+func (s AttributeService) checkKey(categoryID uint, key, typ string) error {
+	existing, err := s.Repo.ListByKeys([]string{key})
+	if err != nil || len(existing) == 0 {
+		return err
+	}
+
+	tree, err := s.loadTree()
+	if err != nil {
+		return err
+	}
+	related := map[uint]bool{}
+	for _, id := range tree.lineage(categoryID) {
+		related[id] = true
+	}
+	for _, id := range tree.descendants(categoryID) {
+		related[id] = true
+	}
+
+	for _, d := range existing {
+		if related[d.CategoryID] {
+			return ErrDuplicateAttribute
+		}
+		if d.Type != typ {
+			return fmt.Errorf("%w: %q is already used as a %s attribute", ErrInvalidAttribute, key, d.Type)
+		}
+	}
+	return nil
+}
+
+// CheckMove makes sure categoryID can move below parentID (nil for the top
+// level) without breaking attributes: the moved categories can't declare a
+// key their new parents declare too, and their products can't keep values
+// for keys that only the old parents declare.
+func (s AttributeService) CheckMove(categoryID uint, parentID *uint) error {
+	tree, err := s.loadTree()
+	if err != nil {
+		return err
+	}
+
+	moved := tree.descendants(categoryID)
+	oldParents := tree.lineage(categoryID)[1:]
+	var newParents []uint
+	if parentID != nil {
+		newParents = tree.lineage(*parentID)
+	}
+
+	declared, err := s.declaredKeys(moved)
+	if err != nil {
+		return err
+	}
+	inherited, err := s.declaredKeys(newParents)
+	if err != nil {
+		return err
+	}
+	for _, key := range declared {
+		if slices.Contains(inherited, key) {
+			return fmt.Errorf("%w: %q is declared both below the category and by its new parents", ErrCategoryMove, key)
+		}
+	}
+
+	lost, err := s.declaredKeys(oldParents)
+	if err != nil {
+		return err
+	}
+	for _, key := range lost {
+		if slices.Contains(inherited, key) {
+			continue
+		}
+		used, err := s.Repo.HasValues(moved, key)
+		if err != nil {
+			return err
+		}
+		if used {
+			return fmt.Errorf("%w: products below the category have values for %q, which the new parents don't declare", ErrCategoryMove, key)
+		}
+	}
+	return nil
+}
+
+// declaredKeys returns the attribute keys the categories declare, sorted.
+func (s AttributeService) declaredKeys(categoryIDs []uint) ([]string, error) {
+	defs, err := s.Repo.ListForCategories(categoryIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(defs))
+	for i, d := range defs {
+		keys[i] = d.Key
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys), nil
+}
+
+func (s AttributeService) getAttribute(id uint) (models.AttributeDefinition, error) {
+	def, err := s.Repo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.AttributeDefinition{}, ErrAttributeNotFound
+	}
+	return def, err
+}
+
+// apply validates in and copies the editable fields that are set onto def.
+func (s AttributeService) apply(def *models.AttributeDefinition, in AttributeInput) error {
+	if in.Label != nil {
+		label := strings.TrimSpace(*in.Label)
+		if label == "" || len(label) > 100 {
+			return fmt.Errorf("%w: label must be 1-100 characters", ErrInvalidAttribute)
+		}
+		def.Label = label
+	}
+
+	if in.Options != nil {
+		if def.Type != models.AttributeEnum {
+			return fmt.Errorf("%w: only enum attributes have options", ErrInvalidAttribute)
+		}
+		seen := map[string]bool{}
+		for _, o := range in.Options {
+			if o == "" || len(o) > maxAttributeString || seen[o] {
+				return fmt.Errorf("%w: options must be distinct, non-empty strings", ErrInvalidAttribute)
+			}
+			seen[o] = true
+		}
+		encoded, err := json.Marshal(in.Options)
+		if err != nil {
+			return err
+		}
+		def.Options = string(encoded)
+	}
+	if def.Type == models.AttributeEnum && len(attributeOptions(*def)) == 0 {
+		return fmt.Errorf("%w: enum attributes need options", ErrInvalidAttribute)
+	}
+
+	if in.Unit != nil {
+		if len(*in.Unit) > 20 {
+			return fmt.Errorf("%w: unit must be at most 20 characters", ErrInvalidAttribute)
+		}
+		def.Unit = *in.Unit
+	}
+	if in.Required != nil {
+		def.Required = *in.Required
+	}
+	if in.Position != nil {
+		def.Position = *in.Position
+	}
+
+	return nil
+}
+
+// checkAttributeValue checks that raw is a valid JSON value for def.
+func checkAttributeValue(def models.AttributeDefinition, raw json.RawMessage) error {
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return fmt.Errorf("%w: %q is not valid JSON", ErrInvalidAttributeValue, def.Key)
+	}
+
+	switch def.Type {
+	case models.AttributeNumber:
+		if _, ok := value.(float64); ok {
+			return nil
+		}
+	case models.AttributeBoolean:
+		if _, ok := value.(bool); ok {
+			return nil
+		}
+	case models.AttributeString:
+		if s, ok := value.(string); ok && s != "" && len(s) <= maxAttributeString {
+			return nil
+		}
+	case models.AttributeEnum:
+		if s, ok := value.(string); ok {
+			for _, o := range attributeOptions(def) {
+				if s == o {
+					return nil
+				}
+			}
+			return fmt.Errorf("%w: %q must be one of %s", ErrInvalidAttributeValue,
+				def.Key, strings.Join(attributeOptions(def), ", "))
+		}
+	}
+
+	return fmt.Errorf("%w: %q must be a %s", ErrInvalidAttributeValue, def.Key, def.Type)
+}
+
+// parseAttributeQuery converts a query string value to an attribute's type.
+func parseAttributeQuery(typ, raw string) (any, error) {
+	switch typ {
+	case models.AttributeNumber:
+		return strconv.ParseFloat(raw, 64)
+	case models.AttributeBoolean:
+		return strconv.ParseBool(raw)
+	default:
+		return raw, nil
+	}
+}
+
+// attributeOptions decodes the allowed values of an enum definition.
+func attributeOptions(def models.AttributeDefinition) []string {
+	var options []string
+	if def.Options != "" {
+		json.Unmarshal([]byte(def.Options), &options)
+	}
+	return options
+}
+
+func newAttributeView(def models.AttributeDefinition) AttributeView {
+	return AttributeView{
+		ID:         def.ID,
+		CategoryID: def.CategoryID,
+		Key:        def.Key,
+		Label:      def.Label,
+		Type:       def.Type,
+		Options:    attributeOptions(def),
+		Unit:       def.Unit,
+		Required:   def.Required,
+		Position:   def.Position,
+	}
+}
+
+// categoryTree is the parent links of every category, for walking up and
+// down the tree without a query per level.
+type categoryTree struct {
+	parents map[uint]*uint
+}
+
+func (s AttributeService) loadTree() (categoryTree, error) {
+	categories, err := s.Categories.List()
+	if err != nil {
+		return categoryTree{}, err
+	}
+
+	tree := categoryTree{parents: make(map[uint]*uint, len(categories))}
+	for _, c := range categories {
+		tree.parents[c.ID] = c.ParentID
+	}
+	return tree, nil
+}
+
+// lineage returns id and its ancestors, nearest first.
+func (t categoryTree) lineage(id uint) []uint {
+	var ids []uint
+	seen := map[uint]bool{}
+	for p := &id; p != nil && !seen[*p]; p = t.parents[*p] {
+		seen[*p] = true
+		ids = append(ids, *p)
+	}
+	return ids
+}
+
+// descendants returns id and every category below it.
+func (t categoryTree) descendants(id uint) []uint {
+	children := map[uint][]uint{}
+	for child, parent := range t.parents {
+		if parent != nil {
+			children[*parent] = append(children[*parent], child)
+		}
+	}
+
+	ids := []uint{id}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
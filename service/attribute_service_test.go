@@ -0,0 +1,99 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func TestCheckAttributeValue(t *testing.T) {
+	number := models.AttributeDefinition{Key: "screen_in", Type: models.AttributeNumber}
+	boolean := models.AttributeDefinition{Key: "smart", Type: models.AttributeBoolean}
+	text := models.AttributeDefinition{Key: "brand", Type: models.AttributeString}
+	enum := models.AttributeDefinition{Key: "panel", Type: models.AttributeEnum, Options: `["OLED","LCD"]`}
+
+	tests := []struct {
+		name string
+		def  models.AttributeDefinition
+		raw  string
+		ok   bool
+	}{
+		{"number", number, `55`, true},
+		{"fractional number", number, `54.6`, true},
+		{"number as string", number, `"55"`, false},
+		{"boolean", boolean, `true`, true},
+		{"boolean as string", boolean, `"true"`, false},
+		{"string", text, `"Sony"`, true},
+		{"empty string", text, `""`, false},
+		{"longest string", text, `"` + strings.Repeat("a", maxAttributeString) + `"`, true},
+		{"string too long", text, `"` + strings.Repeat("a", maxAttributeString+1) + `"`, false},
+		{"string as number", text, `1`, false},
+		{"enum option", enum, `"OLED"`, true},
+		{"enum option in another case", enum, `"oled"`, false},
+		{"enum as number", enum, `1`, false},
+		{"null", number, `null`, false},
+		{"object", text, `{"a":1}`, false},
+		{"not json", number, `55,`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkAttributeValue(tt.def, json.RawMessage(tt.raw))
+			if tt.ok && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrInvalidAttributeValue) {
+				t.Errorf("err = %v, want ErrInvalidAttributeValue", err)
+			}
+		})
+	}
+}
+
+func TestResolveFilters(t *testing.T) {
+	db := newTestDB(t, &models.AttributeDefinition{})
+	s := AttributeService{Repo: repository.AttributeRepo{DB: db}, Categories: repository.CategoryRepo{DB: db}}
+
+	db.Create(&[]models.AttributeDefinition{
+		{CategoryID: 1, Key: "screen_in", Type: models.AttributeNumber},
+		{CategoryID: 1, Key: "smart", Type: models.AttributeBoolean},
+		{CategoryID: 2, Key: "brand", Type: models.AttributeString},
+	})
+
+	tests := []struct {
+		name   string
+		filter repository.AttributeFilter
+		want   []any // nil means the filter is rejected
+	}{
+		{"number", repository.AttributeFilter{Key: "screen_in", Op: "gte", Raw: []string{"50"}}, []any{50.0}},
+		{"boolean", repository.AttributeFilter{Key: "smart", Op: "eq", Raw: []string{"true"}}, []any{true}},
+		{"strings stay strings", repository.AttributeFilter{Key: "brand", Op: "eq", Raw: []string{"Sony", "55"}}, []any{"Sony", "55"}},
+		{"unknown key", repository.AttributeFilter{Key: "weight", Op: "eq", Raw: []string{"1"}}, nil},
+		{"unknown operator", repository.AttributeFilter{Key: "screen_in", Op: "ne", Raw: []string{"1"}}, nil},
+		{"range over a string", repository.AttributeFilter{Key: "brand", Op: "gt", Raw: []string{"A"}}, nil},
+		{"not a number", repository.AttributeFilter{Key: "screen_in", Op: "lt", Raw: []string{"big"}}, nil},
+		{"not a boolean", repository.AttributeFilter{Key: "smart", Op: "eq", Raw: []string{"maybe"}}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolved, err := s.ResolveFilters([]repository.AttributeFilter{tt.filter})
+			if tt.want == nil {
+				if !errors.Is(err, ErrInvalidAttributeQuery) {
+					t.Errorf("err = %v, want ErrInvalidAttributeQuery", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(resolved[0].Values, tt.want) {
+				t.Errorf("values = %#v, want %#v", resolved[0].Values, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+// AuditService appends security-relevant events to the audit log.
+type AuditService struct {
+	Repo repository.AuditRepo
+}
+
+// AuditEntry is one event to record. Before and After are snapshots of the
+// target (structs or maps); only the fields that differ are stored.
+type AuditEntry struct {
+	ActorID    *uint // acting user, nil for anonymous/system actions
+	APIKeyID   *uint // API key the request was made with
+	IP         string
+	RequestID  string
+	Action     string
+	TargetType string
+	TargetID   string
+	Before     any
+	After      any
+	Details    any
+}
+
+// Record writes an event. details is marshalled to JSON and may be nil.
+// Failures are logged rather than returned: a broken audit write must never
+// block the action being audited.
+func (s AuditService) Record(actorID *uint, action, targetType, targetID, ip string, details any) {
+	s.Log(AuditEntry{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         ip,
+		Details:    details,
+	})
+}
+
+// Log writes a fully described event. Like Record it never fails the
+// caller.
+func (s AuditService) Log(e AuditEntry) {
+	event := models.AuditEvent{
+		ActorID:       e.ActorID,
+		ActorAPIKeyID: e.APIKeyID,
+		Action:        e.Action,
+		TargetType:    e.TargetType,
+		TargetID:      e.TargetID,
+		IP:            e.IP,
+		RequestID:     e.RequestID,
+	}
+
+	if e.Before != nil || e.After != nil {
+		event.Before, event.After = auditDiff(e.Before, e.After)
+	}
+
+	if e.Details != nil {
+		if raw, err := json.Marshal(e.Details); err == nil {
+			event.Details = string(raw)
+		}
+	}
+
+	if err := s.Repo.Create(&event); err != nil {
+		log.Printf("failed to write audit event %q: %v", e.Action, err)
+	}
+}
+
+// auditIgnoredFields change on every write and would drown the real diff.
+var auditIgnoredFields = map[string]bool{
+	"UpdatedAt":  true,
+	"updated_at": true,
+}
+
+// auditDiff returns JSON objects holding only the fields that differ between
+// before and after. Either side may be nil (create/delete).
+func auditDiff(before, after any) (string, string) {
+	b := toFieldMap(before)
+	a := toFieldMap(after)
+
+	changedBefore := map[string]any{}
+	changedAfter := map[string]any{}
+
+	for k, bv := range b {
+		if auditIgnoredFields[k] {
+			continue
+		}
+		if av, ok := a[k]; !ok || !reflect.DeepEqual(av, bv) {
+			changedBefore[k] = bv
+		}
+	}
+	for k, av := range a {
+		if auditIgnoredFields[k] {
+			continue
+		}
+		if bv, ok := b[k]; !ok || !reflect.DeepEqual(av, bv) {
+			changedAfter[k] = av
+		}
+	}
+
+	return marshalNonEmpty(changedBefore, before != nil), marshalNonEmpty(changedAfter, after != nil)
+}
+
+// toFieldMap flattens a struct or map into its JSON fields.
+func toFieldMap(v any) map[string]any {
+	out := map[string]any{}
+	if v == nil {
+		return out
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return out
+	}
+	json.Unmarshal(raw, &out)
+	return out
+}
+
+func marshalNonEmpty(m map[string]any, present bool) string {
+	if !present {
+		return ""
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return ""
+	}
+	return string(raw)
+}
+
+// AuditEventView is how an audit event is shown to admins.
+type AuditEventView struct {
+	ID            uint            `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	ActorID       *uint           `json:"actor_id,omitempty"`
+	ActorAPIKeyID *uint           `json:"actor_api_key_id,omitempty"`
+	Action        string          `json:"action"`
+	TargetType    string          `json:"target_type,omitempty"`
+	TargetID      string          `json:"target_id,omitempty"`
+	IP            string          `json:"ip,omitempty"`
+	RequestID     string          `json:"request_id,omitempty"`
+	Before        json.RawMessage `json:"before,omitempty"`
+	After         json.RawMessage `json:"after,omitempty"`
+	Details       json.RawMessage `json:"details,omitempty"`
+}
+
+func NewAuditEventView(e models.AuditEvent) AuditEventView {
+	return AuditEventView{
+		ID:            e.ID,
+		CreatedAt:     e.CreatedAt,
+		ActorID:       e.ActorID,
+		ActorAPIKeyID: e.ActorAPIKeyID,
+		Action:        e.Action,
+		TargetType:    e.TargetType,
+		TargetID:      e.TargetID,
+		IP:            e.IP,
+		RequestID:     e.RequestID,
+		Before:        rawJSON(e.Before),
+		After:         rawJSON(e.After),
+		Details:       rawJSON(e.Details),
+	}
+}
+
+func rawJSON(s string) json.RawMessage {
+	if s == "" {
+		return nil
+	}
+	return json.RawMessage(s)
+}
+
+type AuditListResponse struct {
+	Events []AuditEventView `json:"events"`
+	Meta   PaginationMeta   `json:"meta"`
+}
+
+// ListEvents returns a page of events matching filter, newest first.
+func (s AuditService) ListEvents(filter repository.AuditFilter, page, limit int) (AuditListResponse, error) {
+	events, total, err := s.Repo.List(filter, page, limit)
+	if err != nil {
+		return AuditListResponse{}, err
+	}
+
+	views := make([]AuditEventView, 0, len(events))
+	for _, e := range events {
+		views = append(views, NewAuditEventView(e))
+	}
+
+	return AuditListResponse{
+		Events: views,
+		Meta:   offsetMeta(total, page, limit),
+	}, nil
+}
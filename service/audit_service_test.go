@@ -0,0 +1,133 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func TestAuditDiff(t *testing.T) {
+	type product struct {
+		Name      string
+		Price     int
+		Tags      []string
+		UpdatedAt time.Time
+	}
+	before := product{Name: "Lamp", Price: 10, Tags: []string{"home"}, UpdatedAt: time.Unix(1, 0)}
+
+	tests := []struct {
+		name       string
+		before     any
+		after      any
+		wantBefore string
+		wantAfter  string
+	}{
+		{
+			name:       "changed fields only",
+			before:     before,
+			after:      product{Name: "Lamp", Price: 12, Tags: []string{"home"}, UpdatedAt: time.Unix(2, 0)},
+			wantBefore: `{"Price":10}`,
+			wantAfter:  `{"Price":12}`,
+		},
+		{
+			name:       "slices compared by value",
+			before:     before,
+			after:      product{Name: "Lamp", Price: 10, Tags: []string{"home", "sale"}},
+			wantBefore: `{"Tags":["home"]}`,
+			wantAfter:  `{"Tags":["home","sale"]}`,
+		},
+		{
+			name:       "nothing changed",
+			before:     before,
+			after:      before,
+			wantBefore: `{}`,
+			wantAfter:  `{}`,
+		},
+		{
+			name:       "create",
+			before:     nil,
+			after:      map[string]any{"role": "admin", "updated_at": "now"},
+			wantBefore: ``,
+			wantAfter:  `{"role":"admin"}`,
+		},
+		{
+			name:       "delete",
+			before:     map[string]any{"role": "admin"},
+			after:      nil,
+			wantBefore: `{"role":"admin"}`,
+			wantAfter:  ``,
+		},
+		{
+			name:       "field added and removed",
+			before:     map[string]any{"old": 1, "same": true},
+			after:      map[string]any{"new": 2, "same": true},
+			wantBefore: `{"old":1}`,
+			wantAfter:  `{"new":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotBefore, gotAfter := auditDiff(tt.before, tt.after)
+			if gotBefore != tt.wantBefore || gotAfter != tt.wantAfter {
+				t.Errorf("auditDiff = %s, %s; want %s, %s", gotBefore, gotAfter, tt.wantBefore, tt.wantAfter)
+			}
+		})
+	}
+}
+
+func TestAuditListEvents(t *testing.T) {
+	db := newTestDB(t, &models.AuditEvent{})
+	s := AuditService{Repo: repository.AuditRepo{DB: db}}
+
+	actor := uint(7)
+	s.Record(&actor, "auth.login", "user", "7", "10.0.0.1", nil)
+	s.Record(nil, "auth.login_failed", "user", "", "10.0.0.2", map[string]string{"email": "x@example.com"})
+	s.Record(nil, "password_reset.lockout", "account", "x@example.com", "", nil)
+	s.Record(nil, "passwordXreset.other", "account", "", "", nil)
+	s.Log(AuditEntry{ActorID: &actor, Action: "user.update", TargetType: "user", TargetID: "8",
+		Before: map[string]any{"role": "customer"}, After: map[string]any{"role": "admin"}})
+
+	tests := []struct {
+		name   string
+		filter repository.AuditFilter
+		want   []string
+	}{
+		{"everything, newest first", repository.AuditFilter{}, []string{"user.update", "passwordXreset.other", "password_reset.lockout", "auth.login_failed", "auth.login"}},
+		{"exact action", repository.AuditFilter{Action: "auth.login"}, []string{"auth.login"}},
+		{"action prefix", repository.AuditFilter{Action: "auth.*"}, []string{"auth.login_failed", "auth.login"}},
+		{"underscore in a prefix is literal", repository.AuditFilter{Action: "password_reset.*"}, []string{"password_reset.lockout"}},
+		{"actor", repository.AuditFilter{ActorID: &actor}, []string{"user.update", "auth.login"}},
+		{"target", repository.AuditFilter{TargetType: "user", TargetID: "8"}, []string{"user.update"}},
+		{"ip", repository.AuditFilter{IP: "10.0.0.2"}, []string{"auth.login_failed"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.ListEvents(tt.filter, 1, 50)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, e := range res.Events {
+				got = append(got, e.Action)
+			}
+			if len(got) != len(tt.want) || *res.Meta.TotalItems != int64(len(tt.want)) {
+				t.Fatalf("actions = %v (total %d), want %v", got, *res.Meta.TotalItems, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("actions = %v, want %v", got, tt.want)
+					break
+				}
+			}
+		})
+	}
+
+	res, _ := s.ListEvents(repository.AuditFilter{Action: "user.update"}, 1, 1)
+	if e := res.Events[0]; string(e.Before) != `{"role":"customer"}` || string(e.After) != `{"role":"admin"}` {
+		t.Errorf("diff = %s, %s", e.Before, e.After)
+	}
+}
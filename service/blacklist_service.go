@@ -1,15 +1,97 @@
 package service
 
-import "futuremarket/repository"
+import (
+	"log"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/repository"
+	"futuremarket/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
 
 type BlacklistService struct {
 	Repo repository.BlacklistRepository
+
+	// Cache answers IsTokenBlacklisted without a DB round-trip. Blacklisted
+	// keys are cached until the token expires; "not blacklisted" answers only
+	// for NegativeTTL, which bounds how long a logout on another replica
+	// takes to be seen here. A nil Cache disables caching.
+	Cache       *TTLCache
+	NegativeTTL time.Duration
+}
+
+// blacklistKey identifies a token by its jti claim, falling back to a hash of
+// the whole token, and returns when the token expires. claims must come from
+// Keyset.Parse on that same token, so a caller can't pick the jti or expiry.
+func blacklistKey(token string, claims jwt.MapClaims) (string, time.Time) {
+	expiresAt := time.Now().Add(config.AccessTokenTTL())
+	key := "sha256:" + utils.HashToken(token)
+
+	if jti, ok := claims["jti"].(string); ok && jti != "" {
+		key = "jti:" + jti
+	}
+	if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+		expiresAt = exp.Time
+	}
+
+	return key, expiresAt
+}
+
+// BlacklistToken revokes a verified access token until it expires.
+func (s BlacklistService) BlacklistToken(token string, claims jwt.MapClaims) error {
+	key, expiresAt := blacklistKey(token, claims)
+
+	if err := s.Repo.Add(key, expiresAt); err != nil {
+		return err
+	}
+
+	s.Cache.Set(key, true, time.Until(expiresAt))
+	return nil
 }
 
-func (s BlacklistService) BlacklistToken(token string) error {
-	return s.Repo.Add(token)
+// IsTokenBlacklisted reports whether a verified access token was revoked.
+func (s BlacklistService) IsTokenBlacklisted(token string, claims jwt.MapClaims) (bool, error) {
+	key, expiresAt := blacklistKey(token, claims)
+
+	if blacklisted, ok := s.Cache.Get(key); ok {
+		return blacklisted, nil
+	}
+
+	blacklisted, err := s.Repo.Exists(key)
+	if err != nil {
+		return false, err
+	}
+
+	if blacklisted {
+		s.Cache.Set(key, true, time.Until(expiresAt))
+	} else {
+		s.Cache.Set(key, false, s.NegativeTTL)
+	}
+
+	return blacklisted, nil
+}
+
+// PurgeExpired deletes entries for tokens that have expired anyway.
+func (s BlacklistService) PurgeExpired() (int64, error) {
+	return s.Repo.DeleteExpired(time.Now())
 }
 
-func (s BlacklistService) IsTokenBlacklisted(token string) (bool, error) {
-	return s.Repo.Exists(token)
+// StartPurgeJob runs PurgeExpired every interval. It blocks, so run it in a
+// goroutine.
+func (s BlacklistService) StartPurgeJob(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		n, err := s.PurgeExpired()
+		if err != nil {
+			log.Printf("failed to purge token blacklist: %v", err)
+			continue
+		}
+		if n > 0 {
+			log.Printf("purged %d expired blacklist entries", n)
+		}
+	}
 }
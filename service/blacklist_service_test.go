@@ -0,0 +1,118 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestBlacklistKey(t *testing.T) {
+	exp := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name    string
+		claims  jwt.MapClaims
+		wantKey string
+		wantExp time.Time
+	}{
+		{"jti and exp", jwt.MapClaims{"jti": "abc", "exp": float64(exp.Unix())}, "jti:abc", exp},
+		{"no jti", jwt.MapClaims{"exp": float64(exp.Unix())}, "sha256:" + utils.HashToken("raw"), exp},
+		{"empty jti", jwt.MapClaims{"jti": "", "exp": float64(exp.Unix())}, "sha256:" + utils.HashToken("raw"), exp},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, expiresAt := blacklistKey("raw", tt.claims)
+			if key != tt.wantKey || !expiresAt.Equal(tt.wantExp) {
+				t.Errorf("blacklistKey = %q, %s; want %q, %s", key, expiresAt, tt.wantKey, tt.wantExp)
+			}
+		})
+	}
+
+	// Without exp the entry lives as long as an access token can
+	_, expiresAt := blacklistKey("raw", jwt.MapClaims{})
+	if until := time.Until(expiresAt); until <= 0 || until > config.AccessTokenTTL() {
+		t.Errorf("no exp: expires in %s", until)
+	}
+}
+
+func TestBlacklistToken(t *testing.T) {
+	db := newTestDB(t, &models.TokenBlacklist{})
+	s := BlacklistService{
+		Repo:        repository.BlacklistRepository{DB: db},
+		Cache:       NewTTLCache(100),
+		NegativeTTL: time.Minute,
+	}
+	exp := time.Now().Add(5 * time.Minute)
+	claims := jwt.MapClaims{"jti": "logged-out", "exp": float64(exp.Unix())}
+
+	// A "not blacklisted" answer gets cached...
+	if blacklisted, err := s.IsTokenBlacklisted("token", claims); err != nil || blacklisted {
+		t.Fatalf("before logout: %v, %v", blacklisted, err)
+	}
+	// ...but blacklisting on this replica overrides it right away
+	if err := s.BlacklistToken("token", claims); err != nil {
+		t.Fatal(err)
+	}
+	if blacklisted, err := s.IsTokenBlacklisted("token", claims); err != nil || !blacklisted {
+		t.Errorf("after logout: %v, %v", blacklisted, err)
+	}
+
+	// Another replica, with a cold cache, sees it in the database
+	other := BlacklistService{Repo: s.Repo, Cache: NewTTLCache(100), NegativeTTL: time.Minute}
+	if blacklisted, err := other.IsTokenBlacklisted("token", claims); err != nil || !blacklisted {
+		t.Errorf("other replica: %v, %v", blacklisted, err)
+	}
+
+	// Tokens with another jti are unaffected
+	if blacklisted, _ := s.IsTokenBlacklisted("token", jwt.MapClaims{"jti": "other", "exp": float64(exp.Unix())}); blacklisted {
+		t.Error("token with another jti blacklisted")
+	}
+}
+
+func TestTTLCache(t *testing.T) {
+	c := NewTTLCache(2)
+
+	c.Set("a", true, time.Hour)
+	c.Set("gone", true, time.Nanosecond)
+	c.Set("ignored", true, 0)
+	time.Sleep(time.Millisecond)
+
+	if v, ok := c.Get("a"); !ok || !v {
+		t.Errorf("a = %v, %v", v, ok)
+	}
+	if _, ok := c.Get("gone"); ok {
+		t.Error("expired entry returned")
+	}
+	if _, ok := c.Get("ignored"); ok {
+		t.Error("entry with a zero TTL stored")
+	}
+
+	// Full of live entries: everything is dropped to make room
+	c.Set("b", false, time.Hour)
+	c.Set("c", true, time.Hour)
+	if _, ok := c.Get("a"); ok {
+		t.Error("a survived a full cache")
+	}
+	if v, ok := c.Get("c"); !ok || !v {
+		t.Errorf("c = %v, %v", v, ok)
+	}
+
+	c.Delete("c")
+	if _, ok := c.Get("c"); ok {
+		t.Error("deleted entry returned")
+	}
+
+	// A nil cache is a valid, always-empty cache
+	var none *TTLCache
+	none.Set("a", true, time.Hour)
+	if _, ok := none.Get("a"); ok {
+		t.Error("nil cache returned a value")
+	}
+}
@@ -2,37 +2,46 @@ package service
 
 import (
 	"errors"
+	"time"
+
+	"futuremarket/models"
 	"futuremarket/repository"
 )
 
 type CartService struct {
 	Repo        repository.CartRepo
 	ProductRepo repository.ProductRepo
+	Variants    repository.VariantRepo
 }
 
 // ADD TO CART
-func (s CartService) AddToCart(userID uint, productID uint) error {
+// variantID picks a size/colour; it is required exactly when the product
+// has variants.
+func (s CartService) AddToCart(userID uint, productID uint, variantID *uint) error {
 	cart, err := s.Repo.GetOrCreateCart(userID)
 	if err != nil {
 		return err
 	}
 
-	// Ensure product exists
-	_, err = s.ProductRepo.GetProductByID(productID)
+	// Ensure product exists and is on sale
+	product, err := s.ProductRepo.GetProductByID(productID)
 	if err != nil {
 		return errors.New("product not found")
 	}
+	if !product.Live(time.Now()) {
+		return ErrProductUnavailable
+	}
 
 	// Check stock table
-	stock, err := s.ProductRepo.GetStockByProductID(productID)
+	stock, err := s.selectionStock(productID, variantID)
 	if err != nil {
-		return errors.New("stock record missing")
+		return err
 	}
 	if stock.Quantity <= 0 {
 		return errors.New("product out of stock")
 	}
 
-	return s.Repo.AddOrIncreaseItem(cart.ID, productID)
+	return s.Repo.AddOrIncreaseItem(cart.ID, productID, variantID)
 }
 
 // VIEW CART
@@ -47,20 +56,33 @@ func (s CartService) GetCart(userID uint) (map[string]any, error) {
 		return nil, err
 	}
 
+	// Items whose product was archived or unpublished stay in the cart
+	// so the customer sees what happened, but they can't be checked out
 	var total int64
+	unavailable := []uint{}
+	now := time.Now()
 	for _, item := range items {
-		total += int64(item.Quantity) * item.Product.PriceCents
+		if !item.Product.Live(now) {
+			unavailable = append(unavailable, item.ProductID)
+			continue
+		}
+		price := item.Product.PriceCents
+		if item.Variant != nil {
+			price = item.Variant.EffectivePrice(item.Product)
+		}
+		total += int64(item.Quantity) * price
 	}
 
 	return map[string]any{
-		"cart_id": cart.ID,
-		"items":   items,
-		"total":   total,
+		"cart_id":     cart.ID,
+		"items":       items,
+		"total":       total,
+		"unavailable": unavailable, // product ids to remove before checkout
 	}, nil
 }
 
 // UPDATE QUANTITY
-func (s CartService) UpdateQuantity(userID uint, productID uint, qty int) error {
+func (s CartService) UpdateQuantity(userID uint, productID uint, variantID *uint, qty int) error {
 	if qty <= 0 {
 		return errors.New("quantity must be > 0")
 	}
@@ -70,29 +92,63 @@ func (s CartService) UpdateQuantity(userID uint, productID uint, qty int) error
 		return err
 	}
 
-	_, err = s.ProductRepo.GetProductByID(productID)
+	product, err := s.ProductRepo.GetProductByID(productID)
 	if err != nil {
 		return errors.New("product not found")
 	}
+	if !product.Live(time.Now()) {
+		return ErrProductUnavailable
+	}
 
-	stock, err := s.ProductRepo.GetStockByProductID(productID)
+	stock, err := s.selectionStock(productID, variantID)
 	if err != nil {
-		return errors.New("stock record missing")
+		return err
 	}
 
 	if qty > stock.Quantity {
 		return errors.New("quantity exceeds stock")
 	}
 
-	return s.Repo.UpdateItemQuantity(cart.ID, productID, qty)
+	return s.Repo.UpdateItemQuantity(cart.ID, productID, variantID, qty)
 }
 
 // REMOVE ITEM
-func (s CartService) RemoveItem(userID uint, productID uint) error {
+func (s CartService) RemoveItem(userID uint, productID uint, variantID *uint) error {
 	cart, err := s.Repo.GetOrCreateCart(userID)
 	if err != nil {
 		return err
 	}
 
-	return s.Repo.RemoveItem(cart.ID, productID)
+	return s.Repo.RemoveItem(cart.ID, productID, variantID)
+}
+
+// selectionStock checks the variant choice for a product and returns the
+// stock row it draws from: the variant's, or the product's when the
+// product has no variants.
+func (s CartService) selectionStock(productID uint, variantID *uint) (*models.Stock, error) {
+	count, err := s.Variants.CountByProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if variantID == nil {
+		if count > 0 {
+			return nil, ErrVariantRequired
+		}
+		stock, err := s.ProductRepo.GetStockByProductID(productID)
+		if err != nil {
+			return nil, errors.New("stock record missing")
+		}
+		return stock, nil
+	}
+
+	if _, err := s.Variants.GetForProduct(productID, *variantID); err != nil {
+		return nil, ErrVariantNotFound
+	}
+
+	stock, err := s.Variants.GetStock(*variantID)
+	if err != nil {
+		return nil, errors.New("stock record missing")
+	}
+	return stock, nil
 }
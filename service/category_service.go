@@ -0,0 +1,272 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrCategoryNotFound  = errors.New("category not found")
+	ErrUnknownCategory   = errors.New("unknown category")
+	ErrInvalidCategory   = errors.New("invalid category")
+	ErrCategorySlugTaken = errors.New("a category with this slug already exists")
+	ErrCategoryCycle     = errors.New("a category can't be moved below itself")
+	ErrCategoryInUse     = errors.New("category still has subcategories or products")
+	ErrCategoryMove      = errors.New("the move conflicts with the attributes of the category")
+)
+
+// CategoryNode is a category with its subcategories, for the tree view.
+type CategoryNode struct {
+	ID       uint           `json:"id"`
+	ParentID *uint          `json:"parent_id"`
+	Name     string         `json:"name"`
+	Slug     string         `json:"slug"`
+	Position int            `json:"position"`
+	Children []CategoryNode `json:"children"`
+}
+
+// CategoryInput creates or updates a category. Nil fields are left alone
+// on update; a missing slug is derived from the name on create.
+// ParentID 0 moves a category to the top level.
+type CategoryInput struct {
+	Name     *string `json:"name"`
+	Slug     *string `json:"slug"`
+	ParentID *uint   `json:"parent_id"`
+	Position *int    `json:"position"`
+}
+
+// CategoryService manages the catalog category tree. Attributes checks
+// that moves keep the attributes of the moved categories consistent.
+type CategoryService struct {
+	Repo       repository.CategoryRepo
+	Attributes AttributeService
+}
+
+// Tree returns the top-level categories with their subcategories nested.
+func (s CategoryService) Tree() ([]CategoryNode, error) {
+	categories, err := s.Repo.List()
+	if err != nil {
+		return nil, err
+	}
+
+	// List is already in display order, so children stay sorted
+	children := map[uint][]models.Category{}
+	var roots []models.Category
+	for _, c := range categories {
+		if c.ParentID == nil {
+			roots = append(roots, c)
+		} else {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		}
+	}
+
+	var build func([]models.Category) []CategoryNode
+	build = func(level []models.Category) []CategoryNode {
+		nodes := make([]CategoryNode, 0, len(level))
+		for _, c := range level {
+			nodes = append(nodes, CategoryNode{
+				ID:       c.ID,
+				ParentID: c.ParentID,
+				Name:     c.Name,
+				Slug:     c.Slug,
+				Position: c.Position,
+				Children: build(children[c.ID]),
+			})
+		}
+		return nodes
+	}
+
+	return build(roots), nil
+}
+
+// CreateCategory adds a category. Name is required.
+func (s CategoryService) CreateCategory(in CategoryInput) (models.Category, error) {
+	if in.Name == nil {
+		return models.Category{}, fmt.Errorf("%w: name is required", ErrInvalidCategory)
+	}
+	if in.Slug == nil {
+		slug := slugify(*in.Name)
+		in.Slug = &slug
+	}
+
+	var c models.Category
+	if err := s.apply(&c, in); err != nil {
+		return models.Category{}, err
+	}
+
+	if err := s.Repo.Create(&c); err != nil {
+		return models.Category{}, err
+	}
+	return c, nil
+}
+
+// UpdateCategory renames, re-slugs, moves or reorders a category. It
+// returns the category as it was before and after.
+func (s CategoryService) UpdateCategory(id uint, in CategoryInput) (models.Category, models.Category, error) {
+	c, err := s.getCategory(id)
+	if err != nil {
+		return models.Category{}, models.Category{}, err
+	}
+	before := c
+
+	if err := s.apply(&c, in); err != nil {
+		return models.Category{}, models.Category{}, err
+	}
+
+	if err := s.Repo.Update(&c); err != nil {
+		return models.Category{}, models.Category{}, err
+	}
+	return before, c, nil
+}
+
+// DeleteCategory removes an empty category.
+func (s CategoryService) DeleteCategory(id uint) (models.Category, error) {
+	c, err := s.getCategory(id)
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	children, products, err := s.Repo.CountUsage(id)
+	if err != nil {
+		return models.Category{}, err
+	}
+	if children > 0 || products > 0 {
+		return models.Category{}, ErrCategoryInUse
+	}
+
+	return c, s.Repo.Delete(c)
+}
+
+// resolveCategory finds the category a product is assigned to, by id or by
+// slug/name (so "Electronics" finds the "electronics" category).
+func resolveCategory(repo repository.CategoryRepo, id *uint, name string) (models.Category, error) {
+	var c models.Category
+	var err error
+
+	if id != nil {
+		c, err = repo.GetByID(*id)
+	} else {
+		c, err = repo.GetBySlug(slugify(name))
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Category{}, ErrUnknownCategory
+	}
+	return c, err
+}
+
+func (s CategoryService) getCategory(id uint) (models.Category, error) {
+	c, err := s.Repo.GetByID(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Category{}, ErrCategoryNotFound
+	}
+	return c, err
+}
+
+// apply validates in and copies the fields that are set onto c.
+func (s CategoryService) apply(c *models.Category, in CategoryInput) error {
+	if in.Name != nil {
+		name := strings.TrimSpace(*in.Name)
+		if name == "" || len(name) > 100 {
+			return fmt.Errorf("%w: name must be 1-100 characters", ErrInvalidCategory)
+		}
+		c.Name = name
+	}
+
+	if in.Slug != nil {
+		slug := *in.Slug
+		if slug == "" || slug != slugify(slug) || len(slug) > 100 {
+			return fmt.Errorf("%w: slug must be lower-case letters, digits and dashes", ErrInvalidCategory)
+		}
+		taken, err := s.Repo.SlugTaken(slug, c.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrCategorySlugTaken
+		}
+		c.Slug = slug
+	}
+
+	if in.ParentID != nil {
+		var parentID *uint
+		if *in.ParentID != 0 {
+			if err := s.checkParent(c.ID, *in.ParentID); err != nil {
+				return err
+			}
+			id := *in.ParentID
+			parentID = &id
+		}
+
+		// Existing categories take their attributes and products along
+		if c.ID != 0 && !sameParent(c.ParentID, parentID) {
+			if err := s.Attributes.CheckMove(c.ID, parentID); err != nil {
+				return err
+			}
+		}
+		c.ParentID = parentID
+	}
+
+	if in.Position != nil {
+		c.Position = *in.Position
+	}
+
+	return nil
+}
+
+// checkParent makes sure parentID exists and isn't the category itself or
+// one of its descendants.
+func (s CategoryService) checkParent(id, parentID uint) error {
+	categories, err := s.Repo.List()
+	if err != nil {
+		return err
+	}
+
+	parents := map[uint]*uint{}
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+	if _, ok := parents[parentID]; !ok {
+		return fmt.Errorf("%w: parent_id %d does not exist", ErrInvalidCategory, parentID)
+	}
+
+	// Walk up from the new parent; meeting the category means a cycle
+	for p := &parentID; p != nil; p = parents[*p] {
+		if id != 0 && *p == id {
+			return ErrCategoryCycle
+		}
+	}
+	return nil
+}
+
+func sameParent(a, b *uint) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// slugify lower-cases s and joins its letters and digits with dashes, like
+// the SQL that converted the old category strings.
+func slugify(s string) string {
+	var b strings.Builder
+	dash := false
+
+	for _, r := range strings.ToLower(strings.TrimSpace(s)) {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"Electronics", "electronics"},
+		{"  Home & Garden ", "home-garden"},
+		{"TVs / 4K", "tvs-4k"},
+		{"already-a-slug", "already-a-slug"},
+		{"--Kids--", "kids"},
+		{"Café", "caf"},
+		{"", ""},
+		{"&&", ""},
+	}
+
+	for _, tt := range tests {
+		if got := slugify(tt.in); got != tt.want {
+			t.Errorf("slugify(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestMoveCategoryKeepsAttributesConsistent(t *testing.T) {
+	db := newTestDB(t, &models.Category{}, &models.AttributeDefinition{}, &models.Product{})
+	categories := repository.CategoryRepo{DB: db}
+	s := CategoryService{
+		Repo:       categories,
+		Attributes: AttributeService{Repo: repository.AttributeRepo{DB: db}, Categories: categories},
+	}
+
+	create := func(name string, parent *uint, keys ...string) models.Category {
+		c := models.Category{Name: name, Slug: slugify(name), ParentID: parent}
+		if err := db.Create(&c).Error; err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keys {
+			db.Create(&models.AttributeDefinition{CategoryID: c.ID, Key: key, Label: key, Type: models.AttributeString})
+		}
+		return c
+	}
+	electronics := create("Electronics", nil, "brand")
+	tvs := create("TVs", &electronics.ID, "panel")
+	oled := create("OLED", &tvs.ID)
+	garden := create("Garden", nil, "panel")
+	home := create("Home", nil, "brand")
+	create("Toys", nil)
+
+	product := models.Product{Name: "C3", CategoryID: &oled.ID, Status: models.ProductActive, Attributes: json.RawMessage(`{"brand":"LG","panel":"OLED"}`)}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	move := func(id, parentID uint) error {
+		_, _, err := s.UpdateCategory(id, CategoryInput{ParentID: &parentID})
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		id       uint
+		parentID uint
+		ok       bool
+	}{
+		// Garden declares panel, which TVs already does
+		{"key declared twice", tvs.ID, garden.ID, false},
+		// The OLED product keeps its brand, which only Electronics declares
+		{"values left without a definition", tvs.ID, 0, false},
+		{"subcategory's values left too", oled.ID, 0, false},
+		{"same parent again", tvs.ID, electronics.ID, true},
+		// Home declares brand as well, so the product's value still fits
+		{"key inherited from the new parent", tvs.ID, home.ID, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := move(tt.id, tt.parentID)
+			if tt.ok && err != nil {
+				t.Errorf("err = %v, want nil", err)
+			}
+			if !tt.ok && !errors.Is(err, ErrCategoryMove) {
+				t.Errorf("err = %v, want ErrCategoryMove", err)
+			}
+		})
+	}
+
+	moved, _ := categories.GetByID(tvs.ID)
+	if moved.ParentID == nil || *moved.ParentID != home.ID {
+		t.Fatalf("TVs parent = %v, want %d", moved.ParentID, home.ID)
+	}
+
+	// Without the brand value the move to the top level is fine
+	db.Model(&product).Update("attributes", `{"panel":"OLED"}`)
+	if err := move(tvs.ID, 0); err != nil {
+		t.Errorf("move without orphaned values: %v", err)
+	}
+}
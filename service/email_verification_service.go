@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/mailer"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email is already verified")
+)
+
+// ThrottledError is returned when a user asks for verification emails too
+// often. RetryAfter tells the client when to try again.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e ThrottledError) Error() string {
+	return fmt.Sprintf("too many verification emails requested, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// EmailVerificationService mails verification links and marks addresses as
+// verified when the link is used.
+type EmailVerificationService struct {
+	Repo     repository.EmailVerificationRepo
+	UserRepo repository.UserRepo
+	Mailer   mailer.Sender
+}
+
+// SendVerification mails a fresh verification link for the user's current
+// email address.
+func (s EmailVerificationService) SendVerification(user models.User) error {
+	return s.sendTo(user, user.Email)
+}
+
+// SendEmailChangeVerification mails a link to a requested new address and a
+// heads-up to the current one. The change only happens once the link is used.
+func (s EmailVerificationService) SendEmailChangeVerification(user models.User, newEmail string) error {
+	if err := s.sendTo(user, newEmail); err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Your FutureMarket email address is changing",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nSomeone asked to change the email on your account to %s. The change only happens once the new address is verified.\n\nIf this wasn't you, reset your password straight away.",
+			user.Name, newEmail,
+		),
+	})
+}
+
+// sendTo creates a verification token for email and mails the link there.
+func (s EmailVerificationService) sendTo(user models.User, email string) error {
+	if err := s.Repo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	rawToken, err := utils.RandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := config.EmailVerificationTTL()
+	err = s.Repo.Create(&models.EmailVerificationToken{
+		UserID:    user.ID,
+		Email:     email,
+		TokenHash: utils.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", config.AppBaseURL(), url.QueryEscape(rawToken))
+
+	return s.Mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Verify your FutureMarket email address",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nPlease confirm your email address using the link below. It expires in %s.\n\n%s",
+			user.Name, ttl, link,
+		),
+	})
+}
+
+// Resend mails a new verification link, throttled per user by a minimum
+// interval and an hourly cap.
+func (s EmailVerificationService) Resend(userID uint) error {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil && user.PendingEmail == "" {
+		return ErrEmailAlreadyVerified
+	}
+
+	// Minimum gap between two emails
+	latest, err := s.Repo.LatestForUser(userID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if latest != nil {
+		wait := config.EmailVerificationResendInterval() - time.Since(latest.CreatedAt)
+		if wait > 0 {
+			return ThrottledError{RetryAfter: wait}
+		}
+	}
+
+	// Hourly cap
+	sent, err := s.Repo.CountSince(userID, time.Now().Add(-time.Hour))
+	if err != nil {
+		return err
+	}
+	if sent >= int64(config.EmailVerificationMaxPerHour()) {
+		return ThrottledError{RetryAfter: time.Hour}
+	}
+
+	if user.PendingEmail != "" {
+		return s.sendTo(user, user.PendingEmail)
+	}
+	return s.SendVerification(user)
+}
+
+// Verify consumes a verification token and marks the address as verified.
+func (s EmailVerificationService) Verify(rawToken string) error {
+	return s.Repo.DB.Transaction(func(tx *gorm.DB) error {
+		repo := repository.EmailVerificationRepo{DB: tx}
+
+		token, err := repo.FindByHash(utils.HashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidVerificationToken
+			}
+			return err
+		}
+
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return ErrInvalidVerificationToken
+		}
+
+		ok, err := repo.MarkUsed(token.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidVerificationToken
+		}
+
+		users := repository.UserRepo{DB: tx}
+
+		// Either the current address, or a pending email change
+		ok, err = users.MarkEmailVerified(token.UserID, token.Email, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			if other, err := users.GetUserByEmail(token.Email); err == nil && other.ID != token.UserID {
+				return ErrEmailTaken
+			}
+
+			ok, err = users.ApplyPendingEmail(token.UserID, token.Email, time.Now())
+			if err != nil {
+				return err
+			}
+		}
+
+		// The address changed since the link was sent → link is stale
+		if !ok {
+			return ErrInvalidVerificationToken
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,81 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func TestVerifyEmail(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.EmailVerificationToken{})
+	mail := &recordingSender{}
+	s := EmailVerificationService{
+		Repo:     repository.EmailVerificationRepo{DB: db},
+		UserRepo: repository.UserRepo{DB: db},
+		Mailer:   mail,
+	}
+
+	user := models.User{Name: "New", Email: "new@example.com", Role: "customer"}
+	db.Create(&user)
+
+	if err := s.SendVerification(user); err != nil {
+		t.Fatal(err)
+	}
+	stale := mail.linkToken(t)
+
+	// Only the newest link works
+	t.Setenv("EMAIL_VERIFICATION_RESEND_INTERVAL", "1ns")
+	if err := s.Resend(user.ID); err != nil {
+		t.Fatalf("resend: %v", err)
+	}
+	fresh := mail.linkToken(t)
+
+	if err := s.Verify(stale); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("superseded link: err = %v, want ErrInvalidVerificationToken", err)
+	}
+	if err := s.Verify(fresh); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+	if err := s.Verify(fresh); !errors.Is(err, ErrInvalidVerificationToken) {
+		t.Errorf("second use: err = %v, want ErrInvalidVerificationToken", err)
+	}
+
+	verified, _ := s.UserRepo.GetUserByID(user.ID)
+	if verified.EmailVerifiedAt == nil {
+		t.Error("email not marked verified")
+	}
+	if err := s.Resend(user.ID); !errors.Is(err, ErrEmailAlreadyVerified) {
+		t.Errorf("resend after verifying: err = %v, want ErrEmailAlreadyVerified", err)
+	}
+}
+
+func TestResendIsThrottled(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.EmailVerificationToken{})
+	s := EmailVerificationService{
+		Repo:     repository.EmailVerificationRepo{DB: db},
+		UserRepo: repository.UserRepo{DB: db},
+		Mailer:   &recordingSender{},
+	}
+
+	user := models.User{Name: "New", Email: "new@example.com", Role: "customer"}
+	db.Create(&user)
+	if err := s.SendVerification(user); err != nil {
+		t.Fatal(err)
+	}
+
+	var throttled ThrottledError
+	if err := s.Resend(user.ID); !errors.As(err, &throttled) || throttled.RetryAfter <= 0 {
+		t.Errorf("resend within the interval: err = %v, want ThrottledError", err)
+	}
+
+	t.Setenv("EMAIL_VERIFICATION_RESEND_INTERVAL", "1ns")
+	t.Setenv("EMAIL_VERIFICATION_MAX_PER_HOUR", "2")
+	if err := s.Resend(user.ID); err != nil {
+		t.Fatalf("resend after the interval: %v", err)
+	}
+	if err := s.Resend(user.ID); !errors.As(err, &throttled) {
+		t.Errorf("resend over the hourly cap: err = %v, want ThrottledError", err)
+	}
+}
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+)
+
+// AttemptStore keeps failed-login counters. MemoryAttemptStore is enough for
+// a single instance; repository.LoginAttemptRepo shares counters between
+// replicas through Postgres.
+type AttemptStore interface {
+	Get(key string) (models.LoginAttempt, error)
+	RecordFailure(key string, at time.Time) (models.LoginAttempt, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+// LoginBlockedError is returned while an account or IP is locked out or
+// still waiting out its backoff delay.
+type LoginBlockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e LoginBlockedError) Error() string {
+	return fmt.Sprintf("too many attempts, try again in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginGuard throttles Login per account and per IP: every failure adds an
+// exponentially growing delay, and too many failures lock the key for a
+// while. Each lockout is written to the audit log.
+//
+// The account is whatever identifies the target: the email for logins and
+// password reset requests, the user ID for TOTP checks.
+type LoginGuard struct {
+	Store    AttemptStore
+	Audit    AuditService
+	Settings config.LoginThrottle
+
+	// Scope keeps the counters of guards sharing a Store apart, e.g.
+	// "password_reset". Empty means the login guard.
+	Scope string
+}
+
+func (g LoginGuard) accountKey(account string) string {
+	return g.scoped("account:" + strings.ToLower(strings.TrimSpace(account)))
+}
+
+func (g LoginGuard) ipKey(ip string) string {
+	return g.scoped("ip:" + ip)
+}
+
+// keys are the counters an attempt is checked against. An empty ip only
+// throttles the account.
+func (g LoginGuard) keys(account, ip string) []string {
+	if ip == "" {
+		return []string{g.accountKey(account)}
+	}
+	return []string{g.accountKey(account), g.ipKey(ip)}
+}
+
+func (g LoginGuard) scoped(key string) string {
+	if g.Scope == "" {
+		return key
+	}
+	return g.Scope + ":" + key
+}
+
+// Check returns a LoginBlockedError when the account or IP may not try
+// right now.
+func (g LoginGuard) Check(account, ip string) error {
+	now := time.Now()
+	var wait time.Duration
+
+	for _, key := range g.keys(account, ip) {
+		attempt, err := g.Store.Get(key)
+		if err != nil {
+			return err
+		}
+		if d := g.blockedFor(attempt, now); d > wait {
+			wait = d
+		}
+	}
+
+	if wait > 0 {
+		return LoginBlockedError{RetryAfter: wait}
+	}
+	return nil
+}
+
+// RecordFailure counts a failed attempt against both the account and the
+// IP, locking either one that reached its limit.
+func (g LoginGuard) RecordFailure(account, ip string) error {
+	now := time.Now()
+
+	type counter struct {
+		key   string
+		limit int
+	}
+	keys := []counter{{g.accountKey(account), g.Settings.MaxAccountFailures}}
+	if ip != "" {
+		keys = append(keys, counter{g.ipKey(ip), g.Settings.MaxIPFailures})
+	}
+
+	for _, k := range keys {
+		// Start counting from scratch once the old failures are stale
+		previous, err := g.Store.Get(k.key)
+		if err != nil {
+			return err
+		}
+		if previous.Failures > 0 && now.Sub(previous.LastFailedAt) > g.Settings.Window {
+			if err := g.Store.Reset(k.key); err != nil {
+				return err
+			}
+		}
+
+		attempt, err := g.Store.RecordFailure(k.key, now)
+		if err != nil {
+			return err
+		}
+
+		if k.limit > 0 && attempt.Failures >= k.limit {
+			until := now.Add(g.Settings.LockoutDuration)
+			if err := g.Store.Lock(k.key, until); err != nil {
+				return err
+			}
+
+			action := "login.lockout"
+			if g.Scope != "" {
+				action = g.Scope + ".lockout"
+			}
+			g.Audit.Record(nil, action, "login_key", k.key, ip, map[string]any{
+				"failures":     attempt.Failures,
+				"locked_until": until,
+			})
+		}
+	}
+
+	return nil
+}
+
+// RecordSuccess clears the account's counter. The IP counter is left to
+// expire on its own so one valid login can't reset a stuffing run.
+func (g LoginGuard) RecordSuccess(account string) error {
+	return g.Store.Reset(g.accountKey(account))
+}
+
+// Unlock lifts a lockout on an account before it expires.
+func (g LoginGuard) Unlock(account string) error {
+	return g.Store.Reset(g.accountKey(account))
+}
+
+// blockedFor returns how long a key must still wait before its next attempt.
+func (g LoginGuard) blockedFor(attempt models.LoginAttempt, now time.Time) time.Duration {
+	if attempt.LockedUntil != nil && now.Before(*attempt.LockedUntil) {
+		return attempt.LockedUntil.Sub(now)
+	}
+	if attempt.Failures == 0 || now.Sub(attempt.LastFailedAt) > g.Settings.Window {
+		return 0
+	}
+
+	// base, 2*base, 4*base, ... capped at BackoffMax
+	delay := g.Settings.BackoffBase
+	for i := 1; i < attempt.Failures && delay < g.Settings.BackoffMax; i++ {
+		delay *= 2
+	}
+	if delay > g.Settings.BackoffMax {
+		delay = g.Settings.BackoffMax
+	}
+
+	if next := attempt.LastFailedAt.Add(delay); now.Before(next) {
+		return next.Sub(now)
+	}
+	return 0
+}
+
+// MemoryAttemptStore is an in-process AttemptStore for single-instance
+// deployments and local development.
+type MemoryAttemptStore struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempt
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: map[string]models.LoginAttempt{}}
+}
+
+func (m *MemoryAttemptStore) Get(key string) (models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if attempt, ok := m.attempts[key]; ok {
+		return attempt, nil
+	}
+	return models.LoginAttempt{Key: key}, nil
+}
+
+func (m *MemoryAttemptStore) RecordFailure(key string, at time.Time) (models.LoginAttempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt := m.attempts[key]
+	attempt.Key = key
+	attempt.Failures++
+	attempt.LastFailedAt = at
+	m.attempts[key] = attempt
+
+	return attempt, nil
+}
+
+func (m *MemoryAttemptStore) Lock(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempt := m.attempts[key]
+	attempt.Key = key
+	attempt.LockedUntil = &until
+	m.attempts[key] = attempt
+
+	return nil
+}
+
+func (m *MemoryAttemptStore) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+	return nil
+}
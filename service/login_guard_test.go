@@ -0,0 +1,145 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func newTestGuard(t *testing.T, store AttemptStore, scope string) LoginGuard {
+	db := newTestDB(t, &models.AuditEvent{})
+	return LoginGuard{
+		Store: store,
+		Audit: AuditService{Repo: repository.AuditRepo{DB: db}},
+		Settings: config.LoginThrottle{
+			MaxAccountFailures: 3,
+			MaxIPFailures:      10,
+			LockoutDuration:    time.Hour,
+			BackoffBase:        time.Minute,
+			BackoffMax:         time.Hour,
+			Window:             time.Hour,
+		},
+		Scope: scope,
+	}
+}
+
+func TestLoginGuardScopesShareStore(t *testing.T) {
+	store := NewMemoryAttemptStore()
+	login := newTestGuard(t, store, "")
+	reset := newTestGuard(t, store, "password_reset")
+
+	for i := 0; i < 3; i++ {
+		if err := reset.RecordFailure("Someone@Example.com", "10.0.0.1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var blocked LoginBlockedError
+	if err := reset.Check("someone@example.com", "10.0.0.2"); !errors.As(err, &blocked) {
+		t.Fatalf("reset guard: err = %v, want LoginBlockedError", err)
+	}
+	if err := login.Check("someone@example.com", "10.0.0.1"); err != nil {
+		t.Errorf("login guard blocked by another scope's counters: %v", err)
+	}
+
+	if _, err := store.Get("password_reset:account:someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := store.Get("account:someone@example.com"); a.Failures != 0 {
+		t.Errorf("unscoped key has %d failures", a.Failures)
+	}
+
+	var events []models.AuditEvent
+	reset.Audit.Repo.DB.Find(&events)
+	if len(events) != 1 || events[0].Action != "password_reset.lockout" {
+		t.Errorf("audit events = %+v, want one password_reset.lockout", events)
+	}
+}
+
+func TestLoginGuardBackoff(t *testing.T) {
+	g := LoginGuard{Settings: config.LoginThrottle{
+		BackoffBase: time.Second,
+		BackoffMax:  time.Minute,
+		Window:      15 * time.Minute,
+	}}
+	now := time.Now()
+	future := now.Add(10 * time.Minute)
+
+	tests := []struct {
+		name    string
+		attempt models.LoginAttempt
+		want    time.Duration
+	}{
+		{"no failures", models.LoginAttempt{}, 0},
+		{"first failure", models.LoginAttempt{Failures: 1, LastFailedAt: now}, time.Second},
+		{"doubles", models.LoginAttempt{Failures: 3, LastFailedAt: now}, 4 * time.Second},
+		{"capped", models.LoginAttempt{Failures: 30, LastFailedAt: now}, time.Minute},
+		{"partly waited", models.LoginAttempt{Failures: 3, LastFailedAt: now.Add(-3 * time.Second)}, time.Second},
+		{"delay over", models.LoginAttempt{Failures: 3, LastFailedAt: now.Add(-5 * time.Second)}, 0},
+		{"outside window", models.LoginAttempt{Failures: 30, LastFailedAt: now.Add(-16 * time.Minute)}, 0},
+		{"locked", models.LoginAttempt{Failures: 1, LastFailedAt: now.Add(-time.Hour), LockedUntil: &future}, 10 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := g.blockedFor(tt.attempt, now); got != tt.want {
+				t.Errorf("blockedFor = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginGuardLockout(t *testing.T) {
+	stores := map[string]func(t *testing.T) AttemptStore{
+		"memory": func(t *testing.T) AttemptStore { return NewMemoryAttemptStore() },
+		"database": func(t *testing.T) AttemptStore {
+			return repository.LoginAttemptRepo{DB: newTestDB(t, &models.LoginAttempt{})}
+		},
+	}
+
+	for name, newStore := range stores {
+		t.Run(name, func(t *testing.T) {
+			g := newTestGuard(t, newStore(t), "")
+			g.Settings.BackoffBase = time.Nanosecond // only the lockout matters here
+
+			for i := 0; i < 2; i++ {
+				if err := g.RecordFailure("victim@example.com", "10.0.0.1"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			time.Sleep(time.Millisecond)
+			if err := g.Check("victim@example.com", "10.0.0.9"); err != nil {
+				t.Fatalf("below the limit: %v", err)
+			}
+
+			if err := g.RecordFailure("victim@example.com", "10.0.0.1"); err != nil {
+				t.Fatal(err)
+			}
+			var blocked LoginBlockedError
+			if err := g.Check("victim@example.com", "10.0.0.9"); !errors.As(err, &blocked) || blocked.RetryAfter < 59*time.Minute {
+				t.Fatalf("at the limit: err = %v, want a one hour lockout", err)
+			}
+			if err := g.Check("someone-else@example.com", "10.0.0.9"); err != nil {
+				t.Errorf("other account blocked: %v", err)
+			}
+
+			// An admin unlock lifts the lockout early
+			if err := g.Unlock("VICTIM@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if err := g.Check("victim@example.com", "10.0.0.9"); err != nil {
+				t.Errorf("after unlock: %v", err)
+			}
+
+			// The IP counter keeps going after the account was unlocked
+			attempt, err := g.Store.Get("ip:10.0.0.1")
+			if err != nil || attempt.Failures != 3 {
+				t.Errorf("ip counter = %+v, %v; want 3 failures", attempt, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"futuremarket/jwtkeys"
+	"futuremarket/mailer"
+	"futuremarket/models"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newTestDB opens a private in-memory SQLite database with the given
+// tables. It uses a single connection, so a query that doesn't go through
+// the transaction it runs inside of deadlocks instead of passing silently.
+func newTestDB(t *testing.T, tables ...any) *gorm.DB {
+	t.Helper()
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	db, err := gorm.Open(sqlite.Open(fmt.Sprintf("file:%s?mode=memory", name)), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(tables...); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// testKeyset signs with a fixed HMAC secret.
+func testKeyset() *jwtkeys.Keyset {
+	secret := []byte("test-secret-test-secret-test-secret")
+	return &jwtkeys.Keyset{
+		Active: &jwtkeys.Key{
+			ID:        "test",
+			Method:    jwt.SigningMethodHS256,
+			SignKey:   secret,
+			VerifyKey: secret,
+		},
+		Issuer:   "futuremarket",
+		Audience: "futuremarket-api",
+	}
+}
+
+// createTestUser stores a verified customer.
+func createTestUser(t *testing.T, db *gorm.DB, email string) models.User {
+	t.Helper()
+
+	now := time.Now()
+	user := models.User{Name: "Test User", Email: email, Role: "customer", EmailVerifiedAt: &now}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// recordingSender keeps every message instead of sending it.
+type recordingSender struct {
+	sent []mailer.Message
+}
+
+func (s *recordingSender) Send(msg mailer.Message) error {
+	s.sent = append(s.sent, msg)
+	return nil
+}
+
+// linkToken returns the token query parameter of the last link mailed.
+func (s *recordingSender) linkToken(t *testing.T) string {
+	t.Helper()
+
+	if len(s.sent) == 0 {
+		t.Fatal("no email sent")
+	}
+	body := s.sent[len(s.sent)-1].Body
+	i := strings.Index(body, "token=")
+	if i < 0 {
+		t.Fatalf("no link in %q", body)
+	}
+	raw := strings.Fields(body[i+len("token="):])[0]
+	token, err := url.QueryUnescape(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
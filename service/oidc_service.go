@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/oidc"
+	"futuremarket/repository"
+	"futuremarket/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidOIDCState    = errors.New("sign-in request is invalid or has expired, please start again")
+	ErrOIDCEmailRequired   = errors.New("identity provider did not share an email address")
+	ErrOIDCEmailUnverified = errors.New("an account with this email already exists; sign in with your password to link it")
+	ErrOIDCLinkPrivileged  = errors.New("this account can't be linked to an identity provider automatically; sign in with your password")
+)
+
+// OIDCService runs the "sign in with" flow against an external OpenID
+// provider and maps provider accounts onto local users.
+type OIDCService struct {
+	Client       *oidc.Client
+	States       repository.OIDCStateRepo
+	Identities   repository.UserIdentityRepo
+	UserRepo     repository.UserRepo
+	Verification EmailVerificationService
+	Audit        AuditService
+}
+
+// OIDCLogin is the outcome of a completed provider login.
+type OIDCLogin struct {
+	User       models.User
+	DeviceName string
+}
+
+// Begin stores a fresh state, nonce and PKCE verifier and returns the
+// provider URL the browser should be sent to, plus the state, which the
+// handler also binds to the browser with a cookie.
+func (s OIDCService) Begin(ctx context.Context, deviceName string) (string, string, error) {
+	state, err := utils.RandomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := utils.RandomToken(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := utils.RandomToken(32) // 43 base64url chars, the RFC 7636 minimum
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err := s.Client.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", err
+	}
+
+	// Logins that were started but never finished.
+	if err := s.States.DeleteExpired(time.Now()); err != nil {
+		log.Printf("failed to purge expired oidc states: %v", err)
+	}
+
+	if len(deviceName) > 100 {
+		deviceName = deviceName[:100]
+	}
+
+	err = s.States.Create(&models.OIDCState{
+		StateHash:    utils.HashToken(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		DeviceName:   deviceName,
+		ExpiresAt:    time.Now().Add(s.Client.Config.StateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	return authURL, state, nil
+}
+
+// Complete handles the provider's redirect: it consumes the state,
+// exchanges the code, validates the ID token and returns the linked (or
+// newly provisioned) user.
+func (s OIDCService) Complete(ctx context.Context, state, code, ip string) (OIDCLogin, error) {
+	if state == "" || code == "" {
+		return OIDCLogin{}, ErrInvalidOIDCState
+	}
+
+	stored, err := s.States.Consume(utils.HashToken(state))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return OIDCLogin{}, ErrInvalidOIDCState
+		}
+		return OIDCLogin{}, err
+	}
+	if time.Now().After(stored.ExpiresAt) {
+		return OIDCLogin{}, ErrInvalidOIDCState
+	}
+
+	tokens, err := s.Client.Exchange(ctx, code, stored.CodeVerifier)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+
+	claims, err := s.Client.VerifyIDToken(ctx, tokens.IDToken, stored.Nonce)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+
+	user, err := s.resolveUser(claims, ip)
+	if err != nil {
+		return OIDCLogin{}, err
+	}
+
+	if user.DisabledAt != nil {
+		return OIDCLogin{}, ErrAccountDisabled
+	}
+
+	return OIDCLogin{User: user, DeviceName: stored.DeviceName}, nil
+}
+
+// resolveUser finds the user for a provider account. Unknown accounts are
+// linked to an existing customer with the same address only when the
+// provider has verified that address; otherwise a new customer is created.
+// Staff accounts are never linked this way: whoever controls the address at
+// the provider would get in without the password.
+func (s OIDCService) resolveUser(claims *oidc.IDTokenClaims, ip string) (models.User, error) {
+	identity, err := s.Identities.Find(claims.Issuer, claims.Subject)
+	if err == nil {
+		return s.UserRepo.GetUserByID(identity.UserID)
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.User{}, err
+	}
+
+	email := strings.TrimSpace(claims.Email)
+	if email == "" || ValidateEmail(email) != nil {
+		return models.User{}, ErrOIDCEmailRequired
+	}
+
+	var user models.User
+	var created bool
+
+	err = s.UserRepo.DB.Transaction(func(tx *gorm.DB) error {
+		users := repository.UserRepo{DB: tx}
+
+		existing, err := users.GetUserByEmail(email)
+		switch {
+		case err == nil:
+			if !claims.EmailVerified {
+				return ErrOIDCEmailUnverified
+			}
+			if existing.Role != DefaultRole {
+				return ErrOIDCLinkPrivileged
+			}
+			user = existing
+
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			user = models.User{
+				Name:  oidcDisplayName(claims.Name),
+				Email: email,
+				Role:  DefaultRole,
+				// No password: the account signs in through the provider
+				// until the user sets one via the password reset flow.
+			}
+			if claims.EmailVerified {
+				now := time.Now()
+				user.EmailVerifiedAt = &now
+			}
+			if err := users.Create(&user); err != nil {
+				return err
+			}
+			created = true
+
+		default:
+			return err
+		}
+
+		return (repository.UserIdentityRepo{DB: tx}).Create(&models.UserIdentity{
+			UserID:  user.ID,
+			Issuer:  claims.Issuer,
+			Subject: claims.Subject,
+			Email:   email,
+		})
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+
+	action := "oidc.link"
+	if created {
+		action = "oidc.provision"
+	}
+	s.Audit.Record(&user.ID, action, "user", strconv.Itoa(int(user.ID)), ip, map[string]any{
+		"issuer":  claims.Issuer,
+		"subject": claims.Subject,
+	})
+
+	if created && user.EmailVerifiedAt == nil {
+		if err := s.Verification.SendVerification(user); err != nil {
+			log.Printf("failed to send verification email to user %d: %v", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+// oidcDisplayName uses the provider's name when it passes our own name
+// rules, and a neutral placeholder otherwise.
+func oidcDisplayName(name string) string {
+	name = strings.TrimSpace(name)
+	if ValidateName(name) == nil {
+		return name
+	}
+	return "Customer"
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/oidc"
+	"futuremarket/repository"
+)
+
+func TestOIDCResolveUser(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.UserIdentity{}, &models.EmailVerificationToken{}, &models.AuditEvent{})
+	s := OIDCService{
+		Identities: repository.UserIdentityRepo{DB: db},
+		UserRepo:   repository.UserRepo{DB: db},
+		Verification: EmailVerificationService{
+			Repo:     repository.EmailVerificationRepo{DB: db},
+			UserRepo: repository.UserRepo{DB: db},
+			Mailer:   &recordingSender{},
+		},
+		Audit: AuditService{Repo: repository.AuditRepo{DB: db}},
+	}
+
+	customer := createTestUser(t, db, "customer@example.com")
+	admin := createTestUser(t, db, "admin@example.com")
+	db.Model(&admin).Update("role", "admin")
+
+	claims := func(sub, email string, verified bool) *oidc.IDTokenClaims {
+		return &oidc.IDTokenClaims{Issuer: "https://idp.example.com", Subject: sub, Email: email, EmailVerified: verified, Name: "Ann"}
+	}
+
+	tests := []struct {
+		name     string
+		claims   *oidc.IDTokenClaims
+		wantUser uint // 0: a new user
+		wantErr  error
+	}{
+		{"verified email of a customer", claims("sub-customer", "customer@example.com", true), customer.ID, nil},
+		{"linked account", claims("sub-customer", "changed@example.com", false), customer.ID, nil},
+		{"unverified email of an existing user", claims("sub-unverified", "customer@example.com", false), 0, ErrOIDCEmailUnverified},
+		{"verified email of an admin", claims("sub-admin", "admin@example.com", true), 0, ErrOIDCLinkPrivileged},
+		{"no email", claims("sub-none", "", true), 0, ErrOIDCEmailRequired},
+		{"new email", claims("sub-new", "new@example.com", false), 0, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user, err := s.resolveUser(tt.claims, "")
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("err = %v, want %v", err, tt.wantErr)
+				}
+				if _, err := s.Identities.Find(tt.claims.Issuer, tt.claims.Subject); err == nil {
+					t.Error("identity linked anyway")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.wantUser != 0 && user.ID != tt.wantUser {
+				t.Errorf("user = %d, want %d", user.ID, tt.wantUser)
+			}
+			if tt.wantUser == 0 {
+				if user.ID == customer.ID || user.ID == admin.ID || user.Role != DefaultRole || user.PasswordHash != "" {
+					t.Errorf("provisioned user = %+v", user)
+				}
+				if user.EmailVerifiedAt != nil {
+					t.Error("unverified provider email marked verified")
+				}
+			}
+		})
+	}
+}
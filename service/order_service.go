@@ -3,18 +3,27 @@ package service
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"futuremarket/models"
+	"futuremarket/pagination"
 	"futuremarket/repository"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 )
 
+var ErrEmailNotVerified = errors.New("please verify your email address before checking out")
+
 type OrderService struct {
 	OrderRepo   repository.OrderRepo
 	CartRepo    repository.CartRepo
 	ProductRepo repository.ProductRepo
+	UserRepo    repository.UserRepo
+	Cursors     *pagination.Signer
+
+	// RequireVerifiedEmail blocks checkout until the user's email is verified.
+	RequireVerifiedEmail bool
 }
 
 func (s OrderService) Checkout(userID uint) error {
@@ -24,6 +33,16 @@ func (s OrderService) Checkout(userID uint) error {
 		return errors.New("order repository db is nil")
 	}
 
+	if s.RequireVerifiedEmail {
+		user, err := s.UserRepo.GetUserByID(userID)
+		if err != nil {
+			return err
+		}
+		if user.EmailVerifiedAt == nil {
+			return ErrEmailNotVerified
+		}
+	}
+
 	return db.Transaction(func(tx *gorm.DB) error {
 
 		// ----------------------------------------------------
@@ -47,6 +66,7 @@ func (s OrderService) Checkout(userID uint) error {
 		// ----------------------------------------------------
 		var total int64 = 0
 		orderItems := make([]models.OrderItem, 0, len(items))
+		now := time.Now()
 
 		for _, ci := range items {
 
@@ -57,8 +77,33 @@ func (s OrderService) Checkout(userID uint) error {
 				return err
 			}
 
-			// Lock stock row
-			stock, err := s.ProductRepo.GetStockLocked(tx, ci.ProductID)
+			// Drafts, archived and unscheduled products can't be bought
+			if !product.Live(now) {
+				return fmt.Errorf("%w: product %d", ErrProductUnavailable, ci.ProductID)
+			}
+
+			// Variant price/SKU; products with variants need one picked
+			price := product.PriceCents
+			sku := ""
+			if ci.VariantID != nil {
+				variant, err := (repository.VariantRepo{DB: tx}).GetForProduct(ci.ProductID, *ci.VariantID)
+				if err != nil {
+					return fmt.Errorf("variant %d of product %d is no longer available", *ci.VariantID, ci.ProductID)
+				}
+				price = variant.EffectivePrice(product)
+				sku = variant.SKU
+			} else {
+				count, err := (repository.VariantRepo{DB: tx}).CountByProduct(ci.ProductID)
+				if err != nil {
+					return err
+				}
+				if count > 0 {
+					return fmt.Errorf("choose a variant for product %d", ci.ProductID)
+				}
+			}
+
+			// Lock stock row (the variant's own when one is picked)
+			stock, err := s.ProductRepo.GetStockLocked(tx, ci.ProductID, ci.VariantID)
 			if err != nil {
 				return fmt.Errorf("missing stock record for product %d", ci.ProductID)
 			}
@@ -79,11 +124,13 @@ func (s OrderService) Checkout(userID uint) error {
 			// Build OrderItem record
 			orderItems = append(orderItems, models.OrderItem{
 				ProductID:  ci.ProductID,
+				VariantID:  ci.VariantID,
+				SKU:        sku,
 				Quantity:   ci.Quantity,
-				PriceCents: product.PriceCents,
+				PriceCents: price,
 			})
 
-			total += int64(ci.Quantity) * product.PriceCents
+			total += int64(ci.Quantity) * price
 		}
 
 		// ----------------------------------------------------
@@ -124,20 +171,11 @@ func (s OrderService) Checkout(userID uint) error {
 // ------------------------------------------------------------
 func (s OrderService) ListOrders(userID uint) ([]models.Order, error) {
 
-	db := s.OrderRepo.DB
-	if db == nil {
+	if s.OrderRepo.DB == nil {
 		return nil, errors.New("order repo db is nil")
 	}
 
-	var orders []models.Order
-
-	err := db.
-		Preload("Items").
-		Where("user_id = ?", userID).
-		Order("created_at DESC").
-		Find(&orders).Error
-
-	return orders, err
+	return s.OrderRepo.ListOrders(userID)
 }
 
 // ------------------------------------------------------------
@@ -145,41 +183,25 @@ func (s OrderService) ListOrders(userID uint) ([]models.Order, error) {
 // ------------------------------------------------------------
 func (s OrderService) ListOrdersPaginated(
 	userID uint,
-	page int,
-	limit int,
-) ([]models.Order, int64, error) {
-
-	db := s.OrderRepo.DB
-	if db == nil {
-		return nil, 0, errors.New("order repo db is nil")
-	}
+	params PageParams,
+) ([]models.Order, PaginationMeta, error) {
 
-	var (
-		orders []models.Order
-		total  int64
-	)
+	// Cursors are bound to the user they were issued for
+	list := fmt.Sprintf("orders:%d", userID)
 
-	// Base query
-	query := db.Model(&models.Order{}).Where("user_id = ?", userID)
-
-	// Count total rows
-	if err := query.Count(&total).Error; err != nil {
-		return nil, 0, err
+	req, err := pageRequest(s.Cursors, list, params, 20)
+	if err != nil {
+		return nil, PaginationMeta{}, err
 	}
 
-	offset := (page - 1) * limit
-
-	// Paginated result
-	err := query.
-		Preload("Items").
-		Order("created_at DESC").
-		Offset(offset).
-		Limit(limit).
-		Find(&orders).Error
-
+	orders, total, err := s.OrderRepo.ListOrdersPaginated(userID, req)
 	if err != nil {
-		return nil, 0, err
+		return nil, PaginationMeta{}, err
 	}
 
-	return orders, total, nil
+	orders, meta := pageMeta(s.Cursors, list, req, orders, total, func(o models.Order) (string, uint) {
+		return o.CreatedAt.Format(time.RFC3339Nano), o.ID
+	})
+
+	return orders, meta, nil
 }
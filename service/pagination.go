@@ -0,0 +1,99 @@
+package service
+
+import (
+	"math"
+
+	"futuremarket/pagination"
+)
+
+// PaginationMeta describes where a page sits in its listing. Totals are
+// left out when the client skipped the count; CurrentPage only applies to
+// page/limit requests.
+type PaginationMeta struct {
+	TotalItems  *int64 `json:"total_items,omitempty"`
+	TotalPages  *int   `json:"total_pages,omitempty"`
+	CurrentPage int    `json:"current_page,omitempty"`
+	Limit       int    `json:"limit"`
+	NextCursor  string `json:"next_cursor,omitempty"`
+	PrevCursor  string `json:"prev_cursor,omitempty"`
+}
+
+// PageParams is a page request as the client sent it: page/limit, or a
+// next_cursor/prev_cursor token from an earlier response.
+type PageParams struct {
+	Page      int
+	Limit     int
+	Cursor    string
+	SkipCount bool
+}
+
+// pageRequest checks the cursor token was issued for list and caps the
+// limit.
+func pageRequest(signer *pagination.Signer, list string, p PageParams, defaultLimit int) (pagination.Request, error) {
+	req := pagination.Request{
+		Page:      p.Page,
+		Limit:     pagination.ClampLimit(p.Limit, defaultLimit),
+		SkipCount: p.SkipCount,
+	}
+	if req.Page < 1 {
+		req.Page = 1
+	}
+
+	if p.Cursor != "" {
+		c, err := signer.Decode(p.Cursor, list)
+		if err != nil {
+			return pagination.Request{}, err
+		}
+		req.Cursor = &c
+	}
+
+	return req, nil
+}
+
+// offsetMeta is the meta of a page/limit listing without cursors.
+func offsetMeta(total int64, page, limit int) PaginationMeta {
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+	return PaginationMeta{
+		TotalItems:  &total,
+		TotalPages:  &totalPages,
+		CurrentPage: page,
+		Limit:       limit,
+	}
+}
+
+// pageMeta trims rows fetched with Limit+1 to the page and builds its meta,
+// with cursors made from the first and last row. keyOf returns a row's
+// sort key and id.
+func pageMeta[T any](
+	signer *pagination.Signer,
+	list string,
+	req pagination.Request,
+	rows []T,
+	total int64,
+	keyOf func(T) (string, uint),
+) ([]T, PaginationMeta) {
+	rows, hasNext, hasPrev := pagination.Trim(rows, req)
+
+	meta := PaginationMeta{Limit: req.Limit}
+
+	if !req.SkipCount {
+		counted := offsetMeta(total, req.Page, req.Limit)
+		meta.TotalItems, meta.TotalPages = counted.TotalItems, counted.TotalPages
+	}
+	if req.Cursor == nil {
+		meta.CurrentPage = req.Page
+	}
+
+	if len(rows) > 0 {
+		if hasNext {
+			key, id := keyOf(rows[len(rows)-1])
+			meta.NextCursor = signer.Encode(pagination.Cursor{List: list, Key: key, ID: id})
+		}
+		if hasPrev {
+			key, id := keyOf(rows[0])
+			meta.PrevCursor = signer.Encode(pagination.Cursor{List: list, Key: key, ID: id, Prev: true})
+		}
+	}
+
+	return rows, meta
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/mailer"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/utils"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// PasswordResetService implements the forgot/reset password flow.
+type PasswordResetService struct {
+	Repo         repository.PasswordResetRepo
+	UserRepo     repository.UserRepo
+	TokenService TokenService
+	Mailer       mailer.Sender
+}
+
+// RequestReset mails a reset link if the email belongs to an account.
+// Unknown emails return nil as well so callers can't probe for accounts.
+func (s PasswordResetService) RequestReset(email string) error {
+	user, err := s.UserRepo.GetUserByEmail(email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	// Only the newest link should work
+	if err := s.Repo.InvalidateForUser(user.ID); err != nil {
+		return err
+	}
+
+	rawToken, err := utils.RandomToken(32)
+	if err != nil {
+		return err
+	}
+
+	ttl := config.PasswordResetTTL()
+	err = s.Repo.Create(&models.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: utils.HashToken(rawToken),
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", config.AppBaseURL(), url.QueryEscape(rawToken))
+
+	return s.Mailer.Send(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your FutureMarket password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %s and can only be used once.\n\n%s\n\nIf you didn't ask for this, you can ignore this email.",
+			user.Name, ttl, link,
+		),
+	})
+}
+
+// ResetPassword consumes a reset token, sets the new password and signs the
+// user out of every existing session.
+func (s PasswordResetService) ResetPassword(rawToken, newPassword string) error {
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	var userID uint
+
+	err = s.Repo.DB.Transaction(func(tx *gorm.DB) error {
+		repo := repository.PasswordResetRepo{DB: tx}
+
+		token, err := repo.FindByHash(utils.HashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidResetToken
+			}
+			return err
+		}
+
+		if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+			return ErrInvalidResetToken
+		}
+
+		ok, err := repo.MarkUsed(token.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrInvalidResetToken
+		}
+
+		userID = token.UserID
+		return repository.UserRepo{DB: tx}.UpdatePasswordHash(token.UserID, string(hashed))
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.TokenService.RevokeAllForUser(userID)
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"log"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+// PermissionService keeps config.RolePermission in sync with the
+// role_permissions table.
+type PermissionService struct {
+	Repo repository.PermissionRepo
+}
+
+// SeedDefaults writes config.DefaultPolicy into an empty table so a fresh
+// database behaves exactly like the old hard-coded switch.
+func (s PermissionService) SeedDefaults() error {
+	count, err := s.Repo.Count()
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var rows []models.RolePermission
+	for role, actions := range config.DefaultPolicy() {
+		for action := range actions {
+			rows = append(rows, models.RolePermission{Role: role, Action: action})
+		}
+	}
+
+	return s.Repo.CreateMany(rows)
+}
+
+// Load reads the table and makes it the active policy.
+func (s PermissionService) Load() error {
+	rows, err := s.Repo.ListAll()
+	if err != nil {
+		return err
+	}
+
+	policy := config.Policy{}
+	for _, row := range rows {
+		if policy[row.Role] == nil {
+			policy[row.Role] = map[string]bool{}
+		}
+		policy[row.Role][row.Action] = true
+	}
+
+	config.SetPolicy(policy)
+	return nil
+}
+
+// Watch reloads the policy every interval so edits to the table are picked
+// up without a restart. It blocks, so run it in a goroutine.
+func (s PermissionService) Watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := s.Load(); err != nil {
+			log.Printf("failed to reload role permissions: %v", err)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"futuremarket/repository"
+)
+
+// exportBatchSize is how many products are read per query while exporting.
+const exportBatchSize = 500
+
+// ExportProducts writes every product, whatever its status, to w in the
+// import format, so an export can be edited and imported again. Products
+// are read and written in batches, so memory use doesn't grow with the
+// catalogue. Rows carry the product id, so products without a SKU can be
+// imported back too.
+func (s ProductService) ExportProducts(w io.Writer, format string) error {
+	var write func(ImportRow) error
+	var flush func() error
+
+	switch format {
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(importColumns); err != nil {
+			return err
+		}
+		write = func(row ImportRow) error { return cw.Write(csvRecord(row)) }
+		flush = func() error { cw.Flush(); return cw.Error() }
+	case FormatNDJSON:
+		bw := bufio.NewWriter(w)
+		enc := json.NewEncoder(bw)
+		write = func(row ImportRow) error { return enc.Encode(row) }
+		flush = bw.Flush
+	default:
+		return fmt.Errorf("%w: format must be csv or ndjson", ErrInvalidImport)
+	}
+
+	var afterID uint
+	for {
+		batch, err := s.Repo.ExportBatch(afterID, exportBatchSize)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range batch {
+			if err := write(exportRow(p)); err != nil {
+				return err
+			}
+			afterID = p.ID
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+
+		if len(batch) < exportBatchSize {
+			return nil
+		}
+	}
+}
+
+// exportRow converts a product to an import row with every field set.
+func exportRow(p repository.ExportRow) ImportRow {
+	row := ImportRow{
+		ID:          &p.ID,
+		SKU:         p.SKU,
+		Name:        &p.Name,
+		Description: &p.Description,
+		CategoryID:  p.CategoryID,
+		Category:    &p.Category,
+		PriceCents:  &p.PriceCents,
+		Stock:       &p.StockQuantity,
+		ImageURL:    &p.ImageURL,
+		ProductStatusInput: ProductStatusInput{
+			Status:      &p.Status,
+			PublishAt:   formatOptionalTime(p.PublishAt),
+			UnpublishAt: formatOptionalTime(p.UnpublishAt),
+		},
+	}
+	if len(p.Attributes) > 0 {
+		json.Unmarshal(p.Attributes, &row.Attributes)
+	}
+	return row
+}
+
+// textColumns are the free-text CSV columns, the ones a formula could hide
+// in. The rest hold numbers, times, statuses or a JSON object.
+var textColumns = map[string]bool{
+	"sku": true, "name": true, "description": true, "category": true, "image_url": true,
+}
+
+// formulaStarts are the characters that make spreadsheet apps read a cell
+// as a formula.
+const formulaStarts = "=+-@\t\r"
+
+// isFormula reports whether a spreadsheet would run cell, also after
+// stripping the ' that escapeFormula adds, which keeps escaping reversible.
+func isFormula(cell string) bool {
+	cell = strings.TrimLeft(cell, "'")
+	return cell != "" && strings.ContainsRune(formulaStarts, rune(cell[0]))
+}
+
+// escapeFormula prefixes a cell that would be run as a formula with ', so
+// spreadsheets show it as text instead.
+func escapeFormula(cell string) string {
+	if isFormula(cell) {
+		return "'" + cell
+	}
+	return cell
+}
+
+// unescapeFormula undoes escapeFormula.
+func unescapeFormula(cell string) string {
+	if strings.HasPrefix(cell, "'") && isFormula(cell[1:]) {
+		return cell[1:]
+	}
+	return cell
+}
+
+// csvRecord lays a row out in importColumns order, with formulas in text
+// cells escaped.
+func csvRecord(row ImportRow) []string {
+	record := make([]string, 0, len(importColumns))
+	for _, column := range importColumns {
+		var cell string
+		switch column {
+		case "id":
+			if row.ID != nil {
+				cell = strconv.FormatUint(uint64(*row.ID), 10)
+			}
+		case "sku":
+			cell = row.SKU
+		case "name":
+			cell = deref(row.Name)
+		case "description":
+			cell = deref(row.Description)
+		case "category_id":
+			if row.CategoryID != nil {
+				cell = strconv.FormatUint(uint64(*row.CategoryID), 10)
+			}
+		case "category":
+			cell = deref(row.Category)
+		case "price_cents":
+			cell = formatOptionalInt(row.PriceCents)
+		case "stock":
+			cell = formatOptionalInt(row.Stock)
+		case "image_url":
+			cell = deref(row.ImageURL)
+		case "status":
+			cell = deref(row.Status)
+		case "publish_at":
+			cell = deref(row.PublishAt)
+		case "unpublish_at":
+			cell = deref(row.UnpublishAt)
+		case "attributes":
+			if len(row.Attributes) > 0 {
+				encoded, _ := json.Marshal(row.Attributes)
+				cell = string(encoded)
+			}
+		}
+		if textColumns[column] {
+			cell = escapeFormula(cell)
+		}
+		record = append(record, cell)
+	}
+	return record
+}
+
+func formatOptionalInt(n *int64) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.FormatInt(*n, 10)
+}
+
+func formatOptionalTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.UTC().Format(time.RFC3339)
+	return &s
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"log"
+	"net/http"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/storage"
+	"futuremarket/utils"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrImageNotFound     = errors.New("image not found for this product")
+	ErrImageTooLarge     = errors.New("image too large")
+	ErrUnsupportedImage  = errors.New("unsupported image: upload a JPEG, PNG or GIF")
+	ErrTooManyImages     = errors.New("too many images for this product")
+	ErrInvalidImageOrder = errors.New("image_ids must list each of the product's images once")
+)
+
+// maxImagePixels caps decoded image size, so a small file that decodes to
+// a huge bitmap can't exhaust memory.
+const maxImagePixels = 40_000_000
+
+// imageFormats maps sniffed content types to the decoder format names.
+var imageFormats = map[string]string{
+	"image/jpeg": "jpeg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// ImageUpload is one file from an upload request.
+type ImageUpload struct {
+	Filename string
+	Data     []byte
+}
+
+// ImageView is a product image as shown to clients.
+type ImageView struct {
+	ID           uint   `json:"id"`
+	Position     int    `json:"position"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnail_url"`
+	ContentType  string `json:"content_type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	SizeBytes    int64  `json:"size_bytes"`
+}
+
+// ProductImageService stores uploaded product images and their
+// thumbnails. MaxBytes limits each file, MaxImages the images per product.
+type ProductImageService struct {
+	Repo        repository.ProductImageRepo
+	ProductRepo repository.ProductRepo
+	Storage     storage.Storage
+	MaxBytes    int64
+	MaxImages   int
+}
+
+// ListImages returns a product's images in display order.
+func (s ProductImageService) ListImages(productID uint) ([]ImageView, error) {
+	images, err := s.Repo.ListByProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]ImageView, len(images))
+	for i, img := range images {
+		views[i] = s.newImageView(img)
+	}
+	return views, nil
+}
+
+// Upload checks, stores and records files after the product's existing
+// images. Every file is checked before anything is stored, so a bad file
+// rejects the whole upload; if storing or recording fails part way, the
+// files stored so far are removed and nothing is recorded.
+func (s ProductImageService) Upload(ctx context.Context, productID uint, files []ImageUpload) ([]ImageView, error) {
+	if _, err := s.ProductRepo.GetProductByID(productID); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.Repo.ListByProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing)+len(files) > s.MaxImages {
+		return nil, fmt.Errorf("%w: at most %d", ErrTooManyImages, s.MaxImages)
+	}
+
+	type prepared struct {
+		image        models.ProductImage
+		data, thumb  []byte
+		thumbContent string
+	}
+	batch := make([]prepared, 0, len(files))
+
+	for _, f := range files {
+		if int64(len(f.Data)) > s.MaxBytes {
+			return nil, fmt.Errorf("%w: %q is over %d bytes", ErrImageTooLarge, f.Filename, s.MaxBytes)
+		}
+
+		// Trust the bytes, not the file name or the part's Content-Type
+		contentType := http.DetectContentType(f.Data)
+		format, ok := imageFormats[contentType]
+		if !ok {
+			return nil, fmt.Errorf("%w (%q looks like %s)", ErrUnsupportedImage, f.Filename, contentType)
+		}
+
+		cfg, decodedFormat, err := image.DecodeConfig(bytes.NewReader(f.Data))
+		if err != nil || decodedFormat != format {
+			return nil, fmt.Errorf("%w (%q can't be decoded)", ErrUnsupportedImage, f.Filename)
+		}
+		if cfg.Width*cfg.Height > maxImagePixels {
+			return nil, fmt.Errorf("%w: %q is %dx%d pixels", ErrImageTooLarge, f.Filename, cfg.Width, cfg.Height)
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(f.Data))
+		if err != nil {
+			return nil, fmt.Errorf("%w (%q can't be decoded)", ErrUnsupportedImage, f.Filename)
+		}
+		thumb, thumbContent, err := makeThumbnail(img, format)
+		if err != nil {
+			return nil, err
+		}
+
+		batch = append(batch, prepared{
+			image: models.ProductImage{
+				ProductID:   productID,
+				ContentType: contentType,
+				Width:       cfg.Width,
+				Height:      cfg.Height,
+				SizeBytes:   int64(len(f.Data)),
+			},
+			data:         f.Data,
+			thumb:        thumb,
+			thumbContent: thumbContent,
+		})
+	}
+
+	position := 0
+	if len(existing) > 0 {
+		position = existing[len(existing)-1].Position + 1
+	}
+
+	// Store every file, then record them all in one transaction. Whatever
+	// fails, the files already stored are removed again.
+	images := make([]models.ProductImage, 0, len(batch))
+	rollback := func() {
+		for _, img := range images {
+			s.removeFiles(img)
+		}
+	}
+
+	for _, p := range batch {
+		img := p.image
+		if img.Key, img.ThumbKey, err = imageKeys(productID, img.ContentType, p.thumbContent); err != nil {
+			rollback()
+			return nil, err
+		}
+		img.Position = position
+		position++
+		images = append(images, img)
+
+		if err := s.Storage.Put(ctx, img.Key, p.data, img.ContentType); err != nil {
+			rollback()
+			return nil, err
+		}
+		if err := s.Storage.Put(ctx, img.ThumbKey, p.thumb, p.thumbContent); err != nil {
+			rollback()
+			return nil, err
+		}
+	}
+
+	err = s.Repo.DB.Transaction(func(tx *gorm.DB) error {
+		repo := repository.ProductImageRepo{DB: tx}
+		for i := range images {
+			if err := repo.Create(&images[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		rollback()
+		return nil, err
+	}
+
+	views := make([]ImageView, len(images))
+	for i, img := range images {
+		views[i] = s.newImageView(img)
+	}
+
+	return views, s.syncProductImage(productID)
+}
+
+// Reorder puts a product's images in the order of ids, which must name
+// each of them exactly once.
+func (s ProductImageService) Reorder(productID uint, ids []uint) ([]ImageView, error) {
+	images, err := s.Repo.ListByProduct(productID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ids) != len(images) {
+		return nil, ErrInvalidImageOrder
+	}
+	remaining := make(map[uint]bool, len(images))
+	for _, img := range images {
+		remaining[img.ID] = true
+	}
+	for _, id := range ids {
+		if !remaining[id] {
+			return nil, ErrInvalidImageOrder
+		}
+		delete(remaining, id)
+	}
+
+	if err := s.Repo.Reorder(productID, ids); err != nil {
+		return nil, err
+	}
+	if err := s.syncProductImage(productID); err != nil {
+		return nil, err
+	}
+	return s.ListImages(productID)
+}
+
+// DeleteImage removes an image and its files.
+func (s ProductImageService) DeleteImage(ctx context.Context, productID, imageID uint) (ImageView, error) {
+	img, err := s.Repo.GetForProduct(productID, imageID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ImageView{}, ErrImageNotFound
+	}
+	if err != nil {
+		return ImageView{}, err
+	}
+
+	if err := s.Repo.Delete(img); err != nil {
+		return ImageView{}, err
+	}
+	s.removeFiles(img)
+
+	view := s.newImageView(img)
+	if err := s.syncProductImage(productID); err != nil {
+		return ImageView{}, err
+	}
+
+	// The last uploaded image is gone; don't keep pointing at it
+	product, err := s.ProductRepo.GetProductByID(productID)
+	if err == nil && product.ImageURL == view.URL {
+		err = s.Repo.SetProductImageURL(productID, "")
+	}
+	return view, err
+}
+
+// syncProductImage copies the URL of the first image into the product's
+// ImageURL, which listings and older clients use.
+func (s ProductImageService) syncProductImage(productID uint) error {
+	images, err := s.Repo.ListByProduct(productID)
+	if err != nil || len(images) == 0 {
+		return err
+	}
+	return s.Repo.SetProductImageURL(productID, s.Storage.URL(images[0].Key))
+}
+
+// removeFiles deletes an image's files. Failures only leave orphaned
+// files behind, so they are logged rather than returned.
+func (s ProductImageService) removeFiles(img models.ProductImage) {
+	ctx := context.Background()
+	for _, key := range []string{img.Key, img.ThumbKey} {
+		if err := s.Storage.Delete(ctx, key); err != nil {
+			log.Printf("product images: could not delete %s: %v", key, err)
+		}
+	}
+}
+
+func (s ProductImageService) newImageView(img models.ProductImage) ImageView {
+	return ImageView{
+		ID:           img.ID,
+		Position:     img.Position,
+		URL:          s.Storage.URL(img.Key),
+		ThumbnailURL: s.Storage.URL(img.ThumbKey),
+		ContentType:  img.ContentType,
+		Width:        img.Width,
+		Height:       img.Height,
+		SizeBytes:    img.SizeBytes,
+	}
+}
+
+// imageKeys returns fresh storage keys for an image and its thumbnail.
+// Keys are random so a replaced image never hits a stale cache.
+func imageKeys(productID uint, contentType, thumbContent string) (string, string, error) {
+	name, err := utils.RandomToken(12)
+	if err != nil {
+		return "", "", err
+	}
+	base := fmt.Sprintf("products/%d/%s", productID, name)
+	return base + "." + imageFormats[contentType], base + "_thumb." + imageFormats[thumbContent], nil
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+// memStorage keeps files in memory. Put fails once failAfter files are
+// stored, when failAfter is set.
+type memStorage struct {
+	files     map[string][]byte
+	failAfter int
+	puts      int
+}
+
+func (s *memStorage) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	s.puts++
+	if s.failAfter > 0 && s.puts > s.failAfter {
+		return errors.New("storage unavailable")
+	}
+	s.files[key] = data
+	return nil
+}
+
+func (s *memStorage) Delete(ctx context.Context, key string) error {
+	delete(s.files, key)
+	return nil
+}
+
+func (s *memStorage) URL(key string) string {
+	return "https://cdn.example.com/" + key
+}
+
+func newTestImageService(t *testing.T) (ProductImageService, *memStorage, models.Product) {
+	db := newTestDB(t, &models.Product{}, &models.ProductImage{})
+	store := &memStorage{files: map[string][]byte{}}
+
+	product := models.Product{Name: "Lamp", Status: models.ProductActive, Attributes: json.RawMessage(`{}`)}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	return ProductImageService{
+		Repo:        repository.ProductImageRepo{DB: db},
+		ProductRepo: repository.ProductRepo{DB: db},
+		Storage:     store,
+		MaxBytes:    1 << 20,
+		MaxImages:   3,
+	}, store, product
+}
+
+// encodeImage returns a w×h image in format (jpeg, png or gif).
+func encodeImage(t *testing.T, format string, w, h int) []byte {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 128, 255})
+		}
+	}
+
+	var buf bytes.Buffer
+	var err error
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, img, nil)
+	case "png":
+		err = png.Encode(&buf, img)
+	case "gif":
+		err = gif.Encode(&buf, img, nil)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// hugePNG is a tiny PNG whose header claims w×h pixels.
+func hugePNG(t *testing.T, w, h uint32) []byte {
+	data := encodeImage(t, "png", 1, 1)
+	// Signature (8), IHDR length (4) and type (4), then width and height
+	binary.BigEndian.PutUint32(data[16:], w)
+	binary.BigEndian.PutUint32(data[20:], h)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+	return data
+}
+
+func TestUploadImages(t *testing.T) {
+	s, store, product := newTestImageService(t)
+
+	views, err := s.Upload(context.Background(), product.ID, []ImageUpload{
+		{Filename: "photo.png", Data: encodeImage(t, "jpeg", 1000, 500)}, // the name lies
+		{Filename: "icon.gif", Data: encodeImage(t, "gif", 40, 80)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		view          ImageView
+		contentType   string
+		width, height int
+		thumbFormat   string
+		thumbW        int
+		thumbH        int
+	}{
+		{views[0], "image/jpeg", 1000, 500, "jpeg", thumbnailSize, thumbnailSize / 2},
+		// Small images keep their size; GIF thumbnails become PNG
+		{views[1], "image/gif", 40, 80, "png", 40, 80},
+	}
+	for i, tt := range tests {
+		v := tt.view
+		if v.Position != i || v.ContentType != tt.contentType || v.Width != tt.width || v.Height != tt.height {
+			t.Errorf("image %d = %+v", i, v)
+		}
+
+		key := strings.TrimPrefix(v.ThumbnailURL, "https://cdn.example.com/")
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(store.files[key]))
+		if err != nil {
+			t.Fatalf("thumbnail %d: %v", i, err)
+		}
+		if format != tt.thumbFormat || cfg.Width != tt.thumbW || cfg.Height != tt.thumbH {
+			t.Errorf("thumbnail %d: %s %dx%d, want %s %dx%d", i, format, cfg.Width, cfg.Height, tt.thumbFormat, tt.thumbW, tt.thumbH)
+		}
+	}
+	if len(store.files) != 4 {
+		t.Errorf("%d files stored, want 4", len(store.files))
+	}
+
+	// The first image becomes the product's image
+	updated, _ := s.ProductRepo.GetProductByID(product.ID)
+	if updated.ImageURL != views[0].URL {
+		t.Errorf("product image = %q, want %q", updated.ImageURL, views[0].URL)
+	}
+
+	// New uploads go after the existing images, up to MaxImages
+	more, err := s.Upload(context.Background(), product.ID, []ImageUpload{{Filename: "c.png", Data: encodeImage(t, "png", 10, 10)}})
+	if err != nil || more[0].Position != 2 {
+		t.Fatalf("third image: %+v, %v", more, err)
+	}
+	_, err = s.Upload(context.Background(), product.ID, []ImageUpload{{Filename: "d.png", Data: encodeImage(t, "png", 10, 10)}})
+	if !errors.Is(err, ErrTooManyImages) {
+		t.Errorf("fourth image: err = %v, want ErrTooManyImages", err)
+	}
+}
+
+func TestUploadRejects(t *testing.T) {
+	png := encodeImage(t, "png", 10, 10)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"text named like an image", []byte("just some text, not a picture"), ErrUnsupportedImage},
+		{"svg", []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`), ErrUnsupportedImage},
+		{"truncated png", png[:30], ErrUnsupportedImage},
+		{"over MaxBytes", append(bytes.Clone(png), make([]byte, 1<<20)...), ErrImageTooLarge},
+		{"too many pixels", hugePNG(t, 10000, 10000), ErrImageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, store, product := newTestImageService(t)
+
+			// The good file in front of the bad one isn't stored either
+			_, err := s.Upload(context.Background(), product.ID, []ImageUpload{
+				{Filename: "good.png", Data: png},
+				{Filename: "bad.jpg", Data: tt.data},
+			})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+			if len(store.files) != 0 {
+				t.Errorf("%d files stored", len(store.files))
+			}
+		})
+	}
+}
+
+func TestUploadRollsBackStoredFiles(t *testing.T) {
+	s, store, product := newTestImageService(t)
+
+	// The first image and its thumbnail go through, the second image doesn't
+	store.failAfter = 3
+	_, err := s.Upload(context.Background(), product.ID, []ImageUpload{
+		{Filename: "a.png", Data: encodeImage(t, "png", 10, 10)},
+		{Filename: "b.png", Data: encodeImage(t, "png", 10, 10)},
+	})
+	if err == nil {
+		t.Fatal("upload succeeded")
+	}
+
+	if len(store.files) != 0 {
+		t.Errorf("files left behind: %d", len(store.files))
+	}
+	images, _ := s.ListImages(product.ID)
+	if len(images) != 0 {
+		t.Errorf("images recorded: %+v", images)
+	}
+}
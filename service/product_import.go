@@ -0,0 +1,420 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+
+	"gorm.io/gorm"
+)
+
+// Bulk import/export formats.
+const (
+	FormatCSV    = "csv"
+	FormatNDJSON = "ndjson"
+)
+
+// maxImportRows caps the rows of a single import.
+const maxImportRows = 50000
+
+var (
+	ErrInvalidImport = errors.New("invalid import file")
+
+	// errRollback ends an import transaction without committing it
+	errRollback = errors.New("rollback")
+)
+
+// importColumns are the fields of import and export rows, in export order.
+var importColumns = []string{
+	"id", "sku", "name", "description", "category_id", "category", "price_cents", "stock",
+	"image_url", "status", "publish_at", "unpublish_at", "attributes",
+}
+
+// ImportRow is one product in an import file. Products are matched by SKU,
+// or by ID for rows without one; fields left out (nil, or an empty CSV
+// cell) keep their current value on update. New products need a SKU, name
+// and price_cents.
+type ImportRow struct {
+	ID          *uint                      `json:"id"` // only used without a SKU
+	SKU         string                     `json:"sku"`
+	Name        *string                    `json:"name"`
+	Description *string                    `json:"description"`
+	CategoryID  *uint                      `json:"category_id"`
+	Category    *string                    `json:"category"` // slug or name; category_id wins
+	PriceCents  *int64                     `json:"price_cents"`
+	Stock       *int64                     `json:"stock"`
+	ImageURL    *string                    `json:"image_url"`
+	Attributes  map[string]json.RawMessage `json:"attributes"`
+	ProductStatusInput
+}
+
+// ImportOptions controls an import. With ChunkSize 0 the whole file runs
+// in one transaction that only commits if every row is valid; otherwise
+// each chunk of rows commits on its own and invalid rows are skipped.
+type ImportOptions struct {
+	Format    string
+	DryRun    bool
+	ChunkSize int
+}
+
+// ImportRowError reports why a row was rejected. Line is the row's line in
+// the file.
+type ImportRowError struct {
+	Line  int    `json:"line"`
+	SKU   string `json:"sku,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportResult summarises an import. Created and Updated count the rows
+// that went through; they are only saved when Committed is set, which it
+// never is for dry runs or all-or-nothing imports with failed rows.
+type ImportResult struct {
+	Rows      int              `json:"rows"`
+	Created   int              `json:"created"`
+	Updated   int              `json:"updated"`
+	Failed    int              `json:"failed"`
+	DryRun    bool             `json:"dry_run"`
+	Committed bool             `json:"committed"`
+	Errors    []ImportRowError `json:"errors"`
+}
+
+// importLine is a parsed row, or the error that kept it from parsing.
+type importLine struct {
+	line int
+	row  ImportRow
+	err  error
+}
+
+// ImportProducts creates or updates products from a CSV or NDJSON file,
+// matching them by SKU. Every row goes through the same checks as the
+// admin API; a failing row is rolled back to a savepoint so the rest of
+// its transaction carries on.
+func (s ProductService) ImportProducts(r io.Reader, opts ImportOptions) (ImportResult, error) {
+	lines, err := readImportLines(r, opts.Format)
+	if err != nil {
+		return ImportResult{}, err
+	}
+
+	result := ImportResult{Rows: len(lines), DryRun: opts.DryRun, Errors: []ImportRowError{}}
+	allOrNothing := opts.ChunkSize <= 0
+	chunkSize := opts.ChunkSize
+	if allOrNothing {
+		chunkSize = len(lines)
+	}
+
+	for start := 0; start < len(lines); start += chunkSize {
+		chunk := lines[start:min(start+chunkSize, len(lines))]
+
+		var created, updated int
+		var failures []ImportRowError
+
+		err := s.Repo.DB.Transaction(func(tx *gorm.DB) error {
+			for _, l := range chunk {
+				isNew, err := s.importLine(tx, l)
+				switch {
+				case err != nil:
+					failures = append(failures, ImportRowError{Line: l.line, SKU: l.row.SKU, Error: err.Error()})
+				case isNew:
+					created++
+				default:
+					updated++
+				}
+			}
+
+			if opts.DryRun || (allOrNothing && len(failures) > 0) {
+				return errRollback
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, errRollback) {
+			return result, err
+		}
+
+		result.Created += created
+		result.Updated += updated
+		result.Failed += len(failures)
+		result.Errors = append(result.Errors, failures...)
+		result.Committed = result.Committed || err == nil
+	}
+
+	return result, nil
+}
+
+// importLine saves one row inside its own savepoint. It reports whether
+// the product was new.
+func (s ProductService) importLine(tx *gorm.DB, l importLine) (bool, error) {
+	if l.err != nil {
+		return false, l.err
+	}
+
+	var isNew bool
+	err := tx.Transaction(func(sp *gorm.DB) error {
+		var err error
+		isNew, err = s.withDB(sp).upsertRow(l.row)
+		return err
+	})
+	return isNew, err
+}
+
+// upsertRow creates the product with the row's SKU or updates it. Rows
+// without a SKU update the product with their id.
+func (s ProductService) upsertRow(row ImportRow) (bool, error) {
+	if row.SKU == "" && row.ID == nil {
+		return false, fmt.Errorf("%w: sku or id is required", ErrInvalidProduct)
+	}
+	if row.PriceCents != nil && *row.PriceCents <= 0 {
+		return false, fmt.Errorf("%w: price_cents must be positive", ErrInvalidProduct)
+	}
+	if row.Stock != nil && *row.Stock < 0 {
+		return false, fmt.Errorf("%w: stock can't be negative", ErrInvalidProduct)
+	}
+
+	data := models.Product{
+		SKU:         row.SKU,
+		Name:        deref(row.Name),
+		Description: deref(row.Description),
+		CategoryID:  row.CategoryID,
+		Category:    deref(row.Category),
+		PriceCents:  deref(row.PriceCents),
+		Stock:       deref(row.Stock),
+		ImageURL:    deref(row.ImageURL),
+	}
+
+	var existing models.Product
+	var err error
+	if row.SKU != "" {
+		existing, err = s.Repo.GetBySKU(row.SKU)
+	} else if existing, err = s.Repo.GetProductByID(*row.ID); errors.Is(err, gorm.ErrRecordNotFound) {
+		// Ids come from an export; they never create products
+		return false, fmt.Errorf("%w: no product with id %d", ErrInvalidProduct, *row.ID)
+	}
+
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if row.Name == nil || row.PriceCents == nil {
+			return false, fmt.Errorf("%w: name and price_cents are required for new products", ErrInvalidProduct)
+		}
+		if err := s.CreateProduct(&data, row.Attributes, row.ProductStatusInput); err != nil {
+			return false, err
+		}
+		return true, s.Repo.SetStock(data.ID, data.Stock)
+
+	case err != nil:
+		return false, err
+	}
+
+	if _, err := s.UpdateProduct(existing.ID, &data, row.Attributes, row.ProductStatusInput); err != nil {
+		return false, err
+	}
+	if row.Stock != nil {
+		return false, s.Repo.SetStock(existing.ID, *row.Stock)
+	}
+	return false, nil
+}
+
+// withDB returns a copy of s whose repositories use db, e.g. a transaction.
+func (s ProductService) withDB(db *gorm.DB) ProductService {
+	s.Repo = repository.ProductRepo{DB: db}
+	s.Categories = repository.CategoryRepo{DB: db}
+	s.Attributes.Repo = repository.AttributeRepo{DB: db}
+	s.Attributes.Categories = s.Categories
+	return s
+}
+
+// readImportLines parses a whole file. Problems with the file as a whole
+// (unknown format or columns, malformed CSV, too many rows) are errors;
+// problems with single rows are kept on their line.
+func readImportLines(r io.Reader, format string) ([]importLine, error) {
+	var lines []importLine
+	var err error
+
+	switch format {
+	case FormatCSV:
+		lines, err = readCSVLines(r)
+	case FormatNDJSON:
+		lines, err = readNDJSONLines(r)
+	default:
+		return nil, fmt.Errorf("%w: format must be csv or ndjson", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("%w: no rows", ErrInvalidImport)
+	}
+	return lines, nil
+}
+
+func readCSVLines(r io.Reader) ([]importLine, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 0 // every row as wide as the header
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: no rows", ErrInvalidImport)
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+
+	known := map[string]bool{}
+	for _, c := range importColumns {
+		known[c] = true
+	}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if !known[name] {
+			return nil, fmt.Errorf("%w: unknown column %q (use %s)", ErrInvalidImport, name, strings.Join(importColumns, ", "))
+		}
+		header[i] = name
+	}
+
+	var lines []importLine
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		if len(lines) == maxImportRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, maxImportRows)
+		}
+
+		line, _ := reader.FieldPos(0)
+		row, err := parseCSVRow(header, record)
+		lines = append(lines, importLine{line: line, row: row, err: err})
+	}
+}
+
+// csvError wraps CSV syntax errors as ErrInvalidImport and passes read
+// errors (such as a body over the size limit) through.
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	return err
+}
+
+// parseCSVRow converts a record; empty cells stay unset. Text cells lose
+// the ' an export put in front of formulas.
+func parseCSVRow(header, record []string) (ImportRow, error) {
+	var row ImportRow
+
+	for i, cell := range record {
+		cell = strings.TrimSpace(cell)
+		if cell == "" {
+			continue
+		}
+		if textColumns[header[i]] {
+			cell = unescapeFormula(cell)
+		}
+
+		var err error
+		switch header[i] {
+		case "id":
+			var id uint64
+			if id, err = strconv.ParseUint(cell, 10, 64); err == nil {
+				productID := uint(id)
+				row.ID = &productID
+			}
+		case "sku":
+			row.SKU = cell
+		case "name":
+			row.Name = &cell
+		case "description":
+			row.Description = &cell
+		case "category":
+			row.Category = &cell
+		case "image_url":
+			row.ImageURL = &cell
+		case "status":
+			row.Status = &cell
+		case "publish_at":
+			row.PublishAt = &cell
+		case "unpublish_at":
+			row.UnpublishAt = &cell
+		case "category_id":
+			var id uint64
+			if id, err = strconv.ParseUint(cell, 10, 64); err == nil {
+				categoryID := uint(id)
+				row.CategoryID = &categoryID
+			}
+		case "price_cents":
+			row.PriceCents, err = parseOptionalInt(cell)
+		case "stock":
+			row.Stock, err = parseOptionalInt(cell)
+		case "attributes":
+			err = json.Unmarshal([]byte(cell), &row.Attributes)
+		}
+		if err != nil {
+			return row, fmt.Errorf("%w: %s: %q is not valid", ErrInvalidProduct, header[i], cell)
+		}
+	}
+
+	return row, nil
+}
+
+func readNDJSONLines(r io.Reader) ([]importLine, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20) // rows up to 1 MB
+
+	var lines []importLine
+	for n := 1; scanner.Scan(); n++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		if len(lines) == maxImportRows {
+			return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidImport, maxImportRows)
+		}
+
+		var row ImportRow
+		dec := json.NewDecoder(bytes.NewReader(text))
+		dec.DisallowUnknownFields()
+
+		l := importLine{line: n}
+		if err := dec.Decode(&row); err != nil {
+			l.err = fmt.Errorf("%w: %v", ErrInvalidProduct, err)
+		}
+		l.row = row
+		lines = append(lines, l)
+	}
+
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%w: a line is longer than 1 MB", ErrInvalidImport)
+		}
+		return nil, err
+	}
+	return lines, nil
+}
+
+func parseOptionalInt(s string) (*int64, error) {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// deref returns *p, or the zero value for nil.
+func deref[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
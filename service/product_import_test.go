@@ -0,0 +1,201 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/pagination"
+	"futuremarket/repository"
+)
+
+func TestParseCSVRow(t *testing.T) {
+	header := []string{"id", "sku", "name", "category_id", "price_cents", "stock", "status", "attributes"}
+	ptr := func(s string) *string { return &s }
+	num := func(n int64) *int64 { return &n }
+	id := func(n uint) *uint { return &n }
+
+	tests := []struct {
+		name   string
+		record []string
+		want   ImportRow
+		bad    string // column named in the error, if any
+	}{
+		{
+			"every column",
+			[]string{"7", " LAMP-1 ", "Desk lamp", "3", "1999", "0", "draft", `{"watts": 40}`},
+			ImportRow{ID: id(7), SKU: "LAMP-1", Name: ptr("Desk lamp"), CategoryID: id(3), PriceCents: num(1999), Stock: num(0),
+				ProductStatusInput: ProductStatusInput{Status: ptr("draft")}, Attributes: map[string]json.RawMessage{"watts": json.RawMessage("40")}},
+			"",
+		},
+		{"empty cells stay unset", []string{"", "LAMP-1", " ", "", "", "", "", ""}, ImportRow{SKU: "LAMP-1"}, ""},
+		{"escaped formula", []string{"", "'-LAMP", "'=1+1", "", "", "", "", ""}, ImportRow{SKU: "-LAMP", Name: ptr("=1+1")}, ""},
+		{"quote that escapes nothing", []string{"", "", "'Tis the season", "", "", "", "", ""}, ImportRow{Name: ptr("'Tis the season")}, ""},
+		{"bad id", []string{"x", "", "", "", "", "", "", ""}, ImportRow{}, "id"},
+		{"negative id", []string{"-1", "", "", "", "", "", "", ""}, ImportRow{}, "id"},
+		{"bad category_id", []string{"", "", "", "tvs", "", "", "", ""}, ImportRow{}, "category_id"},
+		{"bad price", []string{"", "", "", "", "19.99", "", "", ""}, ImportRow{}, "price_cents"},
+		{"bad stock", []string{"", "", "", "", "", "lots", "", ""}, ImportRow{}, "stock"},
+		{"bad attributes", []string{"", "", "", "", "", "", "", `{"watts":`}, ImportRow{}, "attributes"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			row, err := parseCSVRow(header, tt.record)
+			if tt.bad != "" {
+				if !errors.Is(err, ErrInvalidProduct) || !strings.Contains(err.Error(), tt.bad+":") {
+					t.Errorf("err = %v, want ErrInvalidProduct for %s", err, tt.bad)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(row, tt.want) {
+				t.Errorf("row = %+v\nwant  %+v", row, tt.want)
+			}
+		})
+	}
+}
+
+func TestEscapeFormula(t *testing.T) {
+	tests := []struct {
+		cell, want string
+	}{
+		{"Desk lamp", "Desk lamp"},
+		{"=HYPERLINK(\"http://evil.example\")", "'=HYPERLINK(\"http://evil.example\")"},
+		{"+1", "'+1"},
+		{"-5 degrees", "'-5 degrees"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\t=1", "'\t=1"},
+		{"'Tis", "'Tis"},
+		{"'=1", "''=1"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		got := escapeFormula(tt.cell)
+		if got != tt.want {
+			t.Errorf("escapeFormula(%q) = %q, want %q", tt.cell, got, tt.want)
+		}
+		if back := unescapeFormula(got); back != tt.cell {
+			t.Errorf("unescapeFormula(%q) = %q, want %q", got, back, tt.cell)
+		}
+	}
+}
+
+func newTestImportService(t *testing.T) ProductService {
+	db := newTestDB(t, &models.Product{}, &models.Stock{}, &models.Category{}, &models.AttributeDefinition{})
+	return ProductService{
+		Repo:       repository.ProductRepo{DB: db},
+		Categories: repository.CategoryRepo{DB: db},
+		Attributes: AttributeService{Repo: repository.AttributeRepo{DB: db}, Categories: repository.CategoryRepo{DB: db}},
+		Cursors:    pagination.NewSigner([]byte("test")),
+	}
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatCSV, FormatNDJSON} {
+		t.Run(format, func(t *testing.T) {
+			s := newTestImportService(t)
+			db := s.Repo.DB
+
+			products := []models.Product{
+				{SKU: "LAMP-1", Name: `=HYPERLINK("http://evil.example","Lamp")`, Description: "-5 degrees, @home", PriceCents: 1999},
+				{Name: "No SKU", Description: "'Tis the season", PriceCents: 500, Status: models.ProductDraft},
+			}
+			for i := range products {
+				if products[i].Status == "" {
+					products[i].Status = models.ProductActive
+				}
+				products[i].Attributes = json.RawMessage(`{}`)
+				if err := db.Create(&products[i]).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.Repo.SetStock(products[1].ID, 4); err != nil {
+				t.Fatal(err)
+			}
+
+			var exported bytes.Buffer
+			if err := s.ExportProducts(&exported, format); err != nil {
+				t.Fatal(err)
+			}
+			if format == FormatCSV && !strings.Contains(exported.String(), `"'=HYPERLINK(`) {
+				t.Errorf("formula not escaped:\n%s", exported.String())
+			}
+
+			// Importing an unchanged export updates every product in place
+			result, err := s.ImportProducts(bytes.NewReader(exported.Bytes()), ImportOptions{Format: format})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Updated != 2 || result.Created != 0 || result.Failed != 0 || !result.Committed {
+				t.Fatalf("result = %+v", result)
+			}
+
+			var after []models.Product
+			db.Order("id").Find(&after)
+			for i, p := range after {
+				want := products[i]
+				if p.SKU != want.SKU || p.Name != want.Name || p.Description != want.Description ||
+					p.PriceCents != want.PriceCents || p.Status != want.Status {
+					t.Errorf("product %d = %+v\nwant        %+v", i, p, want)
+				}
+			}
+			if after[1].Stock != 4 {
+				t.Errorf("stock = %d, want 4", after[1].Stock)
+			}
+
+			// An edit to the product without a SKU finds it by id
+			edited := strings.Replace(exported.String(), "No SKU", "Still no SKU", 1)
+			if _, err := s.ImportProducts(strings.NewReader(edited), ImportOptions{Format: format}); err != nil {
+				t.Fatal(err)
+			}
+			updated, _ := s.Repo.GetProductByID(products[1].ID)
+			if updated.Name != "Still no SKU" || updated.SKU != "" {
+				t.Errorf("product without SKU = %+v", updated)
+			}
+		})
+	}
+}
+
+func TestImportMatchesByID(t *testing.T) {
+	s := newTestImportService(t)
+
+	tests := []struct {
+		name string
+		csv  string
+		want string // error of the row, "" when it goes through
+	}{
+		{"neither sku nor id", "name,price_cents\nLamp,100\n", "sku or id is required"},
+		{"unknown id", "id,name\n999,Lamp\n", "no product with id 999"},
+		{"id never creates", "id,name,price_cents\n999,Lamp,100\n", "no product with id 999"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := s.ImportProducts(strings.NewReader(tt.csv), ImportOptions{Format: FormatCSV})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Error, tt.want) {
+				t.Errorf("errors = %+v, want %q", result.Errors, tt.want)
+			}
+		})
+	}
+
+	// With a SKU the id is ignored, so exports move between databases
+	result, err := s.ImportProducts(strings.NewReader("id,sku,name,price_cents\n999,NEW-1,Lamp,100\n"), ImportOptions{Format: FormatCSV})
+	if err != nil || result.Created != 1 {
+		t.Fatalf("result = %+v, %v", result, err)
+	}
+	created, err := s.Repo.GetBySKU("NEW-1")
+	if err != nil || created.ID == 999 {
+		t.Errorf("created = %+v, %v", created, err)
+	}
+}
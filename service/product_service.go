@@ -1,39 +1,105 @@
 package service
 
 import (
+	"encoding/json"
 	"errors"
-	"math"
+	"fmt"
+	"strings"
+	"time"
 
+	"futuremarket/config"
 	"futuremarket/models"
+	"futuremarket/pagination"
 	"futuremarket/repository"
 )
 
+var (
+	ErrInvalidProduct       = errors.New("invalid product fields")
+	ErrInvalidSort          = errors.New("invalid sort")
+	ErrInvalidFacet         = errors.New("invalid facet")
+	ErrInvalidProductStatus = errors.New("invalid product status")
+	ErrProductUnavailable   = errors.New("product is not available")
+	ErrDuplicateSKU         = errors.New("another product already uses this SKU")
+)
+
 type ProductService struct {
-	Repo repository.ProductRepo
+	Repo       repository.ProductRepo
+	Categories repository.CategoryRepo
+	Attributes AttributeService
+	Cursors    *pagination.Signer
 }
 
 type ProductListResponse struct {
-	Products []models.Product `json:"products"`
-	Meta     PaginationMeta   `json:"meta"`
+	Products []ProductResult           `json:"products"`
+	Meta     PaginationMeta            `json:"meta"`
+	Facets   *repository.ProductFacets `json:"facets,omitempty"`
+}
+
+// FacetRequest names the facets to count alongside a listing. PriceBuckets
+// overrides the configured price boundaries when set.
+type FacetRequest struct {
+	Names        []string
+	PriceBuckets []int64
 }
 
-type PaginationMeta struct {
-	TotalItems  int64 `json:"total_items"`
-	TotalPages  int   `json:"total_pages"`
-	CurrentPage int   `json:"current_page"`
-	Limit       int   `json:"limit"`
+// ProductResult is a product in a listing. Highlight is only set for
+// search queries (q=) and carries <mark>-tagged snippets and the rank.
+type ProductResult struct {
+	models.Product
+	Highlight *repository.ProductHighlight `json:"highlight,omitempty"`
+}
+
+// ProductStatusInput sets a product's status (draft, active, archived)
+// and publish window. Nil fields are left alone; an empty time clears that
+// end of the window. Times are RFC 3339.
+type ProductStatusInput struct {
+	Status      *string `json:"status"`
+	PublishAt   *string `json:"publish_at"`
+	UnpublishAt *string `json:"unpublish_at"`
 }
 
 // CREATE PRODUCT
-func (s ProductService) CreateProduct(p *models.Product) error {
+// attributes are the product's custom attribute values, checked against
+// its category's definitions. New products are active unless status says
+// otherwise.
+func (s ProductService) CreateProduct(p *models.Product, attributes map[string]json.RawMessage, status ProductStatusInput) error {
 	if p.Name == "" || p.PriceCents <= 0 {
-		return errors.New("invalid product fields")
+		return ErrInvalidProduct
+	}
+
+	p.Status = models.ProductActive
+	if err := applyStatus(p, status); err != nil {
+		return err
 	}
+
+	if err := s.checkSKU(p.SKU, 0); err != nil {
+		return err
+	}
+
+	// Category by id, or by name/slug for older clients
+	if p.CategoryID != nil || p.Category != "" {
+		category, err := resolveCategory(s.Categories, p.CategoryID, p.Category)
+		if err != nil {
+			return err
+		}
+		p.CategoryID, p.Category = &category.ID, category.Name
+	}
+
+	if err := s.Attributes.ApplyValues(p, attributes, true); err != nil {
+		return err
+	}
+
 	return s.Repo.CreateProduct(p)
 }
 
 // UPDATE PRODUCT
-func (s ProductService) UpdateProduct(id uint, updateData *models.Product) (models.Product, error) {
+// attributes are merged into the existing values; null removes one.
+func (s ProductService) UpdateProduct(
+	id uint,
+	updateData *models.Product,
+	attributes map[string]json.RawMessage,
+	status ProductStatusInput,
+) (models.Product, error) {
 	existing, err := s.Repo.GetProductByID(id)
 	if err != nil {
 		return models.Product{}, errors.New("product not found")
@@ -45,8 +111,20 @@ func (s ProductService) UpdateProduct(id uint, updateData *models.Product) (mode
 	if updateData.Description != "" {
 		existing.Description = updateData.Description
 	}
-	if updateData.Category != "" {
-		existing.Category = updateData.Category
+	if updateData.SKU != "" && updateData.SKU != existing.SKU {
+		if err := s.checkSKU(updateData.SKU, existing.ID); err != nil {
+			return models.Product{}, err
+		}
+		existing.SKU = updateData.SKU
+	}
+	categoryChanged := false
+	if updateData.CategoryID != nil || updateData.Category != "" {
+		category, err := resolveCategory(s.Categories, updateData.CategoryID, updateData.Category)
+		if err != nil {
+			return models.Product{}, err
+		}
+		categoryChanged = existing.CategoryID == nil || *existing.CategoryID != category.ID
+		existing.CategoryID, existing.Category = &category.ID, category.Name
 	}
 	if updateData.PriceCents > 0 {
 		existing.PriceCents = updateData.PriceCents
@@ -60,41 +138,178 @@ func (s ProductService) UpdateProduct(id uint, updateData *models.Product) (mode
 		existing.Stock = updateData.Stock
 	}
 
+	if err := applyStatus(&existing, status); err != nil {
+		return models.Product{}, err
+	}
+
+	// Values must still fit the category when it or they change
+	if categoryChanged || len(attributes) > 0 {
+		if err := s.Attributes.ApplyValues(&existing, attributes, true); err != nil {
+			return models.Product{}, err
+		}
+	}
+
 	err = s.Repo.UpdateProduct(&existing)
 	return existing, err
 }
 
+// ArchiveProduct withdraws a product: it disappears from the catalogue and
+// can't be bought, but orders keep showing it. It returns the product
+// before and after.
+func (s ProductService) ArchiveProduct(id uint) (models.Product, models.Product, error) {
+	product, err := s.Repo.GetProductByID(id)
+	if err != nil {
+		return models.Product{}, models.Product{}, err
+	}
+	before := product
+
+	product.Status = models.ProductArchived
+	if err := s.Repo.UpdateProduct(&product); err != nil {
+		return models.Product{}, models.Product{}, err
+	}
+	return before, product, nil
+}
+
+// checkSKU makes sure sku, if set, is well-formed and unused by other
+// products.
+func (s ProductService) checkSKU(sku string, exceptID uint) error {
+	if sku == "" {
+		return nil
+	}
+	if len(sku) > 64 || strings.TrimSpace(sku) != sku {
+		return fmt.Errorf("%w: sku must be at most 64 characters without surrounding spaces", ErrInvalidProduct)
+	}
+
+	taken, err := s.Repo.SKUTaken(sku, exceptID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrDuplicateSKU
+	}
+	return nil
+}
+
+// applyStatus validates in and copies the fields that are set onto p.
+func applyStatus(p *models.Product, in ProductStatusInput) error {
+	if in.Status != nil {
+		switch *in.Status {
+		case models.ProductDraft, models.ProductActive, models.ProductArchived:
+			p.Status = *in.Status
+		default:
+			return fmt.Errorf("%w %q: use draft, active or archived", ErrInvalidProductStatus, *in.Status)
+		}
+	}
+
+	for _, f := range []struct {
+		name  string
+		value *string
+		dst   **time.Time
+	}{
+		{"publish_at", in.PublishAt, &p.PublishAt},
+		{"unpublish_at", in.UnpublishAt, &p.UnpublishAt},
+	} {
+		switch {
+		case f.value == nil:
+		case *f.value == "":
+			*f.dst = nil
+		default:
+			t, err := time.Parse(time.RFC3339, *f.value)
+			if err != nil {
+				return fmt.Errorf("%w: %s must be an RFC 3339 time", ErrInvalidProductStatus, f.name)
+			}
+			*f.dst = &t
+		}
+	}
+
+	if p.PublishAt != nil && p.UnpublishAt != nil && !p.UnpublishAt.After(*p.PublishAt) {
+		return fmt.Errorf("%w: unpublish_at must be after publish_at", ErrInvalidProductStatus)
+	}
+	return nil
+}
+
 // LIST WITH FILTERS
 func (s ProductService) ListProductsWithFilters(
-	page int,
-	limit int,
-	minPrice *int64,
-	maxPrice *int64,
-	category *string,
+	params PageParams,
+	filter repository.ProductFilter,
+	facets FacetRequest,
 ) (ProductListResponse, error) {
 
-	if limit <= 0 {
-		limit = 10
+	if filter.Sort != "" && !repository.IsProductSort(filter.Sort) {
+		return ProductListResponse{}, fmt.Errorf("%w %q: use one of %s",
+			ErrInvalidSort, filter.Sort, strings.Join(repository.ProductSortKeys(), ", "))
+	}
+	for _, name := range facets.Names {
+		if !repository.IsProductFacet(name) {
+			return ProductListResponse{}, fmt.Errorf("%w %q: use category, price or rating", ErrInvalidFacet, name)
+		}
+	}
+
+	// ?category=Electronics means the "electronics" category
+	if filter.Category != nil {
+		slug := slugify(*filter.Category)
+		filter.Category = &slug
 	}
 
-	products, totalItems, err := s.Repo.ListProductsFiltered(page, limit, minPrice, maxPrice, category)
+	var err error
+	if filter.Attributes, err = s.Attributes.ResolveFilters(filter.Attributes); err != nil {
+		return ProductListResponse{}, err
+	}
+
+	// Cursors only work with the ordering they were issued for
+	list := "products:" + filter.SortName()
+
+	req, err := pageRequest(s.Cursors, list, params, 10)
+	if err != nil {
+		return ProductListResponse{}, err
+	}
+
+	rows, totalItems, err := s.Repo.ListProductsFiltered(req, filter)
 	if err != nil {
 		return ProductListResponse{}, err
 	}
 
-	totalPages := int(math.Ceil(float64(totalItems) / float64(limit)))
+	rows, meta := pageMeta(s.Cursors, list, req, rows, totalItems, func(p repository.ProductRow) (string, uint) {
+		return p.SortKey, p.ID
+	})
+
+	var highlights map[uint]repository.ProductHighlight
+	if filter.Query != "" {
+		ids := make([]uint, len(rows))
+		for i, p := range rows {
+			ids[i] = p.ID
+		}
+		if highlights, err = s.Repo.SearchHighlights(ids, filter.Query); err != nil {
+			return ProductListResponse{}, err
+		}
+	}
 
-	meta := PaginationMeta{
-		TotalItems:  totalItems,
-		TotalPages:  totalPages,
-		CurrentPage: page,
-		Limit:       limit,
+	results := make([]ProductResult, len(rows))
+	for i, p := range rows {
+		results[i] = ProductResult{Product: p.Product}
+		if h, ok := highlights[p.ID]; ok {
+			results[i].Highlight = &h
+		}
 	}
 
-	return ProductListResponse{
-		Products: products,
+	response := ProductListResponse{
+		Products: results,
 		Meta:     meta,
-	}, nil
+	}
+
+	if len(facets.Names) > 0 {
+		bounds := facets.PriceBuckets
+		if len(bounds) == 0 {
+			bounds = config.ProductPriceBuckets()
+		}
+		counts, err := s.Repo.CountFacets(filter, facets.Names, bounds)
+		if err != nil {
+			return ProductListResponse{}, err
+		}
+		response.Facets = &counts
+	}
+
+	return response, nil
 }
 
 func (s ProductService) GetProductByID(id uint) (models.Product, error) {
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/pagination"
+	"futuremarket/repository"
+)
+
+func TestListProductsByCategoryName(t *testing.T) {
+	db := newTestDB(t, &models.Product{}, &models.Category{}, &models.AttributeDefinition{})
+	s := ProductService{
+		Repo:       repository.ProductRepo{DB: db},
+		Categories: repository.CategoryRepo{DB: db},
+		Attributes: AttributeService{Repo: repository.AttributeRepo{DB: db}, Categories: repository.CategoryRepo{DB: db}},
+		Cursors:    pagination.NewSigner([]byte("test")),
+	}
+
+	electronics := models.Category{Name: "Electronics", Slug: "electronics"}
+	db.Create(&electronics)
+	tvs := models.Category{Name: "TVs", Slug: "tvs", ParentID: &electronics.ID}
+	db.Create(&tvs)
+	garden := models.Category{Name: "Garden", Slug: "garden"}
+	db.Create(&garden)
+
+	for _, p := range []struct {
+		name     string
+		category uint
+	}{
+		{"Radio", electronics.ID}, {"OLED", tvs.ID}, {"Hose", garden.ID},
+	} {
+		product := models.Product{Name: p.name, CategoryID: &p.category, Status: models.ProductActive, Attributes: json.RawMessage(`{}`)}
+		if err := db.Create(&product).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		category string
+		want     []string
+	}{
+		{"electronics", []string{"OLED", "Radio"}},
+		{"Electronics", []string{"OLED", "Radio"}},
+		{" TVs ", []string{"OLED"}},
+		{"Toys", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.category, func(t *testing.T) {
+			category := tt.category
+			result, err := s.ListProductsWithFilters(PageParams{Limit: 10}, repository.ProductFilter{Category: &category, Sort: "newest"}, FacetRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, p := range result.Products {
+				got = append(got, p.Name)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("products = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
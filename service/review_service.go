@@ -2,9 +2,12 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"math"
+	"time"
 
 	"futuremarket/models"
+	"futuremarket/pagination"
 	"futuremarket/repository"
 
 	"gorm.io/gorm"
@@ -13,7 +16,8 @@ import (
 // ReviewService contains business logic for Epic 6 (reviews & ratings).
 // It uses a ReviewRepo for DB access.
 type ReviewService struct {
-	Repo repository.ReviewRepo
+	Repo    repository.ReviewRepo
+	Cursors *pagination.Signer
 }
 
 // ListReviews returns review rows + user display names for a product.
@@ -92,22 +96,25 @@ type PaginatedReviews struct {
     Meta    PaginationMeta   `json:"meta"`
 }
 
-func (s ReviewService) ListReviewsPaginated(productID uint, page, limit int) (PaginatedReviews, error) {
+func (s ReviewService) ListReviewsPaginated(productID uint, params PageParams) (PaginatedReviews, error) {
 
-    reviews, total, err := s.Repo.ListReviewsPaginated(productID, page, limit)
+    // Cursors are bound to the product they were issued for
+    list := fmt.Sprintf("reviews:%d", productID)
+
+    req, err := pageRequest(s.Cursors, list, params, 10)
     if err != nil {
         return PaginatedReviews{}, err
     }
 
-    totalPages := int(math.Ceil(float64(total) / float64(limit)))
-
-    meta := PaginationMeta{
-        TotalItems:  total,
-        TotalPages:  totalPages,
-        CurrentPage: page,
-        Limit:       limit,
+    reviews, total, err := s.Repo.ListReviewsPaginated(productID, req)
+    if err != nil {
+        return PaginatedReviews{}, err
     }
 
+    reviews, meta := pageMeta(s.Cursors, list, req, reviews, total, func(r models.Review) (string, uint) {
+        return r.CreatedAt.Format(time.RFC3339Nano), r.ID
+    })
+
     return PaginatedReviews{
         Reviews: reviews,
         Meta:    meta,
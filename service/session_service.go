@@ -0,0 +1,150 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/repository"
+
+	"gorm.io/gorm"
+)
+
+// touchInterval limits how often a session's last_seen_at is written, so a
+// busy client doesn't cause one UPDATE per request.
+const touchInterval = time.Minute
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionView is how a session is shown to its owner.
+type SessionView struct {
+	ID         uint      `json:"id"`
+	DeviceName string    `json:"device_name"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	Current    bool      `json:"current"`
+}
+
+// SessionService lists and revokes a user's signed-in devices and tells
+// AuthMiddleware whether a token's session is still alive.
+type SessionService struct {
+	Repo   repository.SessionRepo
+	Tokens TokenService
+
+	// Cache keeps IsSessionActive off the database on most requests.
+	// Revoked sessions are cached for good; active ones only for ActiveTTL,
+	// which bounds how long a sign-out on another replica takes to apply.
+	Cache     *TTLCache
+	ActiveTTL time.Duration
+}
+
+func sessionCacheKey(sessionID, userID uint) string {
+	return fmt.Sprintf("session:%d:%d", sessionID, userID)
+}
+
+// ListSessions returns the user's active sessions, flagging currentID.
+func (s SessionService) ListSessions(userID, currentID uint) ([]SessionView, error) {
+	sessions, err := s.Repo.ListActiveForUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]SessionView, 0, len(sessions))
+	for _, sess := range sessions {
+		views = append(views, SessionView{
+			ID:         sess.ID,
+			DeviceName: sess.DeviceName,
+			IP:         sess.IP,
+			UserAgent:  sess.UserAgent,
+			CreatedAt:  sess.CreatedAt,
+			LastSeenAt: sess.LastSeenAt,
+			Current:    sess.ID == currentID,
+		})
+	}
+	return views, nil
+}
+
+// RevokeSession signs out one of the user's own sessions.
+func (s SessionService) RevokeSession(userID, sessionID uint) error {
+	session, err := s.Repo.GetByID(sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+
+	// Don't reveal other users' session IDs
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	if err := s.Tokens.RevokeSession(sessionID); err != nil {
+		return err
+	}
+
+	s.Cache.Set(sessionCacheKey(sessionID, userID), false, s.revokedTTL())
+	return nil
+}
+
+// RevokeAll signs the user out everywhere.
+func (s SessionService) RevokeAll(userID uint) error {
+	sessions, err := s.Repo.ListActiveForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Tokens.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+
+	for _, sess := range sessions {
+		s.Cache.Set(sessionCacheKey(sess.ID, userID), false, s.revokedTTL())
+	}
+	return nil
+}
+
+// revokedTTL is how long a revoked answer stays cached: long enough to
+// outlive any access token that could still point at the session.
+func (s SessionService) revokedTTL() time.Duration {
+	return config.AccessTokenTTL()
+}
+
+// IsSessionActive reports whether a token's session still exists, belongs to
+// the user and hasn't been revoked. It also bumps last_seen_at.
+func (s SessionService) IsSessionActive(sessionID, userID uint) (bool, error) {
+	cacheKey := sessionCacheKey(sessionID, userID)
+	if active, ok := s.Cache.Get(cacheKey); ok {
+		return active, nil
+	}
+
+	session, err := s.Repo.GetByID(sessionID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	if !isActive(session, userID) {
+		s.Cache.Set(cacheKey, false, s.revokedTTL())
+		return false, nil
+	}
+
+	s.Cache.Set(cacheKey, true, s.ActiveTTL)
+
+	if time.Since(session.LastSeenAt) > touchInterval {
+		// Best effort: a failed touch shouldn't fail the request
+		_ = s.Repo.Touch(session.ID, time.Now(), "")
+	}
+
+	return true, nil
+}
+
+func isActive(session models.Session, userID uint) bool {
+	return session.UserID == userID && session.RevokedAt == nil
+}
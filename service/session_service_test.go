@@ -0,0 +1,46 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRevokeSessionBeatsCache(t *testing.T) {
+	tokens := newTestTokenService(t)
+	s := SessionService{
+		Repo:      tokens.Sessions,
+		Tokens:    tokens,
+		Cache:     NewTTLCache(100),
+		ActiveTTL: time.Hour,
+	}
+	user := createTestUser(t, tokens.Repo.DB, "sessions@example.com")
+	other := createTestUser(t, tokens.Repo.DB, "other@example.com")
+
+	pair, err := tokens.IssueTokens(user, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, _ := tokens.Keys.Parse(pair.AccessToken)
+	sessionID := uint(claims["sid"].(float64))
+
+	// Cache the session as active
+	if active, err := s.IsSessionActive(sessionID, user.ID); err != nil || !active {
+		t.Fatalf("new session: active = %v, %v", active, err)
+	}
+
+	if err := s.RevokeSession(other.ID, sessionID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("someone else's session: err = %v, want ErrSessionNotFound", err)
+	}
+	if err := s.RevokeSession(user.ID, sessionID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Revoked right away, not after ActiveTTL
+	if active, err := s.IsSessionActive(sessionID, user.ID); err != nil || active {
+		t.Errorf("revoked session: active = %v, %v", active, err)
+	}
+	if _, err := tokens.Refresh(pair.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("refresh after revoking: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+)
+
+// thumbnailSize is the longest side of generated thumbnails, in pixels.
+const thumbnailSize = 400
+
+// makeThumbnail shrinks img to fit thumbnailSize and encodes it: JPEG for
+// photos, PNG when the source format (png, gif) may have transparency.
+// It returns the encoded image and its content type.
+func makeThumbnail(img image.Image, format string) ([]byte, string, error) {
+	thumb := shrink(img, thumbnailSize)
+
+	var buf bytes.Buffer
+	if format == "jpeg" {
+		if err := jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80}); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/jpeg", nil
+	}
+
+	if err := png.Encode(&buf, thumb); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/png", nil
+}
+
+// shrink scales img down so neither side exceeds size, keeping the aspect
+// ratio. Each output pixel is the average of the source pixels it covers
+// (a box filter), which is cheap and avoids the aliasing of nearest
+// neighbour. Images that already fit are returned unscaled.
+func shrink(img image.Image, size int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	sw, sh := b.Dx(), b.Dy()
+	if sw <= size && sh <= size {
+		return src
+	}
+
+	dw, dh := size, sh*size/sw
+	if sh > sw {
+		dw, dh = sw*size/sh, size
+	}
+	dw, dh = max(dw, 1), max(dh, 1)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+
+			// RGBA pixels are alpha-premultiplied, so plain averages are right
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride+x0*4 : sy*src.Stride+x1*4]
+				for i := 0; i < len(row); i += 4 {
+					sum[0] += int(row[i])
+					sum[1] += int(row[i+1])
+					sum[2] += int(row[i+2])
+					sum[3] += int(row[i+3])
+				}
+			}
+
+			n := (y1 - y0) * (x1 - x0)
+			o := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[o+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
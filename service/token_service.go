@@ -0,0 +1,304 @@
+package service
+
+import (
+	"errors"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/jwtkeys"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/utils"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrInvalidChallenge    = errors.New("invalid or expired two-factor challenge")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used; all sessions from this login were revoked")
+)
+
+// TokenPair is what a client receives after login or refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"` // access token lifetime in seconds
+}
+
+// SessionMeta describes the device a login comes from.
+type SessionMeta struct {
+	DeviceName string
+	IP         string
+	UserAgent  string
+}
+
+// TokenService issues short-lived JWT access tokens, rotates the opaque
+// refresh tokens stored in the refresh_tokens table and owns the session
+// each pair belongs to.
+type TokenService struct {
+	Keys       *jwtkeys.Keyset
+	Repo       repository.RefreshTokenRepo
+	Sessions   repository.SessionRepo
+	Challenges repository.MFAChallengeRepo
+}
+
+// IssueTokens creates a session (and with it a new refresh token family)
+// for a fresh login. mfa records whether the login passed a second factor.
+func (s TokenService) IssueTokens(user models.User, mfa bool, meta SessionMeta) (TokenPair, error) {
+	familyID, err := utils.RandomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	var pair TokenPair
+
+	err = s.Repo.DB.Transaction(func(tx *gorm.DB) error {
+		session := models.Session{
+			UserID:     user.ID,
+			FamilyID:   familyID,
+			DeviceName: meta.DeviceName,
+			IP:         meta.IP,
+			UserAgent:  meta.UserAgent,
+			MFA:        mfa,
+			LastSeenAt: time.Now(),
+		}
+		if err := (repository.SessionRepo{DB: tx}).Create(&session); err != nil {
+			return err
+		}
+
+		var err error
+		pair, err = s.issue(repository.RefreshTokenRepo{DB: tx}, user, session)
+		return err
+	})
+
+	return pair, err
+}
+
+// IssueChallenge returns a short-lived token proving the password step of a
+// two-factor login succeeded. AuthMiddleware never accepts it, and its jti
+// is stored so ConsumeChallenge accepts it only once.
+func (s TokenService) IssueChallenge(user models.User) (string, error) {
+	jti, err := utils.RandomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(config.MFAChallengeTTL())
+
+	// Challenges nobody answered would pile up otherwise
+	if err := s.Challenges.DeleteExpired(now); err != nil {
+		return "", err
+	}
+	if err := s.Challenges.Create(&models.MFAChallenge{JTI: jti, UserID: user.ID, ExpiresAt: expiresAt}); err != nil {
+		return "", err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"purpose": "mfa_challenge",
+		"jti":     jti,
+		"exp":     expiresAt.Unix(),
+	}
+
+	return s.Keys.Sign(claims)
+}
+
+// ConsumeChallenge validates a challenge token, uses it up and returns its
+// user ID. Each challenge works once, whether or not the code that comes
+// with it is right; after a wrong code the user logs in again.
+func (s TokenService) ConsumeChallenge(challenge string) (uint, error) {
+	claims, err := s.Keys.Parse(challenge)
+	if err != nil || claims["purpose"] != "mfa_challenge" {
+		return 0, ErrInvalidChallenge
+	}
+
+	userID, ok := claims["user_id"].(float64)
+	jti, _ := claims["jti"].(string)
+	if !ok || userID < 1 || jti == "" {
+		return 0, ErrInvalidChallenge
+	}
+
+	fresh, err := s.Challenges.Consume(jti, uint(userID), time.Now())
+	if err != nil {
+		return 0, err
+	}
+	if !fresh {
+		return 0, ErrInvalidChallenge
+	}
+
+	return uint(userID), nil
+}
+
+// Refresh exchanges a refresh token for a new pair. The presented token is
+// marked used; presenting it a second time revokes its whole session.
+func (s TokenService) Refresh(rawToken, ip string) (TokenPair, error) {
+	if rawToken == "" {
+		return TokenPair{}, ErrInvalidRefreshToken
+	}
+
+	var pair TokenPair
+	var reusedFamily string
+
+	err := s.Repo.DB.Transaction(func(tx *gorm.DB) error {
+		repo := repository.RefreshTokenRepo{DB: tx}
+		sessions := repository.SessionRepo{DB: tx}
+
+		stored, err := repo.FindByHash(utils.HashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrInvalidRefreshToken
+			}
+			return err
+		}
+
+		if stored.RevokedAt != nil || time.Now().After(stored.ExpiresAt) {
+			return ErrInvalidRefreshToken
+		}
+
+		// Already rotated → somebody is replaying an old token.
+		if stored.UsedAt != nil {
+			reusedFamily = stored.FamilyID
+			return ErrRefreshTokenReused
+		}
+
+		ok, err := repo.MarkUsed(stored.ID, time.Now())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// Lost a race with a concurrent refresh of the same token.
+			reusedFamily = stored.FamilyID
+			return ErrRefreshTokenReused
+		}
+
+		session, err := sessions.GetByFamily(stored.FamilyID)
+		if err != nil || session.RevokedAt != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		user, err := repository.UserRepo{DB: tx}.GetUserByID(stored.UserID)
+		if err != nil || user.DisabledAt != nil {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := sessions.Touch(session.ID, time.Now(), ip); err != nil {
+			return err
+		}
+
+		pair, err = s.issue(repo, user, session)
+		return err
+	})
+
+	// Revoke outside the transaction so it isn't rolled back with it.
+	if reusedFamily != "" {
+		if revokeErr := s.revokeFamily(reusedFamily); revokeErr != nil {
+			return TokenPair{}, revokeErr
+		}
+	}
+
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return pair, nil
+}
+
+// RevokeSession signs one session out: the session row and every refresh
+// token in its family.
+func (s TokenService) RevokeSession(sessionID uint) error {
+	session, err := s.Sessions.GetByID(sessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Sessions.Revoke(session.ID); err != nil {
+		return err
+	}
+	return s.Repo.RevokeFamily(session.FamilyID)
+}
+
+// RevokeAllForUser signs a user out of every session they have.
+func (s TokenService) RevokeAllForUser(userID uint) error {
+	if err := s.Sessions.RevokeAllForUser(userID); err != nil {
+		return err
+	}
+	return s.Repo.RevokeAllForUser(userID)
+}
+
+// RevokeOtherLogins signs a user out everywhere except keepSessionID
+// (0 signs out everything).
+func (s TokenService) RevokeOtherLogins(userID, keepSessionID uint) error {
+	if keepSessionID == 0 {
+		return s.RevokeAllForUser(userID)
+	}
+
+	keep, err := s.Sessions.GetByID(keepSessionID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Sessions.RevokeAllForUserExcept(userID, keep.ID); err != nil {
+		return err
+	}
+	return s.Repo.RevokeAllForUserExcept(userID, keep.FamilyID)
+}
+
+// revokeFamily revokes a refresh token family and the session that owns it.
+func (s TokenService) revokeFamily(familyID string) error {
+	if session, err := s.Sessions.GetByFamily(familyID); err == nil {
+		if err := s.Sessions.Revoke(session.ID); err != nil {
+			return err
+		}
+	}
+	return s.Repo.RevokeFamily(familyID)
+}
+
+// issue signs an access token for session and persists a new refresh token
+// in the session's family.
+func (s TokenService) issue(repo repository.RefreshTokenRepo, user models.User, session models.Session) (TokenPair, error) {
+	accessTTL := config.AccessTokenTTL()
+
+	jti, err := utils.RandomToken(16)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"role":    user.Role,
+		"sid":     session.ID,
+		"jti":     jti,
+		"mfa":     session.MFA,
+		"exp":     time.Now().Add(accessTTL).Unix(),
+	}
+
+	accessToken, err := s.Keys.Sign(claims)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	rawRefresh, err := utils.RandomToken(32)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	err = repo.Create(&models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  session.FamilyID,
+		TokenHash: utils.HashToken(rawRefresh),
+		ExpiresAt: time.Now().Add(config.RefreshTokenTTL()),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: rawRefresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(accessTTL.Seconds()),
+	}, nil
+}
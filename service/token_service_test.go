@@ -0,0 +1,167 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/utils"
+)
+
+func newTestTokenService(t *testing.T) TokenService {
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.RefreshToken{}, &models.MFAChallenge{})
+	return TokenService{
+		Keys:       testKeyset(),
+		Repo:       repository.RefreshTokenRepo{DB: db},
+		Sessions:   repository.SessionRepo{DB: db},
+		Challenges: repository.MFAChallengeRepo{DB: db},
+	}
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	s := newTestTokenService(t)
+	user := createTestUser(t, s.Repo.DB, "rotate@example.com")
+
+	first, err := s.IssueTokens(user, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.Refresh(first.RefreshToken, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh returned the same refresh token")
+	}
+
+	third, err := s.Refresh(second.RefreshToken, "127.0.0.1")
+	if err != nil {
+		t.Fatalf("refresh of the rotated token: %v", err)
+	}
+
+	claims, err := s.Keys.Parse(third.AccessToken)
+	if err != nil {
+		t.Fatalf("access token: %v", err)
+	}
+	if claims["user_id"] != float64(user.ID) || claims["role"] != "customer" {
+		t.Errorf("claims = %v", claims)
+	}
+}
+
+func TestRefreshReuseRevokesFamily(t *testing.T) {
+	s := newTestTokenService(t)
+	user := createTestUser(t, s.Repo.DB, "reuse@example.com")
+
+	stolen, err := s.IssueTokens(user, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := s.IssueTokens(user, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legit, err := s.Refresh(stolen.RefreshToken, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old token comes back: treat it as stolen
+	if _, err := s.Refresh(stolen.RefreshToken, ""); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("replayed token: err = %v, want ErrRefreshTokenReused", err)
+	}
+
+	// ...which kills the rest of the family and its session
+	if _, err := s.Refresh(legit.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("newest token of the family: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	claims, _ := s.Keys.Parse(legit.AccessToken)
+	session, err := s.Sessions.GetByID(uint(claims["sid"].(float64)))
+	if err != nil || session.RevokedAt == nil {
+		t.Errorf("session not revoked: %+v, %v", session, err)
+	}
+
+	// Other logins are left alone
+	if _, err := s.Refresh(other.RefreshToken, ""); err != nil {
+		t.Errorf("other login: %v", err)
+	}
+}
+
+func TestRefreshRejects(t *testing.T) {
+	s := newTestTokenService(t)
+	user := createTestUser(t, s.Repo.DB, "reject@example.com")
+
+	expired, err := s.IssueTokens(user, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Repo.DB.Model(&models.RefreshToken{}).
+		Where("token_hash = ?", utils.HashToken(expired.RefreshToken)).
+		Update("expires_at", time.Now().Add(-time.Minute))
+
+	disabledUser := createTestUser(t, s.Repo.DB, "disabled@example.com")
+	disabled, err := s.IssueTokens(disabledUser, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Repo.DB.Model(&disabledUser).Update("disabled_at", time.Now())
+
+	revoked, err := s.IssueTokens(user, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RevokeAllForUser(user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"empty", ""},
+		{"unknown", "not-a-token"},
+		{"expired", expired.RefreshToken},
+		{"disabled user", disabled.RefreshToken},
+		{"revoked", revoked.RefreshToken},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := s.Refresh(tt.token, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+				t.Errorf("err = %v, want ErrInvalidRefreshToken", err)
+			}
+		})
+	}
+}
+
+func TestChallengeWorksOnce(t *testing.T) {
+	s := newTestTokenService(t)
+	user := createTestUser(t, s.Repo.DB, "challenge@example.com")
+
+	challenge, err := s.IssueChallenge(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An access token is signed by the same keys but is no challenge
+	pair, err := s.IssueTokens(user, false, SessionMeta{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.ConsumeChallenge(pair.AccessToken); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("access token: err = %v, want ErrInvalidChallenge", err)
+	}
+
+	userID, err := s.ConsumeChallenge(challenge)
+	if err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if userID != user.ID {
+		t.Errorf("user ID = %d, want %d", userID, user.ID)
+	}
+	if _, err := s.ConsumeChallenge(challenge); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("second use: err = %v, want ErrInvalidChallenge", err)
+	}
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the RFC 6238 SHA-1 test key "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	// The RFC lists 8 digit values; ours are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		got, err := totpCode(rfc6238Secret, totpStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("code at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	step := totpStep(now)
+	codeAt := func(delta int64) string {
+		code, err := totpCode(rfc6238Secret, step+delta)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", codeAt(0), step, true},
+		{"surrounding spaces", " " + codeAt(0) + " ", step, true},
+		{"one step behind", codeAt(-1), step - 1, true},
+		{"one step ahead", codeAt(1), step + 1, true},
+		{"two steps behind", codeAt(-2), 0, false},
+		{"two steps ahead", codeAt(2), 0, false},
+		{"too short", codeAt(0)[:5], 0, false},
+		{"too long", codeAt(0) + "0", 0, false},
+		{"empty", "", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := matchTOTP(rfc6238Secret, tt.code, now)
+			if ok != tt.wantOK || got != tt.wantStep {
+				t.Errorf("matchTOTP = %d, %v; want %d, %v", got, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+
+	if _, ok := matchTOTP("not base32!", codeAt(0), now); ok {
+		t.Error("accepted a code for an undecodable secret")
+	}
+}
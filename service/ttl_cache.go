@@ -0,0 +1,87 @@
+package service
+
+import (
+	"sync"
+	"time"
+)
+
+// TTLCache is a small in-process cache whose entries expire on their own.
+// It sits in front of the database on the per-request auth path.
+type TTLCache struct {
+	mu      sync.Mutex
+	entries map[string]ttlEntry
+	maxSize int
+}
+
+type ttlEntry struct {
+	value     bool
+	expiresAt time.Time
+}
+
+// NewTTLCache creates a cache holding at most maxSize entries.
+func NewTTLCache(maxSize int) *TTLCache {
+	return &TTLCache{
+		entries: make(map[string]ttlEntry),
+		maxSize: maxSize,
+	}
+}
+
+// Get returns a cached value and whether it was found and still fresh.
+func (c *TTLCache) Get(key string) (bool, bool) {
+	if c == nil {
+		return false, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return false, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return false, false
+	}
+	return entry.value, true
+}
+
+// Set stores a value for ttl.
+func (c *TTLCache) Set(key string, value bool, ttl time.Duration) {
+	if c == nil || ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= c.maxSize {
+		c.evictLocked()
+	}
+	c.entries[key] = ttlEntry{value: value, expiresAt: time.Now().Add(ttl)}
+}
+
+// Delete drops a key.
+func (c *TTLCache) Delete(key string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// evictLocked drops expired entries, and if that isn't enough, clears the
+// cache. Entries are cheap to rebuild from the database.
+func (c *TTLCache) evictLocked() {
+	now := time.Now()
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+	if len(c.entries) >= c.maxSize {
+		c.entries = make(map[string]ttlEntry)
+	}
+}
@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/repository"
+	"futuremarket/utils"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrTwoFactorNotEnrolling = errors.New("start enrollment first")
+	ErrTwoFactorAlreadyOn    = errors.New("two-factor authentication is already enabled")
+	ErrTwoFactorNotEnabled   = errors.New("two-factor authentication is not enabled")
+	ErrInvalidTwoFactorCode  = errors.New("invalid two-factor code")
+)
+
+// TwoFactorEnrollment is returned when enrollment starts. The URI is meant
+// to be rendered as a QR code by the client.
+type TwoFactorEnrollment struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// TwoFactorService manages RFC 6238 TOTP enrollment, recovery codes and code
+// verification during login.
+type TwoFactorService struct {
+	Repo     repository.TwoFactorRepo
+	UserRepo repository.UserRepo
+
+	// Guard throttles every code check per user, so nobody can run through
+	// the million TOTP codes with a stolen session or challenge token.
+	Guard LoginGuard
+}
+
+// BeginEnrollment generates a new secret for the user. It isn't active until
+// ConfirmEnrollment sees a valid code for it.
+func (s TwoFactorService) BeginEnrollment(userID uint) (TwoFactorEnrollment, error) {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return TwoFactorEnrollment{}, ErrTwoFactorAlreadyOn
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	if err := s.Repo.SetPendingSecret(userID, secret); err != nil {
+		return TwoFactorEnrollment{}, err
+	}
+
+	return TwoFactorEnrollment{
+		Secret:     secret,
+		OTPAuthURI: TOTPURI(config.TOTPIssuer(), user.Email, secret),
+	}, nil
+}
+
+// ConfirmEnrollment activates the pending secret and returns a fresh set of
+// recovery codes. The codes are only ever shown here.
+func (s TwoFactorService) ConfirmEnrollment(userID uint, code string) ([]string, error) {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt != nil {
+		return nil, ErrTwoFactorAlreadyOn
+	}
+	if user.TOTPPendingSecret == "" {
+		return nil, ErrTwoFactorNotEnrolling
+	}
+
+	var step int64
+	err = s.guarded(userID, func() error {
+		var ok bool
+		if step, ok = matchTOTP(user.TOTPPendingSecret, code, time.Now()); !ok {
+			return ErrInvalidTwoFactorCode
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Repo.Enable(userID, user.TOTPPendingSecret, step, time.Now()); err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(userID)
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes. Like Disable it
+// needs the password and a current TOTP code, so a stolen session alone
+// can't mint codes that get past 2FA.
+func (s TwoFactorService) RegenerateRecoveryCodes(userID uint, password, code string) ([]string, error) {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TOTPEnabledAt == nil {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	err = s.guarded(userID, func() error {
+		if err := checkPassword(user, password); err != nil {
+			return err
+		}
+		return s.verifyTOTP(user, code)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.newRecoveryCodes(userID)
+}
+
+// Disable turns 2FA off. It needs the password and a valid code (TOTP or
+// recovery) so a stolen session alone can't remove it.
+func (s TwoFactorService) Disable(userID uint, password, code string) error {
+	user, err := s.UserRepo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.TOTPEnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+	err = s.guarded(userID, func() error {
+		if err := checkPassword(user, password); err != nil {
+			return err
+		}
+		return s.checkCode(user, code)
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Repo.Disable(userID)
+}
+
+// VerifyCode accepts either a current TOTP code or an unused recovery code.
+func (s TwoFactorService) VerifyCode(user models.User, code string) error {
+	if user.TOTPEnabledAt == nil {
+		return ErrTwoFactorNotEnabled
+	}
+
+	return s.guarded(user.ID, func() error {
+		return s.checkCode(user, code)
+	})
+}
+
+// guarded runs check, which verifies a second factor (and maybe the
+// password) of userID, through Guard: it returns a LoginBlockedError while
+// the user is throttled and counts every wrong code or password.
+func (s TwoFactorService) guarded(userID uint, check func() error) error {
+	account := strconv.FormatUint(uint64(userID), 10)
+	if err := s.Guard.Check(account, ""); err != nil {
+		return err
+	}
+
+	err := check()
+	switch {
+	case errors.Is(err, ErrInvalidTwoFactorCode), errors.Is(err, ErrWrongPassword):
+		if recordErr := s.Guard.RecordFailure(account, ""); recordErr != nil {
+			return recordErr
+		}
+	case err == nil:
+		if resetErr := s.Guard.RecordSuccess(account); resetErr != nil {
+			return resetErr
+		}
+	}
+	return err
+}
+
+// checkCode accepts a current TOTP code or an unused recovery code.
+func (s TwoFactorService) checkCode(user models.User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return s.verifyTOTP(user, code)
+	}
+
+	ok, err := s.Repo.UseRecoveryCode(user.ID, utils.HashToken(normaliseRecoveryCode(code)))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s TwoFactorService) verifyTOTP(user models.User, code string) error {
+	step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	// Each code works once
+	fresh, err := s.Repo.AdvanceStep(user.ID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+// newRecoveryCodes creates recoveryCodeCount codes like "k3fq-9z2m-x7pa".
+func (s TwoFactorService) newRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := utils.RandomToken(9)
+		if err != nil {
+			return nil, err
+		}
+		raw = strings.ToLower(strings.NewReplacer("-", "x", "_", "y").Replace(raw))
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12]
+
+		codes = append(codes, code)
+		hashes = append(hashes, utils.HashToken(normaliseRecoveryCode(code)))
+	}
+
+	if err := s.Repo.ReplaceRecoveryCodes(userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// normaliseRecoveryCode ignores case, spaces and dashes.
+func normaliseRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
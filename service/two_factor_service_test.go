@@ -0,0 +1,118 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// newTwoFactorTest returns a TwoFactorService and a user with TOTP enabled
+// and the password "Passw0rd!x".
+func newTwoFactorTest(t *testing.T) (TwoFactorService, models.User) {
+	t.Helper()
+
+	db := newTestDB(t, &models.User{}, &models.RecoveryCode{}, &models.AuditEvent{})
+	guard := newTestGuard(t, NewMemoryAttemptStore(), "2fa")
+	guard.Audit = AuditService{Repo: repository.AuditRepo{DB: db}}
+	guard.Settings.BackoffBase = time.Nanosecond // only the lockout matters here
+
+	s := TwoFactorService{
+		Repo:     repository.TwoFactorRepo{DB: db},
+		UserRepo: repository.UserRepo{DB: db},
+		Guard:    guard,
+	}
+
+	user := createTestUser(t, db, "totp@example.com")
+	hash, err := bcrypt.GenerateFromPassword([]byte("Passw0rd!x"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Model(&user).Update("password_hash", string(hash))
+
+	// Enabled a while ago, so the codes around now are all unused
+	if err := s.Repo.Enable(user.ID, rfc6238Secret, 0, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	user, err = s.UserRepo.GetUserByID(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, user
+}
+
+func currentTOTP(t *testing.T) string {
+	t.Helper()
+
+	code, err := totpCode(rfc6238Secret, totpStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func TestVerifyCodeRejectsReplay(t *testing.T) {
+	s, user := newTwoFactorTest(t)
+	code := currentTOTP(t)
+
+	if err := s.VerifyCode(user, code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := s.VerifyCode(user, code); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("replay: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestRegenerateRecoveryCodesNeedsPassword(t *testing.T) {
+	s, user := newTwoFactorTest(t)
+
+	if _, err := s.RegenerateRecoveryCodes(user.ID, "wrong", currentTOTP(t)); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong password: err = %v, want ErrWrongPassword", err)
+	}
+
+	codes, err := s.RegenerateRecoveryCodes(user.ID, "Passw0rd!x", currentTOTP(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("got %d codes, want %d", len(codes), recoveryCodeCount)
+	}
+
+	// A recovery code works once in place of a TOTP code
+	if err := s.VerifyCode(user, codes[0]); err != nil {
+		t.Errorf("recovery code: %v", err)
+	}
+	if err := s.VerifyCode(user, codes[0]); !errors.Is(err, ErrInvalidTwoFactorCode) {
+		t.Errorf("used recovery code: err = %v, want ErrInvalidTwoFactorCode", err)
+	}
+}
+
+func TestTwoFactorChecksAreThrottled(t *testing.T) {
+	s, user := newTwoFactorTest(t)
+
+	for i := 0; i < 3; i++ {
+		time.Sleep(time.Millisecond)
+		if err := s.VerifyCode(user, "000000"); !errors.Is(err, ErrInvalidTwoFactorCode) {
+			t.Fatalf("wrong code %d: err = %v, want ErrInvalidTwoFactorCode", i+1, err)
+		}
+	}
+
+	// Locked out: even the right code and password are turned away
+	var blocked LoginBlockedError
+	if err := s.VerifyCode(user, currentTOTP(t)); !errors.As(err, &blocked) {
+		t.Fatalf("right code while locked: err = %v, want LoginBlockedError", err)
+	}
+	if err := s.Disable(user.ID, "Passw0rd!x", currentTOTP(t)); !errors.As(err, &blocked) {
+		t.Errorf("disable while locked: err = %v, want LoginBlockedError", err)
+	}
+
+	var events []models.AuditEvent
+	s.Guard.Audit.Repo.DB.Find(&events)
+	if len(events) != 1 || events[0].Action != "2fa.lockout" {
+		t.Errorf("audit events = %+v, want one 2fa.lockout", events)
+	}
+}
@@ -2,14 +2,66 @@ package service
 
 import (
 	"errors"
+	"time"
+
+	"futuremarket/config"
 	"futuremarket/models"
 	"futuremarket/repository"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
+// DefaultRole is what every self-registered account gets.
+const DefaultRole = "customer"
+
+var (
+	ErrUnknownRole      = errors.New("unknown role")
+	ErrCannotModifySelf = errors.New("admins cannot change, disable or delete their own account here")
+	ErrRoleNotCovered   = errors.New("your role lacks permissions of the role involved")
+	ErrAccountDisabled  = errors.New("account is disabled")
+	ErrWrongPassword    = errors.New("current password is incorrect")
+	ErrEmailTaken       = errors.New("email already registered")
+)
+
 type UserService struct {
-	Repo repository.UserRepo
+	Repo         repository.UserRepo
+	Tokens       TokenService
+	Verification EmailVerificationService
+}
+
+// UserView is the public representation of a user. It never carries the
+// password hash.
+type UserView struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Role            string     `json:"role"`
+	EmailVerified   bool       `json:"email_verified"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	PendingEmail    string     `json:"pending_email,omitempty"`
+	Disabled        bool       `json:"disabled"`
+	DisabledAt      *time.Time `json:"disabled_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+func NewUserView(u models.User) UserView {
+	return UserView{
+		ID:              u.ID,
+		Name:            u.Name,
+		Email:           u.Email,
+		Role:            u.Role,
+		EmailVerified:   u.EmailVerifiedAt != nil,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		PendingEmail:    u.PendingEmail,
+		Disabled:        u.DisabledAt != nil,
+		DisabledAt:      u.DisabledAt,
+		CreatedAt:       u.CreatedAt,
+	}
+}
+
+type UserListResponse struct {
+	Users []UserView     `json:"users"`
+	Meta  PaginationMeta `json:"meta"`
 }
 
 func (us UserService) CreateUser(user *models.User) error {
@@ -20,13 +72,19 @@ func (us UserService) GetUserByEmail(email string) (models.User, error) {
 	return us.Repo.GetUserByEmail(email)
 }
 
+func (us UserService) GetUserByID(id uint) (models.User, error) {
+	return us.Repo.GetUserByID(id)
+}
+
 //
 // ===============================
 // REGISTER USER (used by AuthHandler)
 // ===============================
 //
 
-func (s UserService) RegisterUser(name, email, password, role string) (models.User, error) {
+// RegisterUser creates a customer account. The role is never taken from the
+// client; admins promote users through the admin user API.
+func (s UserService) RegisterUser(name, email, password string) (models.User, error) {
 
 	// 1) VALIDATION
 	if err := ValidateName(name); err != nil {
@@ -42,7 +100,7 @@ func (s UserService) RegisterUser(name, email, password, role string) (models.Us
 	// 2) Check duplicate email
 	_, err := s.Repo.GetUserByEmail(email)
 	if err == nil {
-		return models.User{}, errors.New("email already registered")
+		return models.User{}, ErrEmailTaken
 	}
 
 	// 3) Hash password
@@ -56,7 +114,7 @@ func (s UserService) RegisterUser(name, email, password, role string) (models.Us
 		Name:         name,
 		Email:        email,
 		PasswordHash: string(hashed),
-		Role:         role,
+		Role:         DefaultRole,
 	}
 
 	if err := s.Repo.Create(&newUser); err != nil {
@@ -65,3 +123,221 @@ func (s UserService) RegisterUser(name, email, password, role string) (models.Us
 
 	return newUser, nil
 }
+
+//
+// ===============================
+// ADMIN USER MANAGEMENT
+// ===============================
+//
+
+// ListUsers returns a page of users matching search (email or name).
+func (s UserService) ListUsers(page, limit int, search string) (UserListResponse, error) {
+	users, total, err := s.Repo.ListUsers(page, limit, search)
+	if err != nil {
+		return UserListResponse{}, err
+	}
+
+	views := make([]UserView, 0, len(users))
+	for _, u := range users {
+		views = append(views, NewUserView(u))
+	}
+
+	return UserListResponse{
+		Users: views,
+		Meta:  offsetMeta(total, page, limit),
+	}, nil
+}
+
+// ChangeRole moves a user to another role known to the permission policy.
+// The actor's role has to cover both the user's current role and the new
+// one. Existing sessions are revoked so the new role applies immediately.
+func (s UserService) ChangeRole(actorID, userID uint, role string) (models.User, error) {
+	if actorID == userID {
+		return models.User{}, ErrCannotModifySelf
+	}
+	if !config.HasRole(role) {
+		return models.User{}, ErrUnknownRole
+	}
+
+	user, err := s.targetUser(actorID, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := s.checkCovers(actorID, role); err != nil {
+		return models.User{}, err
+	}
+
+	if err := s.Repo.UpdateRole(userID, role); err != nil {
+		return models.User{}, err
+	}
+	if err := s.Tokens.RevokeAllForUser(userID); err != nil {
+		return models.User{}, err
+	}
+
+	user.Role = role
+	return user, nil
+}
+
+// DisableUser blocks a user from logging in and signs them out everywhere.
+func (s UserService) DisableUser(actorID, userID uint) (models.User, error) {
+	if actorID == userID {
+		return models.User{}, ErrCannotModifySelf
+	}
+
+	user, err := s.targetUser(actorID, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	now := time.Now()
+	if err := s.Repo.SetDisabledAt(userID, &now); err != nil {
+		return models.User{}, err
+	}
+	if err := s.Tokens.RevokeAllForUser(userID); err != nil {
+		return models.User{}, err
+	}
+
+	user.DisabledAt = &now
+	return user, nil
+}
+
+// EnableUser lifts a previous DisableUser.
+func (s UserService) EnableUser(userID uint) (models.User, error) {
+	user, err := s.Repo.GetUserByID(userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := s.Repo.SetDisabledAt(userID, nil); err != nil {
+		return models.User{}, err
+	}
+
+	user.DisabledAt = nil
+	return user, nil
+}
+
+// DeleteUser soft-deletes a user and revokes their sessions.
+func (s UserService) DeleteUser(actorID, userID uint) error {
+	if actorID == userID {
+		return ErrCannotModifySelf
+	}
+
+	if _, err := s.targetUser(actorID, userID); err != nil {
+		return err
+	}
+
+	if err := s.Repo.Delete(userID); err != nil {
+		return err
+	}
+
+	return s.Tokens.RevokeAllForUser(userID)
+}
+
+// targetUser loads the user an admin action is aimed at, provided the
+// actor's role covers theirs: a role can't act on a more powerful one.
+func (s UserService) targetUser(actorID, userID uint) (models.User, error) {
+	user, err := s.Repo.GetUserByID(userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, s.checkCovers(actorID, user.Role)
+}
+
+// checkCovers makes sure the actor's role holds every permission of role.
+func (s UserService) checkCovers(actorID uint, role string) error {
+	actor, err := s.Repo.GetUserByID(actorID)
+	if err != nil || !config.RoleCovers(actor.Role, role) {
+		return ErrRoleNotCovered
+	}
+	return nil
+}
+
+//
+// ===============================
+// SELF-SERVICE ACCOUNT (used by AccountHandler)
+// ===============================
+//
+
+// checkPassword verifies a user's current password.
+func checkPassword(user models.User, password string) error {
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return ErrWrongPassword
+	}
+	return nil
+}
+
+// UpdateName changes the caller's display name.
+func (s UserService) UpdateName(userID uint, name string) (models.User, error) {
+	if err := ValidateName(name); err != nil {
+		return models.User{}, err
+	}
+
+	user, err := s.Repo.GetUserByID(userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := s.Repo.UpdateName(userID, name); err != nil {
+		return models.User{}, err
+	}
+
+	user.Name = name
+	return user, nil
+}
+
+// RequestEmailChange records newEmail as pending and mails a verification
+// link to it. The account keeps its current address until the link is used.
+func (s UserService) RequestEmailChange(userID uint, currentPassword, newEmail string) (models.User, error) {
+	if err := ValidateEmail(newEmail); err != nil {
+		return models.User{}, err
+	}
+
+	user, err := s.Repo.GetUserByID(userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := checkPassword(user, currentPassword); err != nil {
+		return models.User{}, err
+	}
+
+	if _, err := s.Repo.GetUserByEmail(newEmail); err == nil {
+		return models.User{}, ErrEmailTaken
+	}
+
+	if err := s.Repo.SetPendingEmail(userID, newEmail); err != nil {
+		return models.User{}, err
+	}
+	if err := s.Verification.SendEmailChangeVerification(user, newEmail); err != nil {
+		return models.User{}, err
+	}
+
+	user.PendingEmail = newEmail
+	return user, nil
+}
+
+// ChangePassword replaces the caller's password after checking the current
+// one, then signs out every other session (keepSessionID stays signed in).
+func (s UserService) ChangePassword(userID uint, currentPassword, newPassword string, keepSessionID uint) error {
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+
+	user, err := s.Repo.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if err := checkPassword(user, currentPassword); err != nil {
+		return err
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	if err := s.Repo.UpdatePasswordHash(userID, string(hashed)); err != nil {
+		return err
+	}
+
+	return s.Tokens.RevokeOtherLogins(userID, keepSessionID)
+}
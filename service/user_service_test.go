@@ -0,0 +1,208 @@
+package service
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"futuremarket/config"
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func TestRegisterAfterDelete(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.RefreshToken{})
+	s := UserService{
+		Repo: repository.UserRepo{DB: db},
+		Tokens: TokenService{
+			Keys:     testKeyset(),
+			Repo:     repository.RefreshTokenRepo{DB: db},
+			Sessions: repository.SessionRepo{DB: db},
+		},
+	}
+	admin := createTestUser(t, db, "admin@example.com")
+
+	first, err := s.RegisterUser("First Owner", "reuse@example.com", "Passw0rd!x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Role != DefaultRole {
+		t.Errorf("role = %q, want %q", first.Role, DefaultRole)
+	}
+
+	if _, err := s.RegisterUser("Second Owner", "reuse@example.com", "Passw0rd!x"); !errors.Is(err, ErrEmailTaken) {
+		t.Fatalf("duplicate email: err = %v, want ErrEmailTaken", err)
+	}
+
+	if err := s.DeleteUser(admin.ID, first.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := s.RegisterUser("Second Owner", "reuse@example.com", "Passw0rd!x")
+	if err != nil {
+		t.Fatalf("register with a deleted account's email: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Error("got the deleted account back")
+	}
+}
+
+func TestChangePasswordKeepsCurrentSession(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.RefreshToken{})
+	tokens := TokenService{
+		Keys:     testKeyset(),
+		Repo:     repository.RefreshTokenRepo{DB: db},
+		Sessions: repository.SessionRepo{DB: db},
+	}
+	s := UserService{Repo: repository.UserRepo{DB: db}, Tokens: tokens}
+
+	user, err := s.RegisterUser("Owner", "owner@example.com", "Passw0rd!x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	current, _ := tokens.IssueTokens(user, false, SessionMeta{})
+	other, _ := tokens.IssueTokens(user, false, SessionMeta{})
+	claims, _ := tokens.Keys.Parse(current.AccessToken)
+	currentSession := uint(claims["sid"].(float64))
+
+	if err := s.ChangePassword(user.ID, "wrong", "N3wPassw0rd!", currentSession); !errors.Is(err, ErrWrongPassword) {
+		t.Fatalf("wrong current password: err = %v, want ErrWrongPassword", err)
+	}
+	if err := s.ChangePassword(user.ID, "Passw0rd!x", "N3wPassw0rd!", currentSession); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tokens.Refresh(current.RefreshToken, ""); err != nil {
+		t.Errorf("current session signed out: %v", err)
+	}
+	if _, err := tokens.Refresh(other.RefreshToken, ""); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("other session: err = %v, want ErrInvalidRefreshToken", err)
+	}
+
+	updated, _ := s.Repo.GetUserByID(user.ID)
+	if checkPassword(updated, "N3wPassw0rd!") != nil {
+		t.Error("new password doesn't match")
+	}
+}
+
+func TestEmailChangeNeedsVerification(t *testing.T) {
+	db := newTestDB(t, &models.User{}, &models.EmailVerificationToken{})
+	mail := &recordingSender{}
+	verification := EmailVerificationService{
+		Repo:     repository.EmailVerificationRepo{DB: db},
+		UserRepo: repository.UserRepo{DB: db},
+		Mailer:   mail,
+	}
+	s := UserService{Repo: repository.UserRepo{DB: db}, Verification: verification}
+
+	user, err := s.RegisterUser("Owner", "old@example.com", "Passw0rd!x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	createTestUser(t, db, "taken@example.com")
+
+	if _, err := s.RequestEmailChange(user.ID, "Passw0rd!x", "taken@example.com"); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("taken address: err = %v, want ErrEmailTaken", err)
+	}
+	if _, err := s.RequestEmailChange(user.ID, "wrong", "new@example.com"); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("wrong password: err = %v, want ErrWrongPassword", err)
+	}
+
+	if _, err := s.RequestEmailChange(user.ID, "Passw0rd!x", "new@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(mail.sent) != 2 || mail.sent[0].To != "new@example.com" || mail.sent[1].To != "old@example.com" {
+		t.Fatalf("mails = %+v, want a link to the new address and a notice to the old one", mail.sent)
+	}
+
+	pending, _ := s.Repo.GetUserByID(user.ID)
+	if pending.Email != "old@example.com" {
+		t.Errorf("email changed before verification: %q", pending.Email)
+	}
+
+	if strings.Contains(mail.sent[1].Body, "token=") {
+		t.Error("the notice to the old address contains a verification link")
+	}
+	mail.sent = mail.sent[:1]
+	if err := verification.Verify(mail.linkToken(t)); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	changed, _ := s.Repo.GetUserByID(user.ID)
+	if changed.Email != "new@example.com" || changed.PendingEmail != "" || changed.EmailVerifiedAt == nil {
+		t.Errorf("after verifying: email %q, pending %q, verified %v", changed.Email, changed.PendingEmail, changed.EmailVerifiedAt)
+	}
+}
+
+func TestAdminActionsNeedCoveringRole(t *testing.T) {
+	config.SetPolicy(config.Policy{
+		"admin":     {config.WildcardAction: true},
+		"support":   {"manage:users": true, "manage:cart": true},
+		"warehouse": {"manage:products": true},
+		"customer":  {"manage:cart": true},
+	})
+	defer config.SetPolicy(config.DefaultPolicy())
+
+	db := newTestDB(t, &models.User{}, &models.Session{}, &models.RefreshToken{})
+	s := UserService{
+		Repo: repository.UserRepo{DB: db},
+		Tokens: TokenService{
+			Keys:     testKeyset(),
+			Repo:     repository.RefreshTokenRepo{DB: db},
+			Sessions: repository.SessionRepo{DB: db},
+		},
+	}
+
+	withRole := func(email, role string) models.User {
+		u := createTestUser(t, db, email)
+		db.Model(&u).Update("role", role)
+		u.Role = role
+		return u
+	}
+	admin := withRole("admin@example.com", "admin")
+	otherAdmin := withRole("admin2@example.com", "admin")
+	support := withRole("support@example.com", "support")
+	warehouse := withRole("warehouse@example.com", "warehouse")
+	customer := withRole("customer@example.com", "customer")
+
+	denied := []struct {
+		name string
+		do   func() error
+	}{
+		{"support grants admin", func() error { _, err := s.ChangeRole(support.ID, customer.ID, "admin"); return err }},
+		{"support grants a permission it lacks", func() error { _, err := s.ChangeRole(support.ID, customer.ID, "warehouse"); return err }},
+		{"support demotes an admin", func() error { _, err := s.ChangeRole(support.ID, admin.ID, "customer"); return err }},
+		{"support changes a role it doesn't cover", func() error { _, err := s.ChangeRole(support.ID, warehouse.ID, "customer"); return err }},
+		{"support disables an admin", func() error { _, err := s.DisableUser(support.ID, admin.ID); return err }},
+		{"support deletes an admin", func() error { return s.DeleteUser(support.ID, admin.ID) }},
+		{"warehouse disables support", func() error { _, err := s.DisableUser(warehouse.ID, support.ID); return err }},
+	}
+	for _, tt := range denied {
+		if err := tt.do(); !errors.Is(err, ErrRoleNotCovered) {
+			t.Errorf("%s: err = %v, want ErrRoleNotCovered", tt.name, err)
+		}
+	}
+
+	var roles []string
+	db.Model(&models.User{}).Order("id").Pluck("role", &roles)
+	if want := []string{"admin", "admin", "support", "warehouse", "customer"}; !slices.Equal(roles, want) {
+		t.Fatalf("roles after denied changes = %q, want %q", roles, want)
+	}
+
+	// Within its own permissions support still manages users
+	if _, err := s.ChangeRole(support.ID, customer.ID, "support"); err != nil {
+		t.Errorf("support grants support: %v", err)
+	}
+	if _, err := s.DisableUser(support.ID, customer.ID); err != nil {
+		t.Errorf("support disables a support user: %v", err)
+	}
+
+	// Admins cover every role, other admins included
+	if _, err := s.ChangeRole(admin.ID, warehouse.ID, "admin"); err != nil {
+		t.Errorf("admin grants admin: %v", err)
+	}
+	if _, err := s.DisableUser(admin.ID, otherAdmin.ID); err != nil {
+		t.Errorf("admin disables an admin: %v", err)
+	}
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+var (
+	ErrVariantNotFound  = errors.New("variant not found for this product")
+	ErrVariantRequired  = errors.New("this product comes in variants; choose one with variant_id")
+	ErrInvalidVariant   = errors.New("invalid variant")
+	ErrDuplicateVariant = errors.New("a variant with this SKU or these options already exists")
+)
+
+// maxVariantOptions caps the option names per variant (size, colour, ...).
+const maxVariantOptions = 5
+
+// VariantView is a variant as shown on the product page. PriceCents and
+// ImageURL already fall back to the product's.
+type VariantView struct {
+	ID         uint              `json:"id"`
+	SKU        string            `json:"sku"`
+	Options    map[string]string `json:"options"`
+	PriceCents int64             `json:"price_cents"`
+	ImageURL   string            `json:"image_url,omitempty"`
+	Stock      int               `json:"stock"`
+	InStock    bool              `json:"in_stock"`
+}
+
+// ProductDetail is a product with its variants and images, for
+// GET /products/{id}.
+type ProductDetail struct {
+	models.Product
+	Variants []VariantView `json:"variants"`
+	Images   []ImageView   `json:"images"`
+}
+
+// VariantInput creates or updates a variant. Nil fields are left alone on
+// update; PriceCents 0 removes the price override.
+type VariantInput struct {
+	SKU        *string           `json:"sku"`
+	Options    map[string]string `json:"options"`
+	PriceCents *int64            `json:"price_cents"`
+	ImageURL   *string           `json:"image_url"`
+	Stock      *int              `json:"stock"`
+}
+
+// VariantService manages the sizes/colours a product is sold in.
+type VariantService struct {
+	Repo        repository.VariantRepo
+	ProductRepo repository.ProductRepo
+}
+
+// GetProductDetail returns a product customers can see and its variants.
+func (s VariantService) GetProductDetail(productID uint) (ProductDetail, error) {
+	product, err := s.ProductRepo.GetLiveProductByID(productID)
+	if err != nil {
+		return ProductDetail{}, err
+	}
+
+	views, err := s.ListVariants(product)
+	if err != nil {
+		return ProductDetail{}, err
+	}
+
+	return ProductDetail{Product: product, Variants: views}, nil
+}
+
+// ListVariants returns the views of a product's variants.
+func (s VariantService) ListVariants(product models.Product) ([]VariantView, error) {
+	variants, err := s.Repo.ListByProduct(product.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, len(variants))
+	for i, v := range variants {
+		ids[i] = v.ID
+	}
+	stock, err := s.Repo.StockByVariant(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]VariantView, 0, len(variants))
+	for _, v := range variants {
+		views = append(views, newVariantView(v, product, stock[v.ID]))
+	}
+	return views, nil
+}
+
+// CreateVariant adds a variant with its own stock row. SKU and options are
+// required.
+func (s VariantService) CreateVariant(productID uint, in VariantInput) (VariantView, error) {
+	product, err := s.ProductRepo.GetProductByID(productID)
+	if err != nil {
+		return VariantView{}, err
+	}
+
+	if in.SKU == nil || in.Options == nil {
+		return VariantView{}, fmt.Errorf("%w: sku and options are required", ErrInvalidVariant)
+	}
+
+	v := models.ProductVariant{ProductID: productID}
+	if err := s.apply(&v, in); err != nil {
+		return VariantView{}, err
+	}
+
+	quantity := 0
+	if in.Stock != nil {
+		quantity = *in.Stock
+	}
+
+	if err := s.Repo.Create(&v, quantity); err != nil {
+		return VariantView{}, err
+	}
+	return newVariantView(v, product, quantity), nil
+}
+
+// UpdateVariant changes the fields set in in. It returns the variant as it
+// was before and after.
+func (s VariantService) UpdateVariant(productID, variantID uint, in VariantInput) (VariantView, VariantView, error) {
+	product, err := s.ProductRepo.GetProductByID(productID)
+	if err != nil {
+		return VariantView{}, VariantView{}, err
+	}
+
+	v, err := s.Repo.GetForProduct(productID, variantID)
+	if err != nil {
+		return VariantView{}, VariantView{}, ErrVariantNotFound
+	}
+
+	stock, err := s.Repo.GetStock(v.ID)
+	if err != nil {
+		return VariantView{}, VariantView{}, err
+	}
+	before := newVariantView(v, product, stock.Quantity)
+
+	if err := s.apply(&v, in); err != nil {
+		return VariantView{}, VariantView{}, err
+	}
+
+	if err := s.Repo.Update(&v, in.Stock); err != nil {
+		return VariantView{}, VariantView{}, err
+	}
+
+	quantity := stock.Quantity
+	if in.Stock != nil {
+		quantity = *in.Stock
+	}
+	return before, newVariantView(v, product, quantity), nil
+}
+
+// DeleteVariant retires a variant. Carts holding it can no longer check
+// out; past orders keep its SKU.
+func (s VariantService) DeleteVariant(productID, variantID uint) (VariantView, error) {
+	product, err := s.ProductRepo.GetProductByID(productID)
+	if err != nil {
+		return VariantView{}, err
+	}
+
+	v, err := s.Repo.GetForProduct(productID, variantID)
+	if err != nil {
+		return VariantView{}, ErrVariantNotFound
+	}
+
+	if err := s.Repo.Delete(v); err != nil {
+		return VariantView{}, err
+	}
+	return newVariantView(v, product, 0), nil
+}
+
+// apply validates in and copies the fields that are set onto v.
+func (s VariantService) apply(v *models.ProductVariant, in VariantInput) error {
+	if in.SKU != nil {
+		sku := strings.TrimSpace(*in.SKU)
+		if sku == "" || len(sku) > 64 {
+			return fmt.Errorf("%w: sku must be 1-64 characters", ErrInvalidVariant)
+		}
+		taken, err := s.Repo.SKUTaken(sku, v.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateVariant
+		}
+		v.SKU = sku
+	}
+
+	if in.Options != nil {
+		options, err := normaliseOptions(in.Options)
+		if err != nil {
+			return err
+		}
+		taken, err := s.Repo.OptionsTaken(v.ProductID, options, v.ID)
+		if err != nil {
+			return err
+		}
+		if taken {
+			return ErrDuplicateVariant
+		}
+		v.Options = options
+	}
+
+	if in.PriceCents != nil {
+		switch {
+		case *in.PriceCents < 0:
+			return fmt.Errorf("%w: price_cents can't be negative", ErrInvalidVariant)
+		case *in.PriceCents == 0:
+			v.PriceCents = nil
+		default:
+			price := *in.PriceCents
+			v.PriceCents = &price
+		}
+	}
+
+	if in.ImageURL != nil {
+		v.ImageURL = strings.TrimSpace(*in.ImageURL)
+	}
+
+	if in.Stock != nil && *in.Stock < 0 {
+		return fmt.Errorf("%w: stock can't be negative", ErrInvalidVariant)
+	}
+
+	return nil
+}
+
+// normaliseOptions lower-cases option names, trims values and encodes them
+// as JSON with sorted keys, so equal selections compare equal in SQL.
+func normaliseOptions(in map[string]string) (string, error) {
+	if len(in) == 0 || len(in) > maxVariantOptions {
+		return "", fmt.Errorf("%w: give 1-%d options", ErrInvalidVariant, maxVariantOptions)
+	}
+
+	out := make(map[string]string, len(in))
+	for name, value := range in {
+		name = strings.ToLower(strings.TrimSpace(name))
+		value = strings.TrimSpace(value)
+		if name == "" || value == "" || len(name) > 50 || len(value) > 100 {
+			return "", fmt.Errorf("%w: option names and values can't be empty", ErrInvalidVariant)
+		}
+		if _, dup := out[name]; dup {
+			return "", fmt.Errorf("%w: option %q given twice", ErrInvalidVariant, name)
+		}
+		out[name] = value
+	}
+
+	// encoding/json writes map keys in sorted order
+	b, err := json.Marshal(out)
+	return string(b), err
+}
+
+func newVariantView(v models.ProductVariant, product models.Product, stock int) VariantView {
+	options := map[string]string{}
+	_ = json.Unmarshal([]byte(v.Options), &options)
+
+	image := v.ImageURL
+	if image == "" {
+		image = product.ImageURL
+	}
+
+	return VariantView{
+		ID:         v.ID,
+		SKU:        v.SKU,
+		Options:    options,
+		PriceCents: v.EffectivePrice(product),
+		ImageURL:   image,
+		Stock:      stock,
+		InStock:    stock > 0,
+	}
+}
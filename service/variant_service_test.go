@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"futuremarket/models"
+	"futuremarket/repository"
+)
+
+func TestNormaliseOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		in   map[string]string
+		want string
+	}{
+		{"sorted and lower-cased", map[string]string{"Size": " 42 ", "colour": "Black"}, `{"colour":"Black","size":"42"}`},
+		{"none", map[string]string{}, ""},
+		{"too many", map[string]string{"a": "1", "b": "2", "c": "3", "d": "4", "e": "5", "f": "6"}, ""},
+		{"empty value", map[string]string{"size": " "}, ""},
+		{"empty name", map[string]string{" ": "42"}, ""},
+		{"same name twice", map[string]string{"Size": "42", "size": "43"}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normaliseOptions(tt.in)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidVariant) {
+					t.Errorf("err = %v, want ErrInvalidVariant", err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("normaliseOptions = %s, %v, want %s", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVariants(t *testing.T) {
+	db := newTestDB(t, &models.Product{}, &models.ProductVariant{}, &models.Stock{})
+	s := VariantService{Repo: repository.VariantRepo{DB: db}, ProductRepo: repository.ProductRepo{DB: db}}
+
+	product := models.Product{Name: "Shoe", PriceCents: 5000, ImageURL: "shoe.jpg", Status: models.ProductActive, Attributes: json.RawMessage(`{}`)}
+	if err := db.Create(&product).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	sku, price, stock := "SHOE-42", int64(5500), 3
+	created, err := s.CreateVariant(product.ID, VariantInput{
+		SKU:        &sku,
+		Options:    map[string]string{"Size": "42"},
+		PriceCents: &price,
+		Stock:      &stock,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.PriceCents != 5500 || created.ImageURL != "shoe.jpg" || created.Stock != 3 || !created.InStock {
+		t.Errorf("created = %+v", created)
+	}
+
+	// Neither the SKU nor the same options (in another spelling) again
+	other := "SHOE-42B"
+	for name, in := range map[string]VariantInput{
+		"same sku":     {SKU: &sku, Options: map[string]string{"size": "43"}},
+		"same options": {SKU: &other, Options: map[string]string{" SIZE ": "42"}},
+	} {
+		if _, err := s.CreateVariant(product.ID, in); !errors.Is(err, ErrDuplicateVariant) {
+			t.Errorf("%s: err = %v, want ErrDuplicateVariant", name, err)
+		}
+	}
+
+	// Price 0 drops the override, stock is set alongside
+	zero, empty := int64(0), 0
+	before, after, err := s.UpdateVariant(product.ID, created.ID, VariantInput{PriceCents: &zero, Stock: &empty})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if before.PriceCents != 5500 || after.PriceCents != 5000 || after.InStock {
+		t.Errorf("before = %+v, after = %+v", before, after)
+	}
+
+	negative := -1
+	if _, _, err := s.UpdateVariant(product.ID, created.ID, VariantInput{Stock: &negative}); !errors.Is(err, ErrInvalidVariant) {
+		t.Errorf("negative stock: err = %v, want ErrInvalidVariant", err)
+	}
+	if _, _, err := s.UpdateVariant(product.ID+1, created.ID, VariantInput{}); err == nil {
+		t.Error("update through another product succeeded")
+	}
+
+	if _, err := s.DeleteVariant(product.ID, created.ID); err != nil {
+		t.Fatal(err)
+	}
+	views, err := s.ListVariants(product)
+	if err != nil || len(views) != 0 {
+		t.Errorf("after delete: %+v, %v", views, err)
+	}
+
+	// The SKU of a deleted variant is free again
+	if _, err := s.CreateVariant(product.ID, VariantInput{SKU: &sku, Options: map[string]string{"size": "42"}}); err != nil {
+		t.Errorf("reuse of a deleted variant's SKU: %v", err)
+	}
+}
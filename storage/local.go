@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalPath is where the API serves files of the local backend.
+const LocalPath = "/uploads"
+
+// Local keeps files in a directory on disk. BaseURL is the URL prefix
+// they are served under, LocalPath unless a CDN or proxy serves Dir.
+type Local struct {
+	Dir     string
+	BaseURL string
+}
+
+func (s Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !validKey(key) {
+		return errors.New("storage: invalid key " + key)
+	}
+
+	path := filepath.Join(s.Dir, filepath.FromSlash(key))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// Write then rename so readers never see half a file
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (s Local) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return errors.New("storage: invalid key " + key)
+	}
+	err := os.Remove(filepath.Join(s.Dir, filepath.FromSlash(key)))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s Local) URL(key string) string {
+	return strings.TrimSuffix(s.BaseURL, "/") + "/" + key
+}
+
+// Handler serves the stored files, to be mounted at LocalPath. Directory
+// listings are refused; keys are unique so files can be cached for good.
+func (s Local) Handler() http.Handler {
+	files := http.FileServer(http.Dir(s.Dir))
+
+	return http.StripPrefix(LocalPath, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		files.ServeHTTP(w, r)
+	}))
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3 keeps files in a bucket of Amazon S3 or a compatible service (MinIO,
+// Cloudflare R2, ...). Requests are signed with AWS Signature Version 4.
+type S3 struct {
+	Endpoint  string // e.g. http://localhost:9000; empty means AWS for Region
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool   // bucket in the path instead of the host name, as most stand-ins expect
+	PublicURL string // where objects are served from; defaults to the bucket URL
+
+	HTTPClient *http.Client
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if !validKey(key) {
+		return errors.New("storage: invalid key " + key)
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", contentType)
+	header.Set("Cache-Control", "public, max-age=31536000, immutable")
+
+	return s.do(ctx, http.MethodPut, key, data, header, http.StatusOK)
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if !validKey(key) {
+		return errors.New("storage: invalid key " + key)
+	}
+	// S3 answers 204 whether or not the object existed
+	return s.do(ctx, http.MethodDelete, key, nil, http.Header{}, http.StatusNoContent, http.StatusNotFound)
+}
+
+func (s *S3) URL(key string) string {
+	if s.PublicURL != "" {
+		return strings.TrimSuffix(s.PublicURL, "/") + "/" + key
+	}
+	return s.objectURL(key).String()
+}
+
+// objectURL is the API URL of key in the bucket.
+func (s *S3) objectURL(key string) *url.URL {
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	u, err := url.Parse(strings.TrimSuffix(endpoint, "/"))
+	if err != nil {
+		u = &url.URL{Scheme: "https", Host: endpoint}
+	}
+
+	if s.PathStyle {
+		u.Path += "/" + s.Bucket + "/" + key
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+		u.Path += "/" + key
+	}
+	return u
+}
+
+// do sends a signed request for key and checks the response status.
+func (s *S3) do(ctx context.Context, method, key string, body []byte, header http.Header, ok ...int) error {
+	u := s.objectURL(key)
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	req.ContentLength = int64(len(body))
+	s.sign(req, body, time.Now().UTC())
+
+	client := s.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("storage: %s %s: %w", method, key, err)
+	}
+	defer resp.Body.Close()
+
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("storage: %s %s: %s: %s", method, key, resp.Status, bytes.TrimSpace(detail))
+}
+
+// sign adds the AWS Signature Version 4 headers to req.
+func (s *S3) sign(req *http.Request, body []byte, now time.Time) {
+	payloadHash := sha256Hex(body)
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	// Sign the host and every header we set ourselves
+	signed := map[string]string{"host": req.URL.Host}
+	for name := range req.Header {
+		signed[strings.ToLower(name)] = strings.TrimSpace(req.Header.Get(name))
+	}
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + signed[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), day)
+	for _, part := range []string{s.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
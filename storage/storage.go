@@ -0,0 +1,69 @@
+// Package storage keeps uploaded files such as product images, either in a
+// local directory or in an S3-compatible bucket.
+package storage
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"futuremarket/config"
+)
+
+// Storage stores files under slash-separated keys like
+// "products/12/abc.jpg". Services only depend on this interface so the
+// backend can change without touching them.
+type Storage interface {
+	// Put stores data under key, replacing anything already there.
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+	// URL is where clients fetch key from.
+	URL(key string) string
+}
+
+// NewFromEnv picks a Storage based on STORAGE_DRIVER ("local" or "s3").
+func NewFromEnv() (Storage, error) {
+	switch driver := config.GetEnv("STORAGE_DRIVER", "local"); driver {
+	case "local":
+		return Local{
+			Dir:     config.GetEnv("STORAGE_DIR", "uploads"),
+			BaseURL: config.GetEnv("STORAGE_PUBLIC_URL", LocalPath),
+		}, nil
+	case "s3":
+		s := &S3{
+			Endpoint:  config.GetEnv("S3_ENDPOINT", ""),
+			Region:    config.GetEnv("S3_REGION", "us-east-1"),
+			Bucket:    config.GetEnv("S3_BUCKET", ""),
+			AccessKey: config.GetEnv("S3_ACCESS_KEY_ID", ""),
+			SecretKey: config.GetEnv("S3_SECRET_ACCESS_KEY", ""),
+			PathStyle: config.GetBool("S3_FORCE_PATH_STYLE", false),
+			PublicURL: config.GetEnv("STORAGE_PUBLIC_URL", ""),
+		}
+		if s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+			return nil, errors.New("storage: S3_BUCKET, S3_ACCESS_KEY_ID and S3_SECRET_ACCESS_KEY are required")
+		}
+		return s, nil
+	default:
+		return nil, errors.New("storage: unknown STORAGE_DRIVER " + driver)
+	}
+}
+
+// validKey accepts keys made of URL-safe characters that can't escape the
+// storage root. Keeping to unreserved characters also means they appear
+// unchanged in URLs and request signatures.
+func validKey(key string) bool {
+	invalid := func(r rune) bool { return !strings.ContainsRune(keyChars, r) }
+	if key == "" || strings.ContainsFunc(key, invalid) {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
+
+// keyChars are the characters keys may use.
+const keyChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~/"